@@ -0,0 +1,306 @@
+// Package apikey manages the API keys machine clients, such as CI runners,
+// authenticate with.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/chaitanyamaili/go_rest/models/apikey/db"
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/auth"
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+	"github.com/chaitanyamaili/go_rest/pkg/validate"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of error variables for CRUD operations.
+var (
+	ErrNotFound  = errors.New("api key not found")
+	ErrInvalidID = errors.New("ID is not in its proper form")
+)
+
+// Fields lists the fields api key reads can select.
+var Fields = db.Fields
+
+// Keys look like gr_<prefix>_<secret>, the prefix identifies the key and
+// only the hash of the whole key is stored.
+const (
+	keyTag         = "gr"
+	keyPrefixBytes = 8
+	keySecretBytes = 32
+)
+
+// touchInterval limits how often the last used time of a key is written.
+const touchInterval = time.Minute
+
+// Core manages the set of APIs for api key access.
+type Core struct {
+	store db.Store
+}
+
+// NewCore constructs a core for api key api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB, rwmux *sync.RWMutex) Core {
+	return Core{
+		store: db.NewStore(log, sqlxDB, rwmux),
+	}
+}
+
+// -----------------------------------------------------------------------
+// CRUD Methods
+// -----------------------------------------------------------------------
+
+// Create generates a new api key and stores its hash. The returned key is
+// the only time the secret is available. Keys act on behalf of the caller
+// unless an owner is given.
+func (c Core) Create(ctx context.Context, nk NewAPIKey, now time.Time) (CreatedAPIKey, error) {
+	if nk.Owner == "" {
+		if v, err := api.GetContextValues(ctx); err == nil {
+			nk.Owner = v.Subject
+		}
+	}
+
+	if err := validate.Check(nk); err != nil {
+		return CreatedAPIKey{}, err
+	}
+	if err := checkKey(nk, now); err != nil {
+		return CreatedAPIKey{}, err
+	}
+
+	key, prefix, hash, err := generateKey()
+	if err != nil {
+		return CreatedAPIKey{}, err
+	}
+
+	dbK := db.APIKey{
+		Prefix:    prefix,
+		Hash:      hash,
+		Name:      strings.TrimSpace(nk.Name),
+		Owner:     strings.TrimSpace(nk.Owner),
+		OrgUID:    database.TenantFrom(ctx).OrgUID,
+		Scopes:    joinScopes(nk.Scopes),
+		ExpiresOn: nk.ExpiresOn,
+		CreatedOn: now,
+		UpdatedOn: now,
+	}
+	if dbK.ExpiresOn != nil {
+		expiresOn := dbK.ExpiresOn.UTC()
+		dbK.ExpiresOn = &expiresOn
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		res, err := c.store.Tran(tx).Create(ctx, dbK)
+		if err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+		dbK.ID = fmt.Sprintf("%d", res.LastInsertID)
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return CreatedAPIKey{}, fmt.Errorf("tran: %w", err)
+	}
+
+	return CreatedAPIKey{APIKey: toAPIKey(dbK), Key: key}, nil
+}
+
+// Rotate replaces the secret of an api key, the previous secret stops
+// working right away.
+func (c Core) Rotate(ctx context.Context, id string, now time.Time) (CreatedAPIKey, error) {
+	if err := validate.CheckID(id); err != nil {
+		return CreatedAPIKey{}, ErrInvalidID
+	}
+
+	dbK, err := c.store.QueryByID(ctx, id, database.Fieldset{})
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return CreatedAPIKey{}, ErrNotFound
+		}
+		return CreatedAPIKey{}, fmt.Errorf("rotating api key id[%s]: %w", id, err)
+	}
+
+	key, prefix, hash, err := generateKey()
+	if err != nil {
+		return CreatedAPIKey{}, err
+	}
+	dbK.Prefix = prefix
+	dbK.Hash = hash
+	dbK.LastUsedOn = nil
+	dbK.UpdatedOn = now
+
+	res, err := c.store.Rotate(ctx, dbK)
+	if err != nil {
+		return CreatedAPIKey{}, fmt.Errorf("rotate id[%s]: %w", id, err)
+	}
+	if res.AffectedRows == 0 {
+		return CreatedAPIKey{}, ErrNotFound
+	}
+
+	return CreatedAPIKey{APIKey: toAPIKey(dbK), Key: key}, nil
+}
+
+// Revoke disables an api key for good.
+func (c Core) Revoke(ctx context.Context, id string, now time.Time) error {
+	if err := validate.CheckID(id); err != nil {
+		return ErrInvalidID
+	}
+
+	res, err := c.store.Revoke(ctx, id, now)
+	if err != nil {
+		return fmt.Errorf("revoke id[%s]: %w", id, err)
+	}
+	if res.AffectedRows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Query retrieves a list of the api keys that were not revoked.
+func (c Core) Query(ctx context.Context, fs database.Fieldset, pagi database.Pagination) ([]APIKey, database.Page, error) {
+	res, page, err := c.store.Query(ctx, fs, pagi)
+	if err != nil {
+		return []APIKey{}, database.Page{}, fmt.Errorf("query: %w", err)
+	}
+
+	return toAPIKeySlice(res), page, nil
+}
+
+// QueryByID retrieves a single api key by id.
+func (c Core) QueryByID(ctx context.Context, id string, fs database.Fieldset) (APIKey, error) {
+	if err := validate.CheckID(id); err != nil {
+		return APIKey{}, ErrInvalidID
+	}
+
+	res, err := c.store.QueryByID(ctx, id, fs)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return APIKey{}, ErrNotFound
+		}
+		return APIKey{}, fmt.Errorf("query: %w", err)
+	}
+
+	return toAPIKey(res), nil
+}
+
+// VerifyKey checks the key against the stored hash and returns the identity
+// the key acts as. Unknown, revoked and expired keys wrap
+// auth.ErrUnauthenticated.
+func (c Core) VerifyKey(ctx context.Context, key string) (auth.Identity, error) {
+	prefix, ok := parseKey(key)
+	if !ok {
+		return auth.Identity{}, fmt.Errorf("%w: malformed api key", auth.ErrUnauthenticated)
+	}
+
+	dbK, err := c.store.QueryByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return auth.Identity{}, fmt.Errorf("%w: unknown api key", auth.ErrUnauthenticated)
+		}
+		return auth.Identity{}, fmt.Errorf("verifying api key: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashKey(key)), []byte(dbK.Hash)) != 1 {
+		return auth.Identity{}, fmt.Errorf("%w: unknown api key", auth.ErrUnauthenticated)
+	}
+
+	now := time.Now().UTC()
+	switch {
+	case dbK.RevokedOn != nil:
+		return auth.Identity{}, fmt.Errorf("%w: api key was revoked", auth.ErrUnauthenticated)
+	case dbK.ExpiresOn != nil && !now.Before(*dbK.ExpiresOn):
+		return auth.Identity{}, fmt.Errorf("%w: api key expired", auth.ErrUnauthenticated)
+	}
+
+	if dbK.LastUsedOn == nil || now.Sub(*dbK.LastUsedOn) >= touchInterval {
+		if _, err := c.store.Touch(ctx, dbK.ID, now.Truncate(time.Second)); err != nil {
+			return auth.Identity{}, fmt.Errorf("verifying api key: %w", err)
+		}
+	}
+
+	return auth.Identity{
+		Subject: dbK.Owner,
+		OrgUID:  dbK.OrgUID,
+		Scopes:  splitScopes(dbK.Scopes),
+	}, nil
+}
+
+// checkKey validates what the struct tags can't: keys need an owner when
+// the caller is not authenticated, scopes are stored comma separated and
+// keys can't be created already expired.
+func checkKey(nk NewAPIKey, now time.Time) error {
+	var fe validate.FieldErrors
+	if nk.Owner == "" {
+		fe.FieldError = append(fe.FieldError, validate.FieldError{
+			Field: "owner",
+			Error: "owner is required when the caller is not authenticated",
+		})
+	}
+	for _, scope := range nk.Scopes {
+		if strings.ContainsRune(scope, ',') || strings.IndexFunc(strings.TrimSpace(scope), unicode.IsSpace) >= 0 {
+			fe.FieldError = append(fe.FieldError, validate.FieldError{
+				Field: "scopes",
+				Error: fmt.Sprintf("scope %q can't contain commas or spaces", scope),
+			})
+		}
+	}
+	if len(joinScopes(nk.Scopes)) > 1024 {
+		fe.FieldError = append(fe.FieldError, validate.FieldError{
+			Field: "scopes",
+			Error: "scopes must be at most 1024 characters long",
+		})
+	}
+	if nk.ExpiresOn != nil && !nk.ExpiresOn.After(now) {
+		fe.FieldError = append(fe.FieldError, validate.FieldError{
+			Field: "expires_on",
+			Error: "expires_on must be in the future",
+		})
+	}
+
+	if len(fe.FieldError) > 0 {
+		return fe
+	}
+	return nil
+}
+
+// generateKey returns a new random key along with its prefix and hash.
+func generateKey() (string, string, string, error) {
+	b := make([]byte, keyPrefixBytes+keySecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", fmt.Errorf("generating api key: %w", err)
+	}
+
+	prefix := hex.EncodeToString(b[:keyPrefixBytes])
+	key := fmt.Sprintf("%s_%s_%s", keyTag, prefix, hex.EncodeToString(b[keyPrefixBytes:]))
+
+	return key, prefix, hashKey(key), nil
+}
+
+// parseKey returns the prefix of a well formed key.
+func parseKey(key string) (string, bool) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != keyTag {
+		return "", false
+	}
+	if len(parts[1]) != 2*keyPrefixBytes || len(parts[2]) != 2*keySecretBytes {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// hashKey returns the hex sha256 of the key. Keys are random enough that a
+// slow hash would only slow down every request.
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
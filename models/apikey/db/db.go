@@ -0,0 +1,281 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store holds details for basic database needs
+type Store struct {
+	log          *zap.SugaredLogger
+	tr           database.Transactor
+	db           sqlx.ExtContext
+	rwmux        *sync.RWMutex
+	isWithinTran bool
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB, rwmux *sync.RWMutex) Store {
+	return Store{
+		log:   log,
+		tr:    db,
+		db:    db,
+		rwmux: rwmux,
+	}
+}
+
+// WithinTran runs passes function and do commit/rollback at the end.
+func (s Store) WithinTran(ctx context.Context, fn func(sqlx.ExtContext) error) error {
+	if s.isWithinTran {
+		return fn(s.db)
+	}
+	s.rwmux.Lock()
+	err := database.WithinTran(ctx, s.log, s.tr, fn)
+	s.rwmux.Unlock()
+
+	return err
+}
+
+// Tran return new Store with transaction in it.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log:          s.log,
+		tr:           s.tr,
+		db:           tx,
+		isWithinTran: true,
+	}
+}
+
+// -----------------------------------------------------------------------
+// Database Query Repository
+// -----------------------------------------------------------------------
+
+// Create inserts a new api key into the database, owned by the org of the
+// request.
+func (s Store) Create(ctx context.Context, k APIKey) (database.DBResults, error) {
+	const q = `
+	INSERT INTO api_key
+		(prefix, hash, name, owner, org_uid, scopes, expires_on, created_on, updated_on)
+	VALUES
+		(:prefix, :hash, :name, :owner, :org_uid, :scopes, :expires_on, :created_on, :updated_on)`
+
+	res, err := database.NamedInsertContext(ctx, s.log, s.db, q, database.TenantFrom(ctx).Data(k))
+	if err != nil {
+		if database.IsDuplicateEntry(err) {
+			return database.DBResults{}, database.NewError(database.ErrDBDuplicatedEntry, http.StatusConflict)
+		}
+
+		return database.DBResults{}, fmt.Errorf("inserting api key: %w", err)
+	}
+
+	return res, nil
+}
+
+// Rotate replaces the secret of an api key in the database.
+func (s Store) Rotate(ctx context.Context, k APIKey) (database.DBResults, error) {
+	t := database.TenantFrom(ctx)
+	q := orgQuery(t, `
+	UPDATE
+		api_key
+	SET
+		prefix = :prefix,
+		hash = :hash,
+		last_used_on = null,
+		updated_on = :updated_on
+	WHERE
+		id = :id
+		and revoked_on is null:tenant`)
+
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, t.Data(k))
+	if err != nil {
+		if database.IsDuplicateEntry(err) {
+			return database.DBResults{}, database.NewError(database.ErrDBDuplicatedEntry, http.StatusConflict)
+		}
+		return database.DBResults{}, fmt.Errorf("rotating api key id[%s]: %w", k.ID, err)
+	}
+
+	return res, nil
+}
+
+// Revoke marks an api key as revoked in the database.
+func (s Store) Revoke(ctx context.Context, id string, now time.Time) (database.DBResults, error) {
+	data := struct {
+		ID        string    `db:"id"`
+		RevokedOn time.Time `db:"revoked_on"`
+	}{
+		ID:        id,
+		RevokedOn: now,
+	}
+
+	t := database.TenantFrom(ctx)
+	q := orgQuery(t, `
+	UPDATE
+		api_key
+	SET
+		revoked_on = :revoked_on,
+		updated_on = :revoked_on
+	WHERE
+		id = :id
+		and revoked_on is null:tenant`)
+
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, t.Data(data))
+	if err != nil {
+		return database.DBResults{}, fmt.Errorf("revoking api key id[%s]: %w", id, err)
+	}
+
+	return res, nil
+}
+
+// Touch records when an api key was last used.
+func (s Store) Touch(ctx context.Context, id string, now time.Time) (database.DBResults, error) {
+	data := struct {
+		ID         string    `db:"id"`
+		LastUsedOn time.Time `db:"last_used_on"`
+	}{
+		ID:         id,
+		LastUsedOn: now,
+	}
+
+	const q = `
+	UPDATE
+		api_key
+	SET
+		last_used_on = :last_used_on
+	WHERE
+		id = :id`
+
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, data)
+	if err != nil {
+		return database.DBResults{}, fmt.Errorf("touching api key id[%s]: %w", id, err)
+	}
+
+	return res, nil
+}
+
+// Fields lists the fields api key reads can select. The hash is never
+// selected through it.
+var Fields = database.Fields{
+	Columns: map[string]string{
+		"id":           "id",
+		"prefix":       "prefix",
+		"name":         "name",
+		"owner":        "owner",
+		"org_uid":      "org_uid",
+		"scopes":       "scopes",
+		"expires_on":   "expires_on",
+		"last_used_on": "last_used_on",
+		"created_on":   "created_on",
+		"updated_on":   "updated_on",
+	},
+}
+
+// Query retrieves a page of the api keys of the org that were not revoked
+// from the database.
+func (s Store) Query(ctx context.Context, fs database.Fieldset, pagi database.Pagination) ([]APIKey, database.Page, error) {
+	t := database.TenantFrom(ctx)
+	q := database.PaginationQuery(pagi, database.KeysetQuery(pagi, "", `
+	SELECT
+		:columns
+	FROM
+		api_key
+	WHERE
+		revoked_on is null:tenant:keyset
+	ORDER BY
+		:sort :direction,
+		id :direction
+	LIMIT
+		:per_page OFFSET :page`))
+
+	const qc = `
+	SELECT
+		COUNT(*) AS total
+	FROM
+		api_key
+	WHERE
+		revoked_on is null:tenant`
+
+	pq := database.PageQuery{
+		Query:    database.FieldsQuery(Fields, fs, orgQuery(t, q), "id", pagi.Sort),
+		Count:    orgQuery(t, qc),
+		Table:    "api_key",
+		Filtered: !t.All,
+	}
+
+	// Slice to hold results
+	var res []APIKey
+	page, err := database.NamedQueryPage(ctx, s.log, s.db, pq, pagi, t.Data(pagi), &res)
+	if err != nil {
+		return nil, database.Page{}, fmt.Errorf("selecting api keys: %w", err)
+	}
+
+	return res, page, nil
+}
+
+// QueryByID retrieves an api key that was not revoked from the database.
+func (s Store) QueryByID(ctx context.Context, id string, fs database.Fieldset) (APIKey, error) {
+	data := struct {
+		ID string `db:"id"`
+	}{ID: id}
+	t := database.TenantFrom(ctx)
+	q := database.FieldsQuery(Fields, fs, orgQuery(t, `
+	SELECT
+		:columns
+	FROM
+		api_key
+	WHERE
+		id = :id
+		and revoked_on is null:tenant`), "id")
+
+	var res APIKey
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, t.Data(data), &res); err != nil {
+		if database.IsError(err) && err.Error() == database.ErrDBNotFound.Error() {
+			return APIKey{}, database.ErrDBNotFound
+		}
+		return APIKey{}, fmt.Errorf("selecting api key by ID[%q]: %w", id, err)
+	}
+
+	return res, nil
+}
+
+// QueryByPrefix retrieves an api key with its hash from the database,
+// revoked keys included. Keys are looked up before the tenant is known so
+// the lookup is not scoped.
+func (s Store) QueryByPrefix(ctx context.Context, prefix string) (APIKey, error) {
+	data := struct {
+		Prefix string `db:"prefix"`
+	}{Prefix: prefix}
+	const q = `
+	SELECT
+		*
+	FROM
+		api_key
+	WHERE
+		prefix = :prefix`
+
+	var res APIKey
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &res); err != nil {
+		if database.IsError(err) && err.Error() == database.ErrDBNotFound.Error() {
+			return APIKey{}, database.ErrDBNotFound
+		}
+		return APIKey{}, fmt.Errorf("selecting api key by prefix[%q]: %w", prefix, err)
+	}
+
+	return res, nil
+}
+
+// orgQuery replaces the :tenant placeholder of the query with the condition
+// keeping the keys of the org, api keys are not bound to a site.
+func orgQuery(t database.Tenant, q string) string {
+	if t.All {
+		return strings.ReplaceAll(q, ":tenant", "")
+	}
+	return strings.ReplaceAll(q, ":tenant", "\n\t\tAND org_uid = :org_uid")
+}
@@ -0,0 +1,28 @@
+package db
+
+import "time"
+
+// APIKey represent the structure we need for moving data
+// between the app and the database.
+type APIKey struct {
+	ID         string     `db:"id"`
+	Prefix     string     `db:"prefix"`
+	Hash       string     `db:"hash"`
+	Name       string     `db:"name"`
+	Owner      string     `db:"owner"`
+	OrgUID     string     `db:"org_uid"`
+	Scopes     string     `db:"scopes"`
+	ExpiresOn  *time.Time `db:"expires_on"`
+	LastUsedOn *time.Time `db:"last_used_on"`
+	RevokedOn  *time.Time `db:"revoked_on"`
+	CreatedOn  time.Time  `db:"created_on"`
+	UpdatedOn  time.Time  `db:"updated_on"`
+}
+
+// Keyset returns the id and the sort value cursors are issued from.
+func (k APIKey) Keyset(sort string) (string, time.Time) {
+	if sort == "updated_on" {
+		return k.ID, k.UpdatedOn
+	}
+	return k.ID, k.CreatedOn
+}
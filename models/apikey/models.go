@@ -0,0 +1,129 @@
+package apikey
+
+import (
+	"strings"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/models/apikey/db"
+)
+
+// APIKey represents a key machine clients authenticate with. The secret
+// itself is never stored nor returned past its creation.
+//
+//swagger:model APIKey
+type APIKey struct {
+	// Primary Key
+	// type: integer
+	// example: 1
+	ID string `json:"id"`
+	// Public part of the key, used to tell keys apart
+	// example: 3f9a1c0e7b2d4a65
+	Prefix string `json:"prefix"`
+	// Clean name
+	// example: CI runner
+	Name string `json:"name"`
+	// Subject the key acts on behalf of
+	// example: ci-runner
+	Owner string `json:"owner"`
+	// Org the key belongs to
+	// example: 8d8ac610-566d-4ef0-9c22-186b2a5ed793
+	OrgUID string `json:"org_uid"`
+	// Scopes granted to the key
+	// example: ["build:write"]
+	Scopes []string `json:"scopes"`
+	// The key is rejected from this time on, it never expires when empty
+	// example: 2022-05-25T00:00:00Z
+	ExpiresOn *time.Time `json:"expires_on"`
+	// Last time the key authenticated a request, to the minute
+	// example: 2021-05-25T00:53:16Z
+	LastUsedOn *time.Time `json:"last_used_on"`
+	// Database created value
+	// example: 2021-05-25T00:53:16.535668Z
+	CreatedOn time.Time `json:"created_on"`
+	// Database last updated value
+	// example: 2021-05-25T00:53:16.535668Z
+	UpdatedOn time.Time `json:"updated_on"`
+}
+
+// CreatedAPIKey is an api key along with its secret, only returned when the
+// key is created or rotated.
+//
+//swagger:model CreatedAPIKey
+type CreatedAPIKey struct {
+	APIKey
+	// The key to send as "Authorization: ApiKey <key>", shown only once
+	// example: gr_3f9a1c0e7b2d4a65_8c1e0f...
+	Key string `json:"key"`
+}
+
+// NewAPIKey contains information needed to create a new api key.
+//
+//swagger:model NewAPIKey
+type NewAPIKey struct {
+	// Clear readable name
+	// in: string
+	// required: true
+	// example: CI runner
+	Name string `json:"name" validate:"required,notblank"`
+	// Subject the key acts on behalf of, the caller by default
+	// in: string
+	// example: ci-runner
+	Owner string `json:"owner" validate:"omitempty,notblank"`
+	// Scopes granted to the key
+	// in: array
+	// example: ["build:write"]
+	Scopes []string `json:"scopes" validate:"omitempty,dive,required,notblank"`
+	// The key is rejected from this time on, it never expires when empty
+	// in: string
+	// example: 2022-05-25T00:00:00Z
+	ExpiresOn *time.Time `json:"expires_on"`
+}
+
+func toAPIKey(dbK db.APIKey) APIKey {
+	return APIKey{
+		ID:         dbK.ID,
+		Prefix:     dbK.Prefix,
+		Name:       dbK.Name,
+		Owner:      dbK.Owner,
+		OrgUID:     dbK.OrgUID,
+		Scopes:     splitScopes(dbK.Scopes),
+		ExpiresOn:  dbK.ExpiresOn,
+		LastUsedOn: dbK.LastUsedOn,
+		CreatedOn:  dbK.CreatedOn,
+		UpdatedOn:  dbK.UpdatedOn,
+	}
+}
+
+func toAPIKeySlice(dbKs []db.APIKey) []APIKey {
+	ks := make([]APIKey, len(dbKs))
+	for i, dbK := range dbKs {
+		ks[i] = toAPIKey(dbK)
+	}
+	return ks
+}
+
+// splitScopes splits the stored comma separated scopes.
+func splitScopes(s string) []string {
+	scopes := []string{}
+	for _, scope := range strings.Split(s, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// joinScopes joins the scopes into the stored comma separated list.
+func joinScopes(scopes []string) string {
+	clean := make([]string, 0, len(scopes))
+	seen := make(map[string]bool)
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		clean = append(clean, scope)
+	}
+	return strings.Join(clean, ",")
+}
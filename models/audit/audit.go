@@ -0,0 +1,139 @@
+// Package audit records who changed what in the audit trail. Records are
+// written by the cores of the audited entities, in the transaction of the
+// change they describe.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/models/audit/db"
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Filters lists the query parameters audit records can be filtered by.
+var Filters = db.Filters
+
+// Fields lists the fields audit reads can select.
+var Fields = db.Fields
+
+// Core manages the set of APIs for audit access.
+type Core struct {
+	store db.Store
+}
+
+// NewCore constructs a core for audit api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB, rwmux *sync.RWMutex) Core {
+	return Core{
+		store: db.NewStore(log, sqlxDB, rwmux),
+	}
+}
+
+// Tran return new Core writing in the transaction.
+func (c Core) Tran(tx sqlx.ExtContext) Core {
+	return Core{
+		store: c.store.Tran(tx),
+	}
+}
+
+// -----------------------------------------------------------------------
+// CRUD Methods
+// -----------------------------------------------------------------------
+
+// Record writes what an action changed on an entity to the audit trail. The
+// before and after states are the json representations of the entity, nil
+// when it didn't exist. The actor and tracer uid come from the request, the
+// actor is the authenticated subject or else the user of the headers.
+func (c Core) Record(ctx context.Context, entity string, entityID string, action string, before interface{}, after interface{}, now time.Time) error {
+	changes, err := Diff(before, after)
+	if err != nil {
+		return fmt.Errorf("diffing %s id[%s]: %w", entity, entityID, err)
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("encoding %s id[%s] changes: %w", entity, entityID, err)
+	}
+
+	dbRec := db.Record{
+		Entity:    entity,
+		EntityID:  entityID,
+		Action:    action,
+		TracerUID: api.GetTracerUID(ctx),
+		Changes:   string(data),
+		CreatedOn: now,
+	}
+	if v, err := api.GetContextValues(ctx); err == nil {
+		dbRec.Actor = v.Subject
+		if dbRec.Actor == "" {
+			dbRec.Actor = v.UserUID
+		}
+	}
+
+	if _, err := c.store.Create(ctx, dbRec); err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+
+	return nil
+}
+
+// Query retrieves a list of existing records from the database
+func (c Core) Query(ctx context.Context, filter database.Filter, fs database.Fieldset, pagi database.Pagination) ([]Audit, database.Page, error) {
+	res, page, err := c.store.Query(ctx, filter, fs, pagi)
+	if err != nil {
+		return []Audit{}, database.Page{}, fmt.Errorf("query: %w", err)
+	}
+
+	return toAuditSlice(res), page, nil
+}
+
+// Diff returns the top level json fields that differ between two states
+// of an entity. Either state can be nil.
+func Diff(before interface{}, after interface{}) (map[string]Change, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]Change)
+	for k, bv := range b {
+		if av, ok := a[k]; !ok || !reflect.DeepEqual(bv, av) {
+			changes[k] = Change{Before: bv, After: a[k]}
+		}
+	}
+	for k, av := range a {
+		if _, ok := b[k]; !ok {
+			changes[k] = Change{After: av}
+		}
+	}
+
+	return changes, nil
+}
+
+// fields decodes the json representation of a state into its fields.
+func fields(state interface{}) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	if state == nil {
+		return m, nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
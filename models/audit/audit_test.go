@@ -0,0 +1,89 @@
+package audit_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chaitanyamaili/go_rest/models/audit"
+)
+
+// state is an entity the way it is audited.
+type state struct {
+	ID      string `json:"id"`
+	Label   string `json:"label"`
+	Version int    `json:"version"`
+	Note    string `json:"note,omitempty"`
+}
+
+func TestDiff(t *testing.T) {
+	v1 := state{ID: "1", Label: "build-1", Version: 1}
+	v2 := state{ID: "1", Label: "build-1-renamed", Version: 2, Note: "renamed"}
+
+	tests := []struct {
+		name   string
+		before interface{}
+		after  interface{}
+		want   map[string]audit.Change
+	}{
+		{
+			name:  "create",
+			after: v1,
+			want: map[string]audit.Change{
+				"id":      {After: "1"},
+				"label":   {After: "build-1"},
+				"version": {After: float64(1)},
+			},
+		},
+		{
+			name:   "update",
+			before: v1,
+			after:  v2,
+			want: map[string]audit.Change{
+				"label":   {Before: "build-1", After: "build-1-renamed"},
+				"version": {Before: float64(1), After: float64(2)},
+				"note":    {After: "renamed"},
+			},
+		},
+		{
+			name:   "field removed",
+			before: v2,
+			after:  v1,
+			want: map[string]audit.Change{
+				"label":   {Before: "build-1-renamed", After: "build-1"},
+				"version": {Before: float64(2), After: float64(1)},
+				"note":    {Before: "renamed"},
+			},
+		},
+		{
+			name:   "delete",
+			before: &v1,
+			want: map[string]audit.Change{
+				"id":      {Before: "1"},
+				"label":   {Before: "build-1"},
+				"version": {Before: float64(1)},
+			},
+		},
+		{
+			name:   "no change",
+			before: v1,
+			after:  &v1,
+			want:   map[string]audit.Change{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := audit.Diff(tt.before, tt.after)
+			if err != nil {
+				t.Fatalf("diff: %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := audit.Diff(make(chan int), nil); err == nil {
+		t.Error("state that is not json: got no error, want one")
+	}
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+	"github.com/chaitanyamaili/go_rest/pkg/validate"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store holds details for basic database needs
+type Store struct {
+	log          *zap.SugaredLogger
+	tr           database.Transactor
+	db           sqlx.ExtContext
+	rwmux        *sync.RWMutex
+	isWithinTran bool
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB, rwmux *sync.RWMutex) Store {
+	return Store{
+		log:   log,
+		tr:    db,
+		db:    db,
+		rwmux: rwmux,
+	}
+}
+
+// WithinTran runs passes function and do commit/rollback at the end.
+func (s Store) WithinTran(ctx context.Context, fn func(sqlx.ExtContext) error) error {
+	if s.isWithinTran {
+		return fn(s.db)
+	}
+	s.rwmux.Lock()
+	err := database.WithinTran(ctx, s.log, s.tr, fn)
+	s.rwmux.Unlock()
+
+	return err
+}
+
+// Tran return new Store with transaction in it.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log:          s.log,
+		tr:           s.tr,
+		db:           tx,
+		isWithinTran: true,
+	}
+}
+
+// -----------------------------------------------------------------------
+// Database Query Repository
+// -----------------------------------------------------------------------
+
+// Create inserts a new audit record into the database, owned by the tenant
+// of the request.
+func (s Store) Create(ctx context.Context, rec Record) (database.DBResults, error) {
+	const q = `
+	INSERT INTO audit_record
+		(entity, entity_id, action, actor, tracer_uid, org_uid, site_uid, changes, created_on)
+	VALUES
+		(:entity, :entity_id, :action, :actor, :tracer_uid, :org_uid, :site_uid, :changes, :created_on)`
+
+	res, err := database.NamedInsertContext(ctx, s.log, s.db, q, database.TenantFrom(ctx).Data(rec))
+	if err != nil {
+		return database.DBResults{}, fmt.Errorf("inserting audit record: %w", err)
+	}
+
+	return res, nil
+}
+
+// Fields lists the fields audit reads can select.
+var Fields = database.Fields{
+	Columns: map[string]string{
+		"id":         "id",
+		"entity":     "entity",
+		"entity_id":  "entity_id",
+		"action":     "action",
+		"actor":      "actor",
+		"tracer_uid": "tracer_uid",
+		"org_uid":    "org_uid",
+		"site_uid":   "site_uid",
+		"changes":    "changes",
+		"created_on": "created_on",
+	},
+}
+
+// Filters lists the query parameters audit records can be filtered by.
+var Filters = map[string]database.FilterField{
+	"entity":         {Column: "entity", Multi: true, Check: validate.CheckSlug},
+	"entity_id":      {Column: "entity_id", Multi: true},
+	"action":         {Column: "action", Multi: true, Check: validate.CheckSlug},
+	"actor":          {Column: "actor", Prefix: true},
+	"tracer_uid":     {Column: "tracer_uid"},
+	"created_after":  {Column: "created_on", Op: database.FilterAfter, Type: database.FilterTime},
+	"created_before": {Column: "created_on", Op: database.FilterBefore, Type: database.FilterTime},
+}
+
+// Query retrieves a page of the audit records of the tenant from the
+// database. Records are only ever sorted by when they were written.
+func (s Store) Query(ctx context.Context, filter database.Filter, fs database.Fieldset, pagi database.Pagination) ([]Record, database.Page, error) {
+	if pagi.Sort != "id" {
+		pagi.Sort = "created_on"
+	}
+
+	t := database.TenantFrom(ctx)
+	q := database.PaginationQuery(pagi, database.KeysetQuery(pagi, "", `
+	SELECT
+		:columns
+	FROM
+		audit_record
+	WHERE
+		1 = 1:tenant:filters:keyset
+	ORDER BY
+		:sort :direction,
+		id :direction
+	LIMIT
+		:per_page OFFSET :page`))
+
+	const qc = `
+	SELECT
+		COUNT(*) AS total
+	FROM
+		audit_record
+	WHERE
+		1 = 1:tenant:filters`
+
+	pq := database.PageQuery{
+		Query:    database.FieldsQuery(Fields, fs, database.TenantQuery(t, "", database.FilterQuery(filter, q)), "id", pagi.Sort),
+		Count:    database.TenantQuery(t, "", database.FilterQuery(filter, qc)),
+		Table:    "audit_record",
+		Filtered: !filter.Empty() || !t.All,
+	}
+
+	// Slice to hold results
+	var res []Record
+	page, err := database.NamedQueryPage(ctx, s.log, s.db, pq, pagi, t.Data(filter.Data(pagi)), &res)
+	if err != nil {
+		return nil, database.Page{}, fmt.Errorf("selecting audit records: %w", err)
+	}
+
+	return res, page, nil
+}
@@ -0,0 +1,23 @@
+package db
+
+import "time"
+
+// Record represent the structure we need for moving data
+// between the app and the database.
+type Record struct {
+	ID        string    `db:"id"`
+	Entity    string    `db:"entity"`
+	EntityID  string    `db:"entity_id"`
+	Action    string    `db:"action"`
+	Actor     string    `db:"actor"`
+	TracerUID string    `db:"tracer_uid"`
+	OrgUID    string    `db:"org_uid"`
+	SiteUID   string    `db:"site_uid"`
+	Changes   string    `db:"changes"`
+	CreatedOn time.Time `db:"created_on"`
+}
+
+// Keyset returns the id and the sort value cursors are issued from.
+func (r Record) Keyset(sort string) (string, time.Time) {
+	return r.ID, r.CreatedOn
+}
@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/models/audit/db"
+)
+
+// Set of actions recorded in the audit trail.
+const (
+	ActionCreate   = "create"
+	ActionUpdate   = "update"
+	ActionDelete   = "delete"
+	ActionUnDelete = "undelete"
+)
+
+// Set of entities recorded in the audit trail.
+const (
+	EntityBuild       = "build"
+	EntityBuildStatus = "build_status"
+)
+
+// Audit is a single change made to an entity.
+//
+//swagger:model Audit
+type Audit struct {
+	// Primary Key
+	// type: integer
+	// example: 1
+	ID string `json:"id"`
+	// Kind of entity that was changed
+	// example: build
+	Entity string `json:"entity"`
+	// ID of the entity that was changed
+	// example: 4
+	EntityID string `json:"entity_id"`
+	// One of create, update, delete or undelete
+	// example: update
+	Action string `json:"action"`
+	// Subject of the caller that made the change, or the user_uid header
+	// when callers are not authenticated
+	// example: user-1
+	Actor string `json:"actor"`
+	// Tracer uid of the request that made the change
+	// example: 0f8fad5b-d9cb-469f-a165-70867728950e
+	TracerUID string `json:"tracer_uid"`
+	// Org the change was made in
+	// example: 8d8ac610-566d-4ef0-9c22-186b2a5ed793
+	OrgUID string `json:"org_uid"`
+	// Site the change was made in
+	// example: 4b4d4a3e-8f2b-4f5c-9a0d-3a1b2c3d4e5f
+	SiteUID string `json:"site_uid"`
+	// Fields that changed with their value before and after the change
+	// example: {"label": {"before": "nightly", "after": "release"}}
+	Changes map[string]Change `json:"changes"`
+	// Database created value
+	// example: 2021-05-25T00:53:16.535668Z
+	CreatedOn time.Time `json:"created_on"`
+}
+
+// Change holds the value of a field before and after a change. Before is
+// null for created fields and after is null for removed ones.
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+func toAudit(dbRec db.Record) Audit {
+	a := Audit{
+		ID:        dbRec.ID,
+		Entity:    dbRec.Entity,
+		EntityID:  dbRec.EntityID,
+		Action:    dbRec.Action,
+		Actor:     dbRec.Actor,
+		TracerUID: dbRec.TracerUID,
+		OrgUID:    dbRec.OrgUID,
+		SiteUID:   dbRec.SiteUID,
+		CreatedOn: dbRec.CreatedOn,
+	}
+
+	// The changes are written by Record, a record that can't be read back
+	// is shown without them rather than failing the whole page.
+	if dbRec.Changes != "" {
+		_ = json.Unmarshal([]byte(dbRec.Changes), &a.Changes)
+	}
+
+	return a
+}
+
+func toAuditSlice(dbRecs []db.Record) []Audit {
+	recs := make([]Audit, len(dbRecs))
+	for i, dbRec := range dbRecs {
+		recs[i] = toAudit(dbRec)
+	}
+	return recs
+}
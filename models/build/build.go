@@ -8,7 +8,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/chaitanyamaili/go_rest/models/audit"
 	"github.com/chaitanyamaili/go_rest/models/build/db"
+	"github.com/chaitanyamaili/go_rest/models/buildstatus"
+	"github.com/chaitanyamaili/go_rest/models/webhook"
+	"github.com/chaitanyamaili/go_rest/pkg/api"
 	"github.com/chaitanyamaili/go_rest/pkg/database"
 	"github.com/chaitanyamaili/go_rest/pkg/validate"
 	"github.com/google/uuid"
@@ -18,20 +22,38 @@ import (
 
 // Set of error variables for CRUD operations.
 var (
-	ErrNotFound     = errors.New("build not found")
-	ErrInvalidID    = errors.New("ID is not in its proper form")
-	ErrInvalidAlias = errors.New("alias is not in its proper form")
+	ErrNotFound      = errors.New("build not found")
+	ErrInvalidID     = errors.New("ID is not in its proper form")
+	ErrInvalidAlias  = errors.New("alias is not in its proper form")
+	ErrInvalidStatus = errors.New("build status does not exist")
+
+	ErrPreconditionFailed = errors.New("build does not match the If-Match etag")
+	ErrConflict           = errors.New("build was changed by another request")
+
+	ErrInvalidTransition = buildstatus.ErrInvalidTransition
 )
 
+// Filters lists the query parameters builds can be filtered by.
+var Filters = db.Filters
+
+// Fields lists the fields and relations build reads can select.
+var Fields = db.Fields
+
 // Core manages the set of APIs for requesting source access
 type Core struct {
-	store db.Store
+	store   db.Store
+	status  buildstatus.Core
+	audit   audit.Core
+	webhook webhook.Core
 }
 
 // NewCore constructs a core for requesting source api access.
 func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB, rwmux *sync.RWMutex) Core {
 	return Core{
-		store: db.NewStore(log, sqlxDB, rwmux),
+		store:   db.NewStore(log, sqlxDB, rwmux),
+		status:  buildstatus.NewCore(log, sqlxDB, rwmux),
+		audit:   audit.NewCore(log, sqlxDB, rwmux),
+		webhook: webhook.NewCore(log, sqlxDB, rwmux),
 	}
 }
 
@@ -45,26 +67,48 @@ func (c Core) Create(ctx context.Context, rs NewBuild, now time.Time) (Build, er
 		return Build{}, err
 	}
 
+	t := database.TenantFrom(ctx)
 	dbRS := db.Build{
-		UUID:          uuid.New().String(),
-		Label:         strings.TrimSpace(rs.Label),
-		CommitSha:     strings.TrimSpace(rs.CommitSha),
-		BuildStatusID: strings.TrimSpace(rs.BuildStatusID),
-		CreatedOn:     now,
-		UpdatedOn:     now,
+		UUID:      uuid.New().String(),
+		Label:     strings.TrimSpace(rs.Label),
+		CommitSha: strings.TrimSpace(rs.CommitSha),
+		OrgUID:    t.OrgUID,
+		SiteUID:   t.SiteUID,
+		Version:   1,
+		CreatedOn: now,
+		UpdatedOn: now,
 	}
 
+	// The status is resolved in the transaction so a status deleted in the
+	// meantime can't be used. Its error is returned as is to the caller.
+	var rejected error
+
 	// This provides an example of how to execute a transaction if required.
 	tran := func(tx sqlx.ExtContext) error {
+		bs, err := resolveStatus(ctx, c.status.Tran(tx), strings.TrimSpace(rs.BuildStatusID), strings.TrimSpace(rs.BuildStatusAlias))
+		if err != nil {
+			rejected = err
+			return err
+		}
+		dbRS.BuildStatusID = bs.ID
+		dbRS.Status = db.Status{Alias: bs.Alias, Name: bs.Name}
+
 		res, err := c.store.Tran(tx).Create(ctx, dbRS)
 		if err != nil {
 			return fmt.Errorf("create: %w", err)
 		}
 		dbRS.ID = fmt.Sprintf("%d", res.LastInsertID)
-		return nil
+
+		if err := c.audit.Tran(tx).Record(ctx, audit.EntityBuild, dbRS.ID, audit.ActionCreate, nil, toStatus(dbRS), now); err != nil {
+			return err
+		}
+		return c.webhook.Tran(tx).Publish(ctx, webhook.EventBuildCreated, toStatus(dbRS), now)
 	}
 
 	if err := c.store.WithinTran(ctx, tran); err != nil {
+		if rejected != nil {
+			return Build{}, rejected
+		}
 		return Build{}, fmt.Errorf("tran: %w", err)
 	}
 
@@ -72,7 +116,7 @@ func (c Core) Create(ctx context.Context, rs NewBuild, now time.Time) (Build, er
 }
 
 // Update replaces a requesting source document in the database.
-func (c Core) Update(ctx context.Context, id string, urs UpdateBuild, now time.Time) error {
+func (c Core) Update(ctx context.Context, id string, urs UpdateBuild, pre api.Precondition, now time.Time) error {
 	if err := validate.Check(urs); err != nil {
 		return err
 	}
@@ -80,13 +124,17 @@ func (c Core) Update(ctx context.Context, id string, urs UpdateBuild, now time.T
 		return ErrInvalidID
 	}
 
-	dbRS, err := c.store.QueryByID(ctx, id)
+	dbRS, err := c.store.QueryByID(ctx, id, database.Fieldset{})
 	if err != nil {
 		if errors.Is(err, database.ErrDBNotFound) {
 			return ErrNotFound
 		}
-		return fmt.Errorf("updating status id[%s]: %w", id, err)
+		return fmt.Errorf("updating build id[%s]: %w", id, err)
 	}
+	if !pre.Matches(api.ETag(dbRS.ID, dbRS.Version)) {
+		return ErrPreconditionFailed
+	}
+	before := toStatus(dbRS)
 
 	hasChanges := false
 	if urs.Label != nil {
@@ -97,8 +145,8 @@ func (c Core) Update(ctx context.Context, id string, urs UpdateBuild, now time.T
 		dbRS.CommitSha = strings.TrimSpace(*urs.CommitSha)
 		hasChanges = true
 	}
-	if urs.BuildStatusID != nil {
-		dbRS.BuildStatusID = strings.TrimSpace(*urs.BuildStatusID)
+	moveStatus := urs.BuildStatusID != nil || urs.BuildStatusAlias != nil
+	if moveStatus {
 		hasChanges = true
 	}
 	// No changes were made - don't touch the DB
@@ -107,75 +155,192 @@ func (c Core) Update(ctx context.Context, id string, urs UpdateBuild, now time.T
 	}
 	dbRS.UpdatedOn = now
 
-	_, err = c.store.Update(ctx, dbRS)
-	if err != nil {
-		return fmt.Errorf("update id[%s]: %w", id, err)
+	// The statuses are read in the transaction so the transition is checked
+	// against the graph the build is written with. Their errors are returned
+	// as is to the caller.
+	var rejected error
+
+	tran := func(tx sqlx.ExtContext) error {
+		if moveStatus {
+			var statusID, alias string
+			if urs.BuildStatusID != nil {
+				statusID = strings.TrimSpace(*urs.BuildStatusID)
+			}
+			if urs.BuildStatusAlias != nil {
+				alias = strings.TrimSpace(*urs.BuildStatusAlias)
+			}
+
+			bs, err := resolveStatus(ctx, c.status.Tran(tx), statusID, alias)
+			if err != nil {
+				rejected = err
+				return err
+			}
+
+			// The current status of the build is joined in again, its
+			// transitions may have changed since the build was read.
+			fresh, err := c.store.Tran(tx).QueryByID(ctx, id, database.Fieldset{})
+			if err != nil {
+				if errors.Is(err, database.ErrDBNotFound) {
+					rejected = ErrNotFound
+				}
+				return fmt.Errorf("updating build id[%s]: %w", id, err)
+			}
+			current := buildstatus.BuildStatus{
+				ID:          fresh.BuildStatusID,
+				Alias:       fresh.Status.Alias,
+				IsTerminal:  fresh.Status.IsTerminal,
+				AllowedNext: buildstatus.SplitAliases(fresh.Status.AllowedNext),
+			}
+			if err := current.CheckTransition(bs); err != nil {
+				rejected = err
+				return err
+			}
+
+			dbRS.BuildStatusID = bs.ID
+			dbRS.Status = db.Status{Alias: bs.Alias, Name: bs.Name}
+		}
+
+		res, err := c.store.Tran(tx).Update(ctx, dbRS)
+		if err != nil {
+			return fmt.Errorf("update id[%s]: %w", id, err)
+		}
+		if res.AffectedRows == 0 {
+			return modifiedError(pre)
+		}
+		dbRS.Version++
+		after := toStatus(dbRS)
+
+		if err := c.audit.Tran(tx).Record(ctx, audit.EntityBuild, id, audit.ActionUpdate, before, after, now); err != nil {
+			return err
+		}
+
+		hooks := c.webhook.Tran(tx)
+		if err := hooks.Publish(ctx, webhook.EventBuildUpdated, after, now); err != nil {
+			return err
+		}
+		if after.BuildStatusID != before.BuildStatusID {
+			return hooks.Publish(ctx, webhook.EventBuildStatusChanged, after, now)
+		}
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		if rejected != nil {
+			return rejected
+		}
+		return fmt.Errorf("tran: %w", err)
 	}
 
 	return nil
 }
 
 // Delete removes a requesting source from the database.
-func (c Core) Delete(ctx context.Context, id string, now time.Time) error {
+func (c Core) Delete(ctx context.Context, id string, pre api.Precondition, now time.Time) error {
 	if err := validate.CheckID(id); err != nil {
 		return ErrInvalidID
 	}
 
-	_, err := c.store.QueryByID(ctx, id)
+	dbRS, err := c.store.QueryByID(ctx, id, database.Fieldset{})
 	if err != nil {
 		if errors.Is(err, database.ErrDBNotFound) {
 			return ErrNotFound
 		}
-		return fmt.Errorf("undeleting status id[%s]: %w", id, err)
+		return fmt.Errorf("deleting build id[%s]: %w", id, err)
+	}
+	if !pre.Matches(api.ETag(dbRS.ID, dbRS.Version)) {
+		return ErrPreconditionFailed
 	}
 
-	_, err = c.store.Delete(ctx, id, now)
-	if err != nil {
-		return fmt.Errorf("delete id[%s]: %w", id, err)
+	tran := func(tx sqlx.ExtContext) error {
+		res, err := c.store.Tran(tx).Delete(ctx, id, dbRS.Version, now)
+		if err != nil {
+			return fmt.Errorf("delete id[%s]: %w", id, err)
+		}
+		if res.AffectedRows == 0 {
+			return modifiedError(pre)
+		}
+
+		before := toStatus(dbRS)
+		dbRS.DeletedOn = &now
+		dbRS.Version++
+
+		if err := c.audit.Tran(tx).Record(ctx, audit.EntityBuild, id, audit.ActionDelete, before, toStatus(dbRS), now); err != nil {
+			return err
+		}
+		return c.webhook.Tran(tx).Publish(ctx, webhook.EventBuildDeleted, toStatus(dbRS), now)
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return fmt.Errorf("tran: %w", err)
 	}
 
 	return nil
 }
 
-// UnDelete removes a requesting source from the database.
-func (c Core) UnDelete(ctx context.Context, id string) (Build, error) {
+// UnDelete restores a soft deleted requesting source. Restoring one that
+// isn't deleted changes nothing.
+func (c Core) UnDelete(ctx context.Context, id string, now time.Time) (Build, error) {
 	if err := validate.CheckID(id); err != nil {
 		return Build{}, ErrInvalidID
 	}
 
-	_, err := c.store.UnDelete(ctx, id)
-	if err != nil {
-		return Build{}, fmt.Errorf("undelete: %w", err)
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		dbRS, err := store.QueryDeletedByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				return nil
+			}
+			return fmt.Errorf("undeleting build id[%s]: %w", id, err)
+		}
+
+		if _, err := store.UnDelete(ctx, id); err != nil {
+			return fmt.Errorf("undelete: %w", err)
+		}
+
+		before := toStatus(dbRS)
+		dbRS.DeletedOn = nil
+		dbRS.Version++
+
+		if err := c.audit.Tran(tx).Record(ctx, audit.EntityBuild, id, audit.ActionUnDelete, before, toStatus(dbRS), now); err != nil {
+			return err
+		}
+		return c.webhook.Tran(tx).Publish(ctx, webhook.EventBuildUnDeleted, toStatus(dbRS), now)
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return Build{}, fmt.Errorf("tran: %w", err)
 	}
 
-	dbRS, err := c.store.QueryByID(ctx, id)
+	dbRS, err := c.store.QueryByID(ctx, id, database.Fieldset{})
 	if err != nil {
 		if errors.Is(err, database.ErrDBNotFound) {
 			return Build{}, ErrNotFound
 		}
-		return Build{}, fmt.Errorf("undeleting status id[%s]: %w", id, err)
+		return Build{}, fmt.Errorf("undeleting build id[%s]: %w", id, err)
 	}
 
 	return toStatus(dbRS), nil
 }
 
 // Query retrieves a list of existing records from the database
-func (c Core) Query(ctx context.Context, pagi database.Pagination) ([]Build, error) {
-	res, err := c.store.Query(ctx, pagi)
+func (c Core) Query(ctx context.Context, filter database.Filter, fs database.Fieldset, pagi database.Pagination) ([]Build, database.Page, error) {
+	res, page, err := c.store.Query(ctx, filter, fs, pagi)
 	if err != nil {
-		return []Build{}, fmt.Errorf("query: %w", err)
+		return []Build{}, database.Page{}, fmt.Errorf("query: %w", err)
 	}
 
-	return toStatusSlice(res), nil
+	return toStatusSlice(res), page, nil
 }
 
 // QueryByID retrieves a single records from the database by id
-func (c Core) QueryByID(ctx context.Context, id string) (Build, error) {
+func (c Core) QueryByID(ctx context.Context, id string, fs database.Fieldset) (Build, error) {
 	if err := validate.CheckID(id); err != nil {
 		return Build{}, ErrInvalidID
 	}
 
-	res, err := c.store.QueryByID(ctx, id)
+	res, err := c.store.QueryByID(ctx, id, fs)
 	if err != nil {
 		if errors.Is(err, database.ErrDBNotFound) {
 			return Build{}, ErrNotFound
@@ -202,3 +367,45 @@ func (c Core) QueryByAlias(ctx context.Context, alias string) (Build, error) {
 
 	return toStatus(res), nil
 }
+
+// resolveStatus looks up the build status a build is moved into, either by
+// id or by alias, through the statuses given. Missing and soft deleted
+// statuses are rejected, as is an id that doesn't match the alias when both
+// are given.
+func resolveStatus(ctx context.Context, statuses buildstatus.Core, id string, alias string) (buildstatus.BuildStatus, error) {
+	var (
+		bs  buildstatus.BuildStatus
+		err error
+	)
+	if alias != "" {
+		bs, err = statuses.QueryByAlias(ctx, alias, database.Fieldset{})
+	} else {
+		bs, err = statuses.QueryByID(ctx, id, database.Fieldset{})
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, buildstatus.ErrNotFound),
+			errors.Is(err, buildstatus.ErrInvalidID),
+			errors.Is(err, buildstatus.ErrInvalidAlias):
+			return buildstatus.BuildStatus{}, ErrInvalidStatus
+		default:
+			return buildstatus.BuildStatus{}, fmt.Errorf("resolving build status: %w", err)
+		}
+	}
+
+	if id != "" && alias != "" && id != bs.ID {
+		return buildstatus.BuildStatus{}, fmt.Errorf("%w: id[%s] is not %q", ErrInvalidStatus, id, alias)
+	}
+
+	return bs, nil
+}
+
+// modifiedError returns the error of a build that changed between the time
+// it was read and written. The If-Match etag of the request no longer holds
+// if there was one, the request can be retried otherwise.
+func modifiedError(pre api.Precondition) error {
+	if pre.Empty() {
+		return ErrConflict
+	}
+	return ErrPreconditionFailed
+}
@@ -4,11 +4,11 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/chaitanyamaili/go_rest/pkg/database"
+	"github.com/chaitanyamaili/go_rest/pkg/validate"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
@@ -58,19 +58,23 @@ func (s Store) Tran(tx sqlx.ExtContext) Store {
 // Database Query Repository
 // -----------------------------------------------------------------------
 
-// Create inserts a new requesting into the database.
+// Create inserts a new requesting into the database, owned by the tenant of
+// the request.
 func (s Store) Create(ctx context.Context, rs Build) (database.DBResults, error) {
 	const q = `
 	INSERT INTO build
-		(uuid, label, commit_sha, build_status_id, created_on, updated_on)
+		(uuid, label, commit_sha, build_status_id, org_uid, site_uid, created_on, updated_on)
 	VALUES
-		(:uuid, :label, :commit_sha, :build_status_id, :created_on, :updated_on)`
+		(:uuid, :label, :commit_sha, :build_status_id, :org_uid, :site_uid, :created_on, :updated_on)`
 
-	res, err := database.NamedExecContext(ctx, s.log, s.db, q, rs)
+	res, err := database.NamedInsertContext(ctx, s.log, s.db, q, database.TenantFrom(ctx).Data(rs))
 	if err != nil {
-		if strings.Contains(err.Error(), "Duplicate entry") {
+		if database.IsDuplicateEntry(err) {
 			return database.DBResults{}, database.NewError(database.ErrDBDuplicatedEntry, http.StatusConflict)
 		}
+		if database.IsForeignKeyViolation(err) {
+			return database.DBResults{}, database.NewError(database.ErrDBInvalidRef, http.StatusBadRequest)
+		}
 
 		return database.DBResults{}, fmt.Errorf("inserting requesting source: %w", err)
 	}
@@ -78,49 +82,62 @@ func (s Store) Create(ctx context.Context, rs Build) (database.DBResults, error)
 	return res, nil
 }
 
-// Update replaces a requesting source record in the database.
+// Update replaces a requesting source record in the database, as long as
+// it is still at the version it was read at. Nothing is affected otherwise.
 func (s Store) Update(ctx context.Context, rs Build) (database.DBResults, error) {
-	const q = `
+	t := database.TenantFrom(ctx)
+	q := database.TenantQuery(t, "", `
 	UPDATE
-		status
-	SET 
-		alias = :alias,
+		build
+	SET
 		label = :label,
-		description = :description,
-		updated_on = :updated_on
+		commit_sha = :commit_sha,
+		build_status_id = :build_status_id,
+		updated_on = :updated_on,
+		version = version + 1
 	WHERE
-		id = :id`
+		id = :id
+		AND version = :version:tenant`)
 
-	res, err := database.NamedExecContext(ctx, s.log, s.db, q, rs)
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, t.Data(rs))
 	if err != nil {
-		if strings.Contains(err.Error(), "Duplicate entry") {
+		if database.IsDuplicateEntry(err) {
 			return database.DBResults{}, database.NewError(database.ErrDBDuplicatedEntry, http.StatusConflict)
 		}
+		if database.IsForeignKeyViolation(err) {
+			return database.DBResults{}, database.NewError(database.ErrDBInvalidRef, http.StatusBadRequest)
+		}
 		return database.DBResults{}, fmt.Errorf("updating Requesting Source ID[%s]: %w", rs.ID, err)
 	}
 
 	return res, nil
 }
 
-// Delete removes a requesting source from the database.
-func (s Store) Delete(ctx context.Context, id string, now time.Time) (database.DBResults, error) {
+// Delete removes a requesting source from the database, as long as it is
+// still at the version it was read at. Nothing is affected otherwise.
+func (s Store) Delete(ctx context.Context, id string, version int, now time.Time) (database.DBResults, error) {
 	data := struct {
 		ID        string    `db:"id"`
+		Version   int       `db:"version"`
 		DeletedOn time.Time `db:"deleted_on"`
 	}{
 		ID:        id,
+		Version:   version,
 		DeletedOn: now,
 	}
 
-	const q = `
+	t := database.TenantFrom(ctx)
+	q := database.TenantQuery(t, "", `
 	UPDATE
 		build
 	SET
-		deleted_on = :deleted_on
+		deleted_on = :deleted_on,
+		version = version + 1
 	WHERE
-		id = :id`
+		id = :id
+		AND version = :version:tenant`)
 
-	res, err := database.NamedExecContext(ctx, s.log, s.db, q, data)
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, t.Data(data))
 	if err != nil {
 		return database.DBResults{}, fmt.Errorf("deleting requesting source id[%s]: %w", id, err)
 	}
@@ -134,15 +151,17 @@ func (s Store) UnDelete(ctx context.Context, id string) (database.DBResults, err
 		ID string `db:"id"`
 	}{ID: id}
 
-	const q = `
+	t := database.TenantFrom(ctx)
+	q := database.TenantQuery(t, "", `
 	UPDATE
 		build
 	SET
-		deleted_on = null
+		deleted_on = null,
+		version = version + 1
 	WHERE
-		id = :id`
+		id = :id:tenant`)
 
-	res, err := database.NamedExecContext(ctx, s.log, s.db, q, data)
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, t.Data(data))
 	if err != nil {
 		return database.DBResults{}, fmt.Errorf("undeleting requesting source id[%s]: %w", id, err)
 	}
@@ -150,69 +169,138 @@ func (s Store) UnDelete(ctx context.Context, id string) (database.DBResults, err
 	return res, nil
 }
 
-// Query retrieves a list of existing requesting source from the database.
-func (s Store) Query(ctx context.Context, pagi database.Pagination) ([]Build, error) {
-	q := database.PaginationQuery(pagi, `
+// Fields lists the fields and relations build reads can select.
+var Fields = database.Fields{
+	Columns: map[string]string{
+		"id":              "b.id",
+		"uuid":            "b.uuid",
+		"label":           "b.label",
+		"commit_sha":      "b.commit_sha",
+		"build_status_id": "b.build_status_id",
+		"org_uid":         "b.org_uid",
+		"site_uid":        "b.site_uid",
+		"created_on":      "b.created_on",
+		"updated_on":      "b.updated_on",
+		"version":         "b.version",
+		"deleted_on":      "b.deleted_on",
+	},
+	Includes: map[string][]string{
+		"status": {
+			`bs.alias AS "status.alias"`,
+			`bs.name AS "status.name"`,
+			`bs.is_terminal AS "status.is_terminal"`,
+			`bs.allowed_next AS "status.allowed_next"`,
+		},
+	},
+}
+
+// Filters lists the query parameters builds can be filtered by.
+var Filters = map[string]database.FilterField{
+	"label":          {Column: "b.label", Prefix: true, Multi: true},
+	"commit_sha":     {Column: "b.commit_sha", Prefix: true},
+	"status":         {Column: "bs.alias", Multi: true, Check: validate.CheckSlug},
+	"created_after":  {Column: "b.created_on", Op: database.FilterAfter, Type: database.FilterTime},
+	"created_before": {Column: "b.created_on", Op: database.FilterBefore, Type: database.FilterTime},
+	"updated_after":  {Column: "b.updated_on", Op: database.FilterAfter, Type: database.FilterTime},
+}
+
+// Query retrieves a page of the existing builds of the tenant from the
+// database.
+func (s Store) Query(ctx context.Context, filter database.Filter, fs database.Fieldset, pagi database.Pagination) ([]Build, database.Page, error) {
+	t := database.TenantFrom(ctx)
+	q := database.PaginationQuery(pagi, database.KeysetQuery(pagi, "b", `
 	SELECT
-		id,
-		uuid,
-	    label,
-	    commit_sha,
-	    build_status_id,
-	    created_on,
-	    updated_on,
-	    deleted_on
+		:columns
 	FROM
-		build
+		build b
+		JOIN build_status bs ON bs.id = b.build_status_id
 	WHERE
-		deleted_on is null
+		b.deleted_on is null:tenant:filters:keyset
 	ORDER BY
-		:sort :direction,
-		id :direction
+		b.:sort :direction,
+		b.id :direction
 	LIMIT
-		:page,:per_page`)
+		:per_page OFFSET :page`))
+
+	const qc = `
+	SELECT
+		COUNT(*) AS total
+	FROM
+		build b
+		JOIN build_status bs ON bs.id = b.build_status_id
+	WHERE
+		b.deleted_on is null:tenant:filters`
+
+	pq := database.PageQuery{
+		Query:    database.FieldsQuery(Fields, fs, database.TenantQuery(t, "b", database.FilterQuery(filter, q)), "id", pagi.Sort),
+		Count:    database.TenantQuery(t, "b", database.FilterQuery(filter, qc)),
+		Table:    "build",
+		Filtered: !filter.Empty() || !t.All,
+	}
 
 	// Slice to hold results
 	var res []Build
-	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, pagi, &res); err != nil {
+	page, err := database.NamedQueryPage(ctx, s.log, s.db, pq, pagi, t.Data(filter.Data(pagi)), &res)
+	if err != nil {
+		return nil, database.Page{}, fmt.Errorf("selecting builds: %w", err)
+	}
+
+	return res, page, nil
+}
+
+// QueryByID retrieves a list of existing requesting sources from the database.
+func (s Store) QueryByID(ctx context.Context, id string, fs database.Fieldset) (Build, error) {
+	data := struct {
+		ID string `db:"id"`
+	}{ID: id}
+	t := database.TenantFrom(ctx)
+	q := database.FieldsQuery(Fields, fs, database.TenantQuery(t, "b", `
+	SELECT
+		:columns
+	FROM
+		build b
+		JOIN build_status bs ON bs.id = b.build_status_id
+	WHERE
+		b.id = :id
+		and b.deleted_on is null:tenant`), "id", "version")
+
+	// Slice to hold results
+	var res Build
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, t.Data(data), &res); err != nil {
+		// Empty Check (no results)
 		if database.IsError(err) && err.Error() == database.ErrDBNotFound.Error() {
-			return []Build{}, database.ErrDBNotFound
+			return Build{}, database.ErrDBNotFound
 		}
-		return nil, fmt.Errorf("selecting requesting source: %w", err)
+		return Build{}, fmt.Errorf("selecting status by ID[%q]: %w", id, err)
 	}
 
 	return res, nil
 }
 
-// QueryByID retrieves a list of existing requesting sources from the database.
-func (s Store) QueryByID(ctx context.Context, id string) (Build, error) {
+// QueryDeletedByID retrieves a soft deleted build from the database.
+func (s Store) QueryDeletedByID(ctx context.Context, id string) (Build, error) {
 	data := struct {
 		ID string `db:"id"`
 	}{ID: id}
-	const q = `
+	t := database.TenantFrom(ctx)
+	q := database.FieldsQuery(Fields, database.Fieldset{}, database.TenantQuery(t, "b", `
 	SELECT
-		id,
-		uuid,
-		label,
-		commit_sha,
-		build_status_id,
-		created_on,
-		updated_on,
-		deleted_on
+		:columns
 	FROM
-		build
+		build b
+		JOIN build_status bs ON bs.id = b.build_status_id
 	WHERE
-		id = :id
-		and deleted_on is null`
+		b.id = :id
+		and b.deleted_on is not null:tenant`), "id", "version")
 
 	// Slice to hold results
 	var res Build
-	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &res); err != nil {
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, t.Data(data), &res); err != nil {
 		// Empty Check (no results)
 		if database.IsError(err) && err.Error() == database.ErrDBNotFound.Error() {
 			return Build{}, database.ErrDBNotFound
 		}
-		return Build{}, fmt.Errorf("selecting status by ID[%q]: %w", id, err)
+		return Build{}, fmt.Errorf("selecting deleted build by ID[%q]: %w", id, err)
 	}
 
 	return res, nil
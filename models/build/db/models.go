@@ -10,7 +10,27 @@ type Build struct {
 	Label         string     `db:"label"`
 	CommitSha     string     `db:"commit_sha"`
 	BuildStatusID string     `db:"build_status_id"`
+	OrgUID        string     `db:"org_uid"`
+	SiteUID       string     `db:"site_uid"`
+	Status        Status     `db:"status"`
+	Version       int        `db:"version"`
 	CreatedOn     time.Time  `db:"created_on"`
 	UpdatedOn     time.Time  `db:"updated_on"`
 	DeletedOn     *time.Time `db:"deleted_on"`
 }
+
+// Status represent the build status columns joined to a build.
+type Status struct {
+	Alias       string `db:"alias"`
+	Name        string `db:"name"`
+	IsTerminal  bool   `db:"is_terminal"`
+	AllowedNext string `db:"allowed_next"`
+}
+
+// Keyset returns the id and the sort value cursors are issued from.
+func (b Build) Keyset(sort string) (string, time.Time) {
+	if sort == "updated_on" {
+		return b.ID, b.UpdatedOn
+	}
+	return b.ID, b.CreatedOn
+}
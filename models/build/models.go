@@ -4,9 +4,9 @@ import (
 	"context"
 	"fmt"
 	"time"
-	"unsafe"
 
 	"github.com/chaitanyamaili/go_rest/models/build/db"
+	"github.com/chaitanyamaili/go_rest/pkg/api"
 )
 
 // Build represents the state of the current session
@@ -29,6 +29,17 @@ type Build struct {
 	// Clean status id
 	// example: Build status id
 	BuildStatusID string `json:"build_status_id"`
+	// Resolved build status
+	Status Status `json:"status"`
+	// Org the build belongs to
+	// example: 8d8ac610-566d-4ef0-9c22-186b2a5ed793
+	OrgUID string `json:"org_uid"`
+	// Site the build belongs to
+	// example: 4b4d4a3e-8f2b-4f5c-9a0d-3a1b2c3d4e5f
+	SiteUID string `json:"site_uid"`
+	// Incremented on every change, the ETag is derived from it
+	// example: 1
+	Version int `json:"version"`
 	// Database created value
 	// example: 2021-05-25T00:53:16.535668Z
 	CreatedOn time.Time `json:"created_on"`
@@ -41,6 +52,18 @@ type Build struct {
 	DeletedOn *time.Time `json:"deleted_on,omitempty"`
 }
 
+// Status is the summary of the build status a build is in.
+//
+//swagger:model BuildStatusSummary
+type Status struct {
+	// Sluggified label
+	// example: processing
+	Alias string `json:"alias"`
+	// Clean name
+	// example: Processing
+	Name string `json:"name"`
+}
+
 // NewBuild contains information needed to create a new NewStatus.
 //
 //swagger:model NewBuild
@@ -60,11 +83,14 @@ type NewBuild struct {
 	// required: true
 	// example: New Build
 	CommitSha string `json:"commit_sha" validate:"required,notblank"`
-	// StatusID
+	// StatusID, required when no build_status_alias is given
 	// in: string
-	// required: true
-	// example: New Build
-	BuildStatusID string `json:"build_status_id" validate:"required,notblank"`
+	// example: 1
+	BuildStatusID string `json:"build_status_id" validate:"required_without=BuildStatusAlias,omitempty,notblank"`
+	// Status alias, required when no build_status_id is given
+	// in: string
+	// example: processing
+	BuildStatusAlias string `json:"build_status_alias" validate:"required_without=BuildStatusID,omitempty,slug"`
 }
 
 // UpdateBuildStatus defines what information may be provided to
@@ -86,17 +112,40 @@ type UpdateBuild struct {
 	// in: string
 	// required: true
 	// example: Update Build
-	CommitSha *string `json:"commit_sha" validate:"omitempty,required,slug"`
+	CommitSha *string `json:"commit_sha" validate:"omitempty,required,notblank"`
 	// StatusID
 	// in: string
-	// required: true
-	// example: Update Build
-	BuildStatusID *string `json:"build_status_id" validate:"omitempty,required,slug"`
+	// example: 2
+	BuildStatusID *string `json:"build_status_id" validate:"omitempty,required,notblank"`
+	// Status alias, an alternative to build_status_id
+	// in: string
+	// example: success
+	BuildStatusAlias *string `json:"build_status_alias" validate:"omitempty,required,slug"`
+}
+
+// ETag returns the strong entity tag of the version of the build.
+func (rs Build) ETag() string {
+	return api.ETag(rs.ID, rs.Version)
 }
 
 func toStatus(dbRS db.Build) Build {
-	p := (*Build)(unsafe.Pointer(&dbRS))
-	return *p
+	return Build{
+		ID:            dbRS.ID,
+		UUID:          dbRS.UUID,
+		Label:         dbRS.Label,
+		CommitSha:     dbRS.CommitSha,
+		BuildStatusID: dbRS.BuildStatusID,
+		Status: Status{
+			Alias: dbRS.Status.Alias,
+			Name:  dbRS.Status.Name,
+		},
+		OrgUID:    dbRS.OrgUID,
+		SiteUID:   dbRS.SiteUID,
+		Version:   dbRS.Version,
+		CreatedOn: dbRS.CreatedOn,
+		UpdatedOn: dbRS.UpdatedOn,
+		DeletedOn: dbRS.DeletedOn,
+	}
 }
 
 func toStatusSlice(dbSRs []db.Build) []Build {
@@ -120,13 +169,16 @@ func (ns NewBuild) GenerateFakeData(num int) []NewBuild {
 	return data
 }
 
+// fakeStatuses are the build statuses seeded by the initial migration.
+var fakeStatuses = []string{"processing", "success", "failed"}
+
 // fakeData creates the fake record
 func (ns NewBuild) fakeData(counter int) NewBuild {
 	return NewBuild{
-		UUID:          fmt.Sprintf("uuid%d", counter),
-		Label:         fmt.Sprintf("label %d", counter),
-		CommitSha:     fmt.Sprintf("commit_sha %d", counter),
-		BuildStatusID: fmt.Sprintf("build_status_id %d", counter),
+		UUID:             fmt.Sprintf("uuid%d", counter),
+		Label:            fmt.Sprintf("label-%d", counter),
+		CommitSha:        fmt.Sprintf("%07x", counter),
+		BuildStatusAlias: fakeStatuses[(counter-1)%len(fakeStatuses)],
 	}
 }
 
@@ -8,7 +8,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/chaitanyamaili/go_rest/models/audit"
 	"github.com/chaitanyamaili/go_rest/models/buildstatus/db"
+	"github.com/chaitanyamaili/go_rest/pkg/api"
 	"github.com/chaitanyamaili/go_rest/pkg/database"
 	"github.com/chaitanyamaili/go_rest/pkg/validate"
 	"github.com/jmoiron/sqlx"
@@ -20,17 +22,36 @@ var (
 	ErrNotFound     = errors.New("build status not found")
 	ErrInvalidID    = errors.New("ID is not in its proper form")
 	ErrInvalidAlias = errors.New("alias is not in its proper form")
+
+	ErrPreconditionFailed = errors.New("build status does not match the If-Match etag")
+	ErrConflict           = errors.New("build status was changed by another request")
+
+	ErrInvalidTransition = errors.New("invalid build status transition")
+	ErrAliasInUse        = errors.New("alias is an allowed transition of other build statuses")
 )
 
+// Fields lists the fields build status reads can select.
+var Fields = db.Fields
+
 // Core manages the set of APIs for requesting source access
 type Core struct {
 	store db.Store
+	audit audit.Core
 }
 
 // NewCore constructs a core for requesting source api access.
 func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB, rwmux *sync.RWMutex) Core {
 	return Core{
 		store: db.NewStore(log, sqlxDB, rwmux),
+		audit: audit.NewCore(log, sqlxDB, rwmux),
+	}
+}
+
+// Tran return new Core reading and writing in the transaction.
+func (c Core) Tran(tx sqlx.ExtContext) Core {
+	return Core{
+		store: c.store.Tran(tx),
+		audit: c.audit.Tran(tx),
 	}
 }
 
@@ -44,24 +65,49 @@ func (c Core) Create(ctx context.Context, rs NewBuildStatus, now time.Time) (Bui
 		return BuildStatus{}, err
 	}
 
+	if err := checkGraph(rs.IsTerminal, rs.AllowedNext); err != nil {
+		return BuildStatus{}, err
+	}
+
+	t := database.TenantFrom(ctx)
 	dbRS := db.BuildStatus{
-		Alias:     strings.TrimSpace(rs.Alias),
-		Name:      strings.TrimSpace(rs.Name),
-		CreatedOn: now,
-		UpdatedOn: now,
+		Alias:       strings.TrimSpace(rs.Alias),
+		Name:        strings.TrimSpace(rs.Name),
+		IsTerminal:  rs.IsTerminal,
+		AllowedNext: joinAliases(rs.AllowedNext),
+		OrgUID:      t.OrgUID,
+		SiteUID:     t.SiteUID,
+		Version:     1,
+		CreatedOn:   now,
+		UpdatedOn:   now,
 	}
 
+	// The transitions are checked in the transaction so they name statuses
+	// that exist when the status is written. Their error is returned as is
+	// to the caller.
+	var rejected error
+
 	// This provides an example of how to execute a transaction if required.
 	tran := func(tx sqlx.ExtContext) error {
-		res, err := c.store.Tran(tx).Create(ctx, dbRS)
+		store := c.store.Tran(tx)
+		if err := checkAllowedNext(ctx, store, dbRS); err != nil {
+			rejected = err
+			return err
+		}
+
+		res, err := store.Create(ctx, dbRS)
 		if err != nil {
 			return fmt.Errorf("create: %w", err)
 		}
 		dbRS.ID = fmt.Sprintf("%d", res.LastInsertID)
-		return nil
+
+		return c.audit.Tran(tx).Record(ctx, audit.EntityBuildStatus, dbRS.ID, audit.ActionCreate, nil, toStatus(dbRS), now)
 	}
 
 	if err := c.store.WithinTran(ctx, tran); err != nil {
+		if rejected != nil {
+			return BuildStatus{}, rejected
+		}
 		return BuildStatus{}, fmt.Errorf("tran: %w", err)
 	}
 
@@ -69,7 +115,7 @@ func (c Core) Create(ctx context.Context, rs NewBuildStatus, now time.Time) (Bui
 }
 
 // Update replaces a requesting source document in the database.
-func (c Core) Update(ctx context.Context, id string, urs UpdateBuildStatus, now time.Time) error {
+func (c Core) Update(ctx context.Context, id string, urs UpdateBuildStatus, pre api.Precondition, now time.Time) error {
 	if err := validate.Check(urs); err != nil {
 		return err
 	}
@@ -77,71 +123,183 @@ func (c Core) Update(ctx context.Context, id string, urs UpdateBuildStatus, now
 		return ErrInvalidID
 	}
 
-	dbRS, err := c.store.QueryByID(ctx, id)
+	dbRS, err := c.store.QueryByID(ctx, id, database.Fieldset{})
 	if err != nil {
 		if errors.Is(err, database.ErrDBNotFound) {
 			return ErrNotFound
 		}
 		return fmt.Errorf("updating status id[%s]: %w", id, err)
 	}
+	if !database.TenantFrom(ctx).Owns(dbRS.OrgUID, dbRS.SiteUID) {
+		return ErrNotFound
+	}
+	if !pre.Matches(api.ETag(dbRS.ID, dbRS.Version)) {
+		return ErrPreconditionFailed
+	}
+	before := toStatus(dbRS)
 
 	hasChanges := false
 	if urs.Alias != nil {
 		dbRS.Alias = strings.TrimSpace(*urs.Alias)
 		hasChanges = true
 	}
+	renamed := dbRS.Alias != before.Alias
 	if urs.Name != nil {
 		dbRS.Name = strings.TrimSpace(*urs.Name)
 		hasChanges = true
 	}
+	if urs.IsTerminal != nil {
+		dbRS.IsTerminal = *urs.IsTerminal
+		hasChanges = true
+	}
+	if urs.AllowedNext != nil {
+		dbRS.AllowedNext = joinAliases(*urs.AllowedNext)
+		hasChanges = true
+	}
 	// No changes were made - don't touch the DB
 	if !hasChanges {
 		return nil
 	}
+	if renamed {
+		dbRS.AllowedNext = renameAlias(dbRS.AllowedNext, before.Alias, dbRS.Alias)
+	}
+	if err := checkGraph(dbRS.IsTerminal, SplitAliases(dbRS.AllowedNext)); err != nil {
+		return err
+	}
 	dbRS.UpdatedOn = now
 
-	_, err = c.store.Update(ctx, dbRS)
-	if err != nil {
-		return fmt.Errorf("update id[%s]: %w", id, err)
+	// The transitions are checked in the transaction so they name statuses
+	// that exist when the status is written. Their error is returned as is
+	// to the caller.
+	var rejected error
+
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+		if renamed {
+			if err := checkUnreferenced(ctx, store, dbRS, before.Alias); err != nil {
+				rejected = err
+				return err
+			}
+		}
+		if urs.AllowedNext != nil {
+			if err := checkAllowedNext(ctx, store, dbRS); err != nil {
+				rejected = err
+				return err
+			}
+		}
+
+		res, err := store.Update(ctx, dbRS)
+		if err != nil {
+			return fmt.Errorf("update id[%s]: %w", id, err)
+		}
+		if res.AffectedRows == 0 {
+			return modifiedError(pre)
+		}
+		dbRS.Version++
+
+		return c.audit.Tran(tx).Record(ctx, audit.EntityBuildStatus, id, audit.ActionUpdate, before, toStatus(dbRS), now)
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		if rejected != nil {
+			return rejected
+		}
+		return fmt.Errorf("tran: %w", err)
 	}
 
 	return nil
 }
 
 // Delete removes a requesting source from the database.
-func (c Core) Delete(ctx context.Context, id string, now time.Time) error {
+func (c Core) Delete(ctx context.Context, id string, pre api.Precondition, now time.Time) error {
 	if err := validate.CheckID(id); err != nil {
 		return ErrInvalidID
 	}
 
-	_, err := c.store.QueryByID(ctx, id)
+	dbRS, err := c.store.QueryByID(ctx, id, database.Fieldset{})
 	if err != nil {
 		if errors.Is(err, database.ErrDBNotFound) {
 			return ErrNotFound
 		}
 		return fmt.Errorf("undeleting status id[%s]: %w", id, err)
 	}
+	if !database.TenantFrom(ctx).Owns(dbRS.OrgUID, dbRS.SiteUID) {
+		return ErrNotFound
+	}
+	if !pre.Matches(api.ETag(dbRS.ID, dbRS.Version)) {
+		return ErrPreconditionFailed
+	}
 
-	_, err = c.store.Delete(ctx, id, now)
-	if err != nil {
-		return fmt.Errorf("delete id[%s]: %w", id, err)
+	// A status other statuses can move to can't go away, the builds
+	// following that transition would fail. The error is returned as is.
+	var rejected error
+
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+		if err := checkUnreferenced(ctx, store, dbRS, dbRS.Alias); err != nil {
+			rejected = err
+			return err
+		}
+
+		res, err := store.Delete(ctx, id, dbRS.Version, now)
+		if err != nil {
+			return fmt.Errorf("delete id[%s]: %w", id, err)
+		}
+		if res.AffectedRows == 0 {
+			return modifiedError(pre)
+		}
+
+		before := toStatus(dbRS)
+		dbRS.DeletedOn = &now
+		dbRS.Version++
+
+		return c.audit.Tran(tx).Record(ctx, audit.EntityBuildStatus, id, audit.ActionDelete, before, toStatus(dbRS), now)
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		if rejected != nil {
+			return rejected
+		}
+		return fmt.Errorf("tran: %w", err)
 	}
 
 	return nil
 }
 
-// UnDelete removes a requesting source from the database.
-func (c Core) UnDelete(ctx context.Context, id string) (BuildStatus, error) {
+// UnDelete restores a soft deleted requesting source. Restoring one that
+// isn't deleted changes nothing.
+func (c Core) UnDelete(ctx context.Context, id string, now time.Time) (BuildStatus, error) {
 	if err := validate.CheckID(id); err != nil {
 		return BuildStatus{}, ErrInvalidID
 	}
 
-	_, err := c.store.UnDelete(ctx, id)
-	if err != nil {
-		return BuildStatus{}, fmt.Errorf("undelete: %w", err)
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		dbRS, err := store.QueryDeletedByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				return nil
+			}
+			return fmt.Errorf("undeleting status id[%s]: %w", id, err)
+		}
+
+		if _, err := store.UnDelete(ctx, id); err != nil {
+			return fmt.Errorf("undelete: %w", err)
+		}
+
+		before := toStatus(dbRS)
+		dbRS.DeletedOn = nil
+		dbRS.Version++
+
+		return c.audit.Tran(tx).Record(ctx, audit.EntityBuildStatus, id, audit.ActionUnDelete, before, toStatus(dbRS), now)
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return BuildStatus{}, fmt.Errorf("tran: %w", err)
 	}
 
-	dbRS, err := c.store.QueryByID(ctx, id)
+	dbRS, err := c.store.QueryByID(ctx, id, database.Fieldset{})
 	if err != nil {
 		if errors.Is(err, database.ErrDBNotFound) {
 			return BuildStatus{}, ErrNotFound
@@ -153,22 +311,22 @@ func (c Core) UnDelete(ctx context.Context, id string) (BuildStatus, error) {
 }
 
 // Query retrieves a list of existing records from the database
-func (c Core) Query(ctx context.Context, pagi database.Pagination) ([]BuildStatus, error) {
-	res, err := c.store.Query(ctx, pagi)
+func (c Core) Query(ctx context.Context, fs database.Fieldset, pagi database.Pagination) ([]BuildStatus, database.Page, error) {
+	res, page, err := c.store.Query(ctx, fs, pagi)
 	if err != nil {
-		return []BuildStatus{}, fmt.Errorf("query: %w", err)
+		return []BuildStatus{}, database.Page{}, fmt.Errorf("query: %w", err)
 	}
 
-	return toStatusSlice(res), nil
+	return toStatusSlice(res), page, nil
 }
 
 // QueryByID retrieves a single records from the database by id
-func (c Core) QueryByID(ctx context.Context, id string) (BuildStatus, error) {
+func (c Core) QueryByID(ctx context.Context, id string, fs database.Fieldset) (BuildStatus, error) {
 	if err := validate.CheckID(id); err != nil {
 		return BuildStatus{}, ErrInvalidID
 	}
 
-	res, err := c.store.QueryByID(ctx, id)
+	res, err := c.store.QueryByID(ctx, id, fs)
 	if err != nil {
 		if errors.Is(err, database.ErrDBNotFound) {
 			return BuildStatus{}, ErrNotFound
@@ -180,12 +338,12 @@ func (c Core) QueryByID(ctx context.Context, id string) (BuildStatus, error) {
 }
 
 // QueryByAlias retrieves a single records from the database by id
-func (c Core) QueryByAlias(ctx context.Context, alias string) (BuildStatus, error) {
+func (c Core) QueryByAlias(ctx context.Context, alias string, fs database.Fieldset) (BuildStatus, error) {
 	if err := validate.CheckSlug(alias); err != nil {
 		return BuildStatus{}, ErrInvalidAlias
 	}
 
-	res, err := c.store.QueryByAlias(ctx, alias)
+	res, err := c.store.QueryByAlias(ctx, alias, fs)
 	if err != nil {
 		if errors.Is(err, database.ErrDBNotFound) {
 			return BuildStatus{}, ErrNotFound
@@ -195,3 +353,86 @@ func (c Core) QueryByAlias(ctx context.Context, alias string) (BuildStatus, erro
 
 	return toStatus(res), nil
 }
+
+// checkGraph makes sure a terminal status doesn't allow any transition.
+func checkGraph(isTerminal bool, allowedNext []string) error {
+	if isTerminal && len(allowedNext) > 0 {
+		return validate.FieldErrors{
+			FieldError: []validate.FieldError{{
+				Field: "allowed_next",
+				Error: "allowed_next must be empty for a terminal status",
+			}},
+		}
+	}
+	return nil
+}
+
+// checkAllowedNext makes sure every allowed transition of the status names
+// a build status the tenant of the request can use, or the status itself.
+func checkAllowedNext(ctx context.Context, store db.Store, dbRS db.BuildStatus) error {
+	var fe validate.FieldErrors
+	for _, alias := range SplitAliases(dbRS.AllowedNext) {
+		if alias == dbRS.Alias {
+			continue
+		}
+
+		if _, err := store.QueryByAlias(ctx, alias, database.Fieldset{}); err != nil {
+			if !errors.Is(err, database.ErrDBNotFound) {
+				return fmt.Errorf("checking allowed_next: %w", err)
+			}
+			fe.FieldError = append(fe.FieldError, validate.FieldError{
+				Field: "allowed_next",
+				Error: fmt.Sprintf("build status %q does not exist", alias),
+			})
+		}
+	}
+
+	if len(fe.FieldError) > 0 {
+		return fe
+	}
+	return nil
+}
+
+// checkUnreferenced makes sure no other build status allows a transition to
+// the alias a status is renamed from or deleted with. Statuses of every tenant can move to a
+// shared status, only those of its tenant to the status of a tenant.
+func checkUnreferenced(ctx context.Context, store db.Store, dbRS db.BuildStatus, alias string) error {
+	t := database.Tenant{
+		OrgUID:  dbRS.OrgUID,
+		SiteUID: dbRS.SiteUID,
+		All:     dbRS.OrgUID == "" && dbRS.SiteUID == "",
+	}
+
+	refs, err := store.QueryReferencing(ctx, t, alias)
+	if err != nil {
+		return fmt.Errorf("checking references to alias[%q]: %w", alias, err)
+	}
+
+	var by []string
+	for _, ref := range refs {
+		if ref.ID == dbRS.ID {
+			continue
+		}
+		for _, next := range SplitAliases(ref.AllowedNext) {
+			if next == alias {
+				by = append(by, ref.Alias)
+				break
+			}
+		}
+	}
+
+	if len(by) > 0 {
+		return fmt.Errorf("%w: %s is allowed by %s", ErrAliasInUse, alias, strings.Join(by, ", "))
+	}
+	return nil
+}
+
+// modifiedError returns the error of a build status that changed between the time
+// it was read and written. The If-Match etag of the request no longer holds
+// if there was one, the request can be retried otherwise.
+func modifiedError(pre api.Precondition) error {
+	if pre.Empty() {
+		return ErrConflict
+	}
+	return ErrPreconditionFailed
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 
@@ -58,17 +57,18 @@ func (s Store) Tran(tx sqlx.ExtContext) Store {
 // Database Query Repository
 // -----------------------------------------------------------------------
 
-// Create inserts a new requesting into the database.
+// Create inserts a new requesting into the database, owned by the tenant of
+// the request.
 func (s Store) Create(ctx context.Context, rs BuildStatus) (database.DBResults, error) {
 	const q = `
-	INSERT INTO status
-		(alias, label, description, created_on, updated_on)
+	INSERT INTO build_status
+		(alias, name, is_terminal, allowed_next, org_uid, site_uid, created_on, updated_on)
 	VALUES
-		(:alias, :label, :description, :created_on, :updated_on)`
+		(:alias, :name, :is_terminal, :allowed_next, :org_uid, :site_uid, :created_on, :updated_on)`
 
-	res, err := database.NamedExecContext(ctx, s.log, s.db, q, rs)
+	res, err := database.NamedInsertContext(ctx, s.log, s.db, q, database.TenantFrom(ctx).Data(rs))
 	if err != nil {
-		if strings.Contains(err.Error(), "Duplicate entry") {
+		if database.IsDuplicateEntry(err) {
 			return database.DBResults{}, database.NewError(database.ErrDBDuplicatedEntry, http.StatusConflict)
 		}
 
@@ -78,22 +78,29 @@ func (s Store) Create(ctx context.Context, rs BuildStatus) (database.DBResults,
 	return res, nil
 }
 
-// Update replaces a requesting source record in the database.
+// Update replaces a requesting source record in the database, as long as
+// it is still at the version it was read at. Nothing is affected otherwise.
+// The statuses shared by the default tenant can't be updated from other
+// tenants.
 func (s Store) Update(ctx context.Context, rs BuildStatus) (database.DBResults, error) {
-	const q = `
+	t := database.TenantFrom(ctx)
+	q := database.TenantQuery(t, "", `
 	UPDATE
-		status
-	SET 
+		build_status
+	SET
 		alias = :alias,
-		label = :label,
-		description = :description,
-		updated_on = :updated_on
+		name = :name,
+		is_terminal = :is_terminal,
+		allowed_next = :allowed_next,
+		updated_on = :updated_on,
+		version = version + 1
 	WHERE
-		id = :id`
+		id = :id
+		AND version = :version:tenant`)
 
-	res, err := database.NamedExecContext(ctx, s.log, s.db, q, rs)
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, t.Data(rs))
 	if err != nil {
-		if strings.Contains(err.Error(), "Duplicate entry") {
+		if database.IsDuplicateEntry(err) {
 			return database.DBResults{}, database.NewError(database.ErrDBDuplicatedEntry, http.StatusConflict)
 		}
 		return database.DBResults{}, fmt.Errorf("updating Requesting Source ID[%s]: %w", rs.ID, err)
@@ -102,25 +109,31 @@ func (s Store) Update(ctx context.Context, rs BuildStatus) (database.DBResults,
 	return res, nil
 }
 
-// Delete removes a requesting source from the database.
-func (s Store) Delete(ctx context.Context, id string, now time.Time) (database.DBResults, error) {
+// Delete removes a requesting source from the database, as long as it is
+// still at the version it was read at. Nothing is affected otherwise.
+func (s Store) Delete(ctx context.Context, id string, version int, now time.Time) (database.DBResults, error) {
 	data := struct {
 		ID        string    `db:"id"`
+		Version   int       `db:"version"`
 		DeletedOn time.Time `db:"deleted_on"`
 	}{
 		ID:        id,
+		Version:   version,
 		DeletedOn: now,
 	}
 
-	const q = `
+	t := database.TenantFrom(ctx)
+	q := database.TenantQuery(t, "", `
 	UPDATE
-		status
+		build_status
 	SET
-		deleted_on = :deleted_on
+		deleted_on = :deleted_on,
+		version = version + 1
 	WHERE
-		id = :id`
+		id = :id
+		AND version = :version:tenant`)
 
-	res, err := database.NamedExecContext(ctx, s.log, s.db, q, data)
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, t.Data(data))
 	if err != nil {
 		return database.DBResults{}, fmt.Errorf("deleting requesting source id[%s]: %w", id, err)
 	}
@@ -134,15 +147,17 @@ func (s Store) UnDelete(ctx context.Context, id string) (database.DBResults, err
 		ID string `db:"id"`
 	}{ID: id}
 
-	const q = `
+	t := database.TenantFrom(ctx)
+	q := database.TenantQuery(t, "", `
 	UPDATE
-		status
+		build_status
 	SET
-		deleted_on = null
+		deleted_on = null,
+		version = version + 1
 	WHERE
-		id = :id`
+		id = :id:tenant`)
 
-	res, err := database.NamedExecContext(ctx, s.log, s.db, q, data)
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, t.Data(data))
 	if err != nil {
 		return database.DBResults{}, fmt.Errorf("undeleting requesting source id[%s]: %w", id, err)
 	}
@@ -150,95 +165,174 @@ func (s Store) UnDelete(ctx context.Context, id string) (database.DBResults, err
 	return res, nil
 }
 
-// Query retrieves a list of existing requesting source from the database.
-func (s Store) Query(ctx context.Context, pagi database.Pagination) ([]BuildStatus, error) {
-	q := database.PaginationQuery(pagi, `
+// Fields lists the fields build status reads can select.
+var Fields = database.Fields{
+	Columns: map[string]string{
+		"id":           "id",
+		"alias":        "alias",
+		"name":         "name",
+		"is_terminal":  "is_terminal",
+		"allowed_next": "allowed_next",
+		"org_uid":      "org_uid",
+		"site_uid":     "site_uid",
+		"created_on":   "created_on",
+		"updated_on":   "updated_on",
+		"version":      "version",
+		"deleted_on":   "deleted_on",
+	},
+}
+
+// Query retrieves a page of the existing build statuses of the tenant, and
+// the ones shared by the default tenant, from the database.
+func (s Store) Query(ctx context.Context, fs database.Fieldset, pagi database.Pagination) ([]BuildStatus, database.Page, error) {
+	t := database.TenantFrom(ctx)
+	q := database.PaginationQuery(pagi, database.KeysetQuery(pagi, "", `
 	SELECT
-		id,
-	    alias,
-	    label,
-	    description,
-	    created_on,
-	    updated_on,
-	    deleted_on
+		:columns
 	FROM
-		status
+		build_status
 	WHERE
-		deleted_on is null
+		deleted_on is null:tenant:keyset
 	ORDER BY
 		:sort :direction,
 		id :direction
 	LIMIT
-		:page,:per_page`)
+		:per_page OFFSET :page`))
+
+	const qc = `
+	SELECT
+		COUNT(*) AS total
+	FROM
+		build_status
+	WHERE
+		deleted_on is null:tenant`
+
+	pq := database.PageQuery{
+		Query:    database.FieldsQuery(Fields, fs, database.SharedTenantQuery(t, "", q), "id", pagi.Sort),
+		Count:    database.SharedTenantQuery(t, "", qc),
+		Table:    "build_status",
+		Filtered: !t.All,
+	}
 
 	// Slice to hold results
 	var res []BuildStatus
-	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, pagi, &res); err != nil {
-		if database.IsError(err) && err.Error() == database.ErrDBNotFound.Error() {
-			return []BuildStatus{}, database.ErrDBNotFound
-		}
-		return nil, fmt.Errorf("selecting requesting source: %w", err)
+	page, err := database.NamedQueryPage(ctx, s.log, s.db, pq, pagi, t.Data(pagi), &res)
+	if err != nil {
+		return nil, database.Page{}, fmt.Errorf("selecting build statuses: %w", err)
 	}
 
-	return res, nil
+	return res, page, nil
 }
 
 // QueryByID retrieves a list of existing requesting sources from the database.
-func (s Store) QueryByID(ctx context.Context, id string) (BuildStatus, error) {
+func (s Store) QueryByID(ctx context.Context, id string, fs database.Fieldset) (BuildStatus, error) {
 	data := struct {
 		ID string `db:"id"`
 	}{ID: id}
-	const q = `
+	t := database.TenantFrom(ctx)
+	q := database.FieldsQuery(Fields, fs, database.SharedTenantQuery(t, "", `
+	SELECT
+		:columns
+	FROM
+		build_status
+	WHERE
+		id = :id
+		and deleted_on is null:tenant`), "id", "version")
+
+	// Slice to hold results
+	var res BuildStatus
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, t.Data(data), &res); err != nil {
+		// Empty Check (no results)
+		if database.IsError(err) && err.Error() == database.ErrDBNotFound.Error() {
+			return BuildStatus{}, database.ErrDBNotFound
+		}
+		return BuildStatus{}, fmt.Errorf("selecting status by ID[%q]: %w", id, err)
+	}
+
+	return res, nil
+}
+
+// QueryReferencing retrieves the build statuses of the tenant, and the
+// shared ones, whose allowed transitions may name the alias. The caller
+// matches the aliases exactly, the query only narrows the rows down.
+func (s Store) QueryReferencing(ctx context.Context, t database.Tenant, alias string) ([]BuildStatus, error) {
+	data := struct {
+		Pattern string `db:"pattern"`
+	}{Pattern: "%" + alias + "%"}
+	q := database.SharedTenantQuery(t, "", `
 	SELECT
 		id,
 		alias,
-		label,
-		description,
-		created_on,
-		updated_on,
-		deleted_on
+		allowed_next,
+		org_uid,
+		site_uid
 	FROM
-		status
+		build_status
+	WHERE
+		allowed_next LIKE :pattern
+		and deleted_on is null:tenant`)
+
+	// Slice to hold results
+	var res []BuildStatus
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, t.Data(data), &res); err != nil {
+		return nil, fmt.Errorf("selecting statuses referencing alias[%q]: %w", alias, err)
+	}
+
+	return res, nil
+}
+
+// QueryDeletedByID retrieves a soft deleted build status of the tenant from
+// the database.
+func (s Store) QueryDeletedByID(ctx context.Context, id string) (BuildStatus, error) {
+	data := struct {
+		ID string `db:"id"`
+	}{ID: id}
+	t := database.TenantFrom(ctx)
+	q := database.FieldsQuery(Fields, database.Fieldset{}, database.TenantQuery(t, "", `
+	SELECT
+		:columns
+	FROM
+		build_status
 	WHERE
 		id = :id
-		and deleted_on is null`
+		and deleted_on is not null:tenant`), "id", "version")
 
 	// Slice to hold results
 	var res BuildStatus
-	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &res); err != nil {
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, t.Data(data), &res); err != nil {
 		// Empty Check (no results)
 		if database.IsError(err) && err.Error() == database.ErrDBNotFound.Error() {
 			return BuildStatus{}, database.ErrDBNotFound
 		}
-		return BuildStatus{}, fmt.Errorf("selecting status by ID[%q]: %w", id, err)
+		return BuildStatus{}, fmt.Errorf("selecting deleted status by ID[%q]: %w", id, err)
 	}
 
 	return res, nil
 }
 
 // QueryByAlias retrieves a list of existing requesting sources from the database.
-func (s Store) QueryByAlias(ctx context.Context, alias string) (BuildStatus, error) {
+func (s Store) QueryByAlias(ctx context.Context, alias string, fs database.Fieldset) (BuildStatus, error) {
 	data := struct {
 		Alias string `db:"alias"`
 	}{Alias: alias}
-	const q = `
+	t := database.TenantFrom(ctx)
+
+	// The status of the tenant shadows the shared one with the same alias.
+	q := database.FieldsQuery(Fields, fs, database.SharedTenantQuery(t, "", `
 	SELECT
-		id,
-		alias,
-		label,
-		description,
-		created_on,
-		updated_on,
-		deleted_on
+		:columns
 	FROM
-		status
+		build_status
 	WHERE
 		alias = :alias
-		and deleted_on is null`
+		and deleted_on is null:tenant
+	ORDER BY
+		org_uid DESC,
+		site_uid DESC`), "id", "version")
 
 	// Slice to hold results
 	var res BuildStatus
-	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &res); err != nil {
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, t.Data(data), &res); err != nil {
 		if database.IsError(err) && err.Error() == database.ErrDBNotFound.Error() {
 			return BuildStatus{}, database.ErrDBNotFound
 		}
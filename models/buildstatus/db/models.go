@@ -5,10 +5,23 @@ import "time"
 // BuildStatus represent the structure we need for moving data
 // between the app and the database.
 type BuildStatus struct {
-	ID        string     `db:"id"`
-	Alias     string     `db:"alias"`
-	Name      string     `db:"name"`
-	CreatedOn time.Time  `db:"created_on"`
-	UpdatedOn time.Time  `db:"updated_on"`
-	DeletedOn *time.Time `db:"deleted_on"`
+	ID          string     `db:"id"`
+	Alias       string     `db:"alias"`
+	Name        string     `db:"name"`
+	IsTerminal  bool       `db:"is_terminal"`
+	AllowedNext string     `db:"allowed_next"`
+	OrgUID      string     `db:"org_uid"`
+	SiteUID     string     `db:"site_uid"`
+	Version     int        `db:"version"`
+	CreatedOn   time.Time  `db:"created_on"`
+	UpdatedOn   time.Time  `db:"updated_on"`
+	DeletedOn   *time.Time `db:"deleted_on"`
+}
+
+// Keyset returns the id and the sort value cursors are issued from.
+func (b BuildStatus) Keyset(sort string) (string, time.Time) {
+	if sort == "updated_on" {
+		return b.ID, b.UpdatedOn
+	}
+	return b.ID, b.CreatedOn
 }
@@ -3,10 +3,11 @@ package buildstatus
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
-	"unsafe"
 
 	"github.com/chaitanyamaili/go_rest/models/buildstatus/db"
+	"github.com/chaitanyamaili/go_rest/pkg/api"
 )
 
 // BuildStatus represents the state of the current session
@@ -23,6 +24,21 @@ type BuildStatus struct {
 	// Clean name
 	// example: Status Name
 	Name string `json:"name"`
+	// Builds in a terminal status can't move to any other status
+	// example: false
+	IsTerminal bool `json:"is_terminal"`
+	// Aliases of the statuses a build in this status may move to
+	// example: ["success", "failed"]
+	AllowedNext []string `json:"allowed_next"`
+	// Org the status belongs to, empty for the statuses shared by every org
+	// example: 8d8ac610-566d-4ef0-9c22-186b2a5ed793
+	OrgUID string `json:"org_uid"`
+	// Site the status belongs to, empty for the statuses shared by every org
+	// example: 4b4d4a3e-8f2b-4f5c-9a0d-3a1b2c3d4e5f
+	SiteUID string `json:"site_uid"`
+	// Incremented on every change, the ETag is derived from it
+	// example: 1
+	Version int `json:"version"`
 	// Database created value
 	// example: 2021-05-25T00:53:16.535668Z
 	CreatedOn time.Time `json:"created_on"`
@@ -49,6 +65,15 @@ type NewBuildStatus struct {
 	// required: true
 	// example: New Status
 	Name string `json:"name" validate:"required,notblank"`
+	// Builds in a terminal status can't move to any other status
+	// in: boolean
+	// example: false
+	IsTerminal bool `json:"is_terminal"`
+	// Aliases of the statuses a build in this status may move to, each
+	// must be an existing status
+	// in: array
+	// example: ["success", "failed"]
+	AllowedNext []string `json:"allowed_next" validate:"omitempty,dive,slug"`
 }
 
 // UpdateBuildStatus defines what information may be provided to
@@ -70,11 +95,93 @@ type UpdateBuildStatus struct {
 	// in: string
 	// example: Updated Status
 	Name *string `json:"name" validate:"omitempty,required,notblank"`
+	// Builds in a terminal status can't move to any other status
+	// in: boolean
+	// example: true
+	IsTerminal *bool `json:"is_terminal"`
+	// Aliases of the statuses a build in this status may move to, each
+	// must be an existing status
+	// in: array
+	// example: ["success", "failed"]
+	AllowedNext *[]string `json:"allowed_next" validate:"omitempty,dive,slug"`
+}
+
+// CheckTransition validates that a build in this status may move into the
+// next status. Staying in the same status is always allowed.
+func (bs BuildStatus) CheckTransition(next BuildStatus) error {
+	if bs.Alias == next.Alias {
+		return nil
+	}
+	if bs.IsTerminal {
+		return fmt.Errorf("%w: %s is a terminal status and can't move to %s", ErrInvalidTransition, bs.Alias, next.Alias)
+	}
+
+	for _, alias := range bs.AllowedNext {
+		if alias == next.Alias {
+			return nil
+		}
+	}
+
+	allowed := "none"
+	if len(bs.AllowedNext) > 0 {
+		allowed = strings.Join(bs.AllowedNext, ", ")
+	}
+	return fmt.Errorf("%w: %s can't move to %s, allowed transitions: %s", ErrInvalidTransition, bs.Alias, next.Alias, allowed)
+}
+
+// SplitAliases parses the comma separated aliases stored in the database.
+func SplitAliases(str string) []string {
+	aliases := []string{}
+	for _, alias := range strings.Split(str, ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
+// joinAliases cleans and joins the aliases for storage.
+func joinAliases(aliases []string) string {
+	clean := make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			clean = append(clean, alias)
+		}
+	}
+	return strings.Join(clean, ",")
+}
+
+// renameAlias replaces an alias in stored aliases, so a status allowing a
+// transition to itself keeps doing so once renamed.
+func renameAlias(str string, from string, to string) string {
+	aliases := SplitAliases(str)
+	for i, alias := range aliases {
+		if alias == from {
+			aliases[i] = to
+		}
+	}
+	return joinAliases(aliases)
+}
+
+// ETag returns the strong entity tag of the version of the build status.
+func (rs BuildStatus) ETag() string {
+	return api.ETag(rs.ID, rs.Version)
 }
 
 func toStatus(dbRS db.BuildStatus) BuildStatus {
-	p := (*BuildStatus)(unsafe.Pointer(&dbRS))
-	return *p
+	return BuildStatus{
+		ID:          dbRS.ID,
+		Alias:       dbRS.Alias,
+		Name:        dbRS.Name,
+		IsTerminal:  dbRS.IsTerminal,
+		AllowedNext: SplitAliases(dbRS.AllowedNext),
+		OrgUID:      dbRS.OrgUID,
+		SiteUID:     dbRS.SiteUID,
+		Version:     dbRS.Version,
+		CreatedOn:   dbRS.CreatedOn,
+		UpdatedOn:   dbRS.UpdatedOn,
+		DeletedOn:   dbRS.DeletedOn,
+	}
 }
 
 func toStatusSlice(dbSRs []db.BuildStatus) []BuildStatus {
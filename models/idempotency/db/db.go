@@ -0,0 +1,187 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store holds details for basic database needs
+type Store struct {
+	log          *zap.SugaredLogger
+	tr           database.Transactor
+	db           sqlx.ExtContext
+	rwmux        *sync.RWMutex
+	isWithinTran bool
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB, rwmux *sync.RWMutex) Store {
+	return Store{
+		log:   log,
+		tr:    db,
+		db:    db,
+		rwmux: rwmux,
+	}
+}
+
+// WithinTran runs passes function and do commit/rollback at the end.
+func (s Store) WithinTran(ctx context.Context, fn func(sqlx.ExtContext) error) error {
+	if s.isWithinTran {
+		return fn(s.db)
+	}
+	s.rwmux.Lock()
+	err := database.WithinTran(ctx, s.log, s.tr, fn)
+	s.rwmux.Unlock()
+
+	return err
+}
+
+// Tran return new Store with transaction in it.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log:          s.log,
+		tr:           s.tr,
+		db:           tx,
+		isWithinTran: true,
+	}
+}
+
+// -----------------------------------------------------------------------
+// Database Query Repository
+// -----------------------------------------------------------------------
+
+// Create inserts a new idempotency record into the database. The driver
+// error is wrapped so callers can tell a duplicate key with
+// database.IsDuplicateEntry.
+func (s Store) Create(ctx context.Context, rec Record) (database.DBResults, error) {
+	const q = `
+	INSERT INTO idempotency_record
+		(scoped_key, fingerprint, status_code, content_type, headers, body, created_on, expires_on)
+	VALUES
+		(:scoped_key, :fingerprint, :status_code, :content_type, :headers, :body, :created_on, :expires_on)`
+
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, rec)
+	if err != nil {
+		return database.DBResults{}, fmt.Errorf("inserting idempotency record: %w", err)
+	}
+
+	return res, nil
+}
+
+// Complete stores the response of an idempotency record in the database.
+func (s Store) Complete(ctx context.Context, rec Record) (database.DBResults, error) {
+	const q = `
+	UPDATE
+		idempotency_record
+	SET
+		status_code = :status_code,
+		content_type = :content_type,
+		headers = :headers,
+		body = :body,
+		expires_on = :expires_on
+	WHERE
+		scoped_key = :scoped_key
+		AND fingerprint = :fingerprint`
+
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, rec)
+	if err != nil {
+		return database.DBResults{}, fmt.Errorf("completing idempotency record: %w", err)
+	}
+
+	return res, nil
+}
+
+// Delete removes an idempotency record from the database.
+func (s Store) Delete(ctx context.Context, key string) (database.DBResults, error) {
+	data := struct {
+		Key string `db:"scoped_key"`
+	}{Key: key}
+
+	const q = `
+	DELETE FROM
+		idempotency_record
+	WHERE
+		scoped_key = :scoped_key`
+
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, data)
+	if err != nil {
+		return database.DBResults{}, fmt.Errorf("deleting idempotency record: %w", err)
+	}
+
+	return res, nil
+}
+
+// DeleteExpired removes the idempotency records that expired from the
+// database.
+func (s Store) DeleteExpired(ctx context.Context, now time.Time) (database.DBResults, error) {
+	data := struct {
+		Now time.Time `db:"now"`
+	}{Now: now}
+
+	const q = `
+	DELETE FROM
+		idempotency_record
+	WHERE
+		expires_on <= :now`
+
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, data)
+	if err != nil {
+		return database.DBResults{}, fmt.Errorf("deleting expired idempotency records: %w", err)
+	}
+
+	return res, nil
+}
+
+// DeleteExpiredByKey removes an idempotency record from the database if it
+// expired.
+func (s Store) DeleteExpiredByKey(ctx context.Context, key string, now time.Time) (database.DBResults, error) {
+	data := struct {
+		Key string    `db:"scoped_key"`
+		Now time.Time `db:"now"`
+	}{Key: key, Now: now}
+
+	const q = `
+	DELETE FROM
+		idempotency_record
+	WHERE
+		scoped_key = :scoped_key
+		AND expires_on <= :now`
+
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, data)
+	if err != nil {
+		return database.DBResults{}, fmt.Errorf("deleting expired idempotency record: %w", err)
+	}
+
+	return res, nil
+}
+
+// QueryByKey retrieves an idempotency record from the database.
+func (s Store) QueryByKey(ctx context.Context, key string) (Record, error) {
+	data := struct {
+		Key string `db:"scoped_key"`
+	}{Key: key}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		idempotency_record
+	WHERE
+		scoped_key = :scoped_key`
+
+	var res Record
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &res); err != nil {
+		if database.IsError(err) && err.Error() == database.ErrDBNotFound.Error() {
+			return Record{}, database.ErrDBNotFound
+		}
+		return Record{}, fmt.Errorf("selecting idempotency record: %w", err)
+	}
+
+	return res, nil
+}
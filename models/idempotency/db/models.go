@@ -0,0 +1,16 @@
+package db
+
+import "time"
+
+// Record represent the structure we need for moving data
+// between the app and the database.
+type Record struct {
+	Key         string    `db:"scoped_key"`
+	Fingerprint string    `db:"fingerprint"`
+	StatusCode  int       `db:"status_code"`
+	ContentType string    `db:"content_type"`
+	Headers     string    `db:"headers"`
+	Body        string    `db:"body"`
+	CreatedOn   time.Time `db:"created_on"`
+	ExpiresOn   time.Time `db:"expires_on"`
+}
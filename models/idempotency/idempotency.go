@@ -0,0 +1,181 @@
+// Package idempotency stores the idempotency records of the requests sent
+// with an Idempotency-Key header in the database.
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/models/idempotency/db"
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+	"github.com/chaitanyamaili/go_rest/pkg/idempotency"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Core manages the set of APIs for idempotency record access.
+type Core struct {
+	store db.Store
+}
+
+// NewCore constructs a core for idempotency record api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB, rwmux *sync.RWMutex) Core {
+	return Core{
+		store: db.NewStore(log, sqlxDB, rwmux),
+	}
+}
+
+// Reserve stores the record, still in progress, unless the key already has
+// a record that did not expire. It then returns that record and false. An
+// expired record of the key, not swept yet, is replaced.
+func (c Core) Reserve(ctx context.Context, rec idempotency.Record, now time.Time) (idempotency.Record, bool, error) {
+	return c.reserve(ctx, rec, now, true)
+}
+
+// reserve implements Reserve. The record is created again at most once
+// after the record holding the key went away, a key that keeps coming back
+// is another request racing for it and is reported as in progress.
+func (c Core) reserve(ctx context.Context, rec idempotency.Record, now time.Time, retry bool) (idempotency.Record, bool, error) {
+	dbRec := toDBRecord(rec)
+	dbRec.StatusCode = 0
+	dbRec.ContentType = ""
+	dbRec.Headers = ""
+	dbRec.Body = ""
+
+	_, err := c.store.Create(ctx, dbRec)
+	switch {
+	case err == nil:
+		return rec, true, nil
+
+	case !database.IsDuplicateEntry(err):
+		return idempotency.Record{}, false, fmt.Errorf("reserve: %w", err)
+	}
+
+	dbRec, err = c.store.QueryByKey(ctx, rec.Key)
+	if err != nil {
+		// The record expired and was removed in between.
+		if errors.Is(err, database.ErrDBNotFound) {
+			if !retry {
+				return inProgress(rec), false, nil
+			}
+			return c.reserve(ctx, rec, now, false)
+		}
+		return idempotency.Record{}, false, fmt.Errorf("reserve: %w", err)
+	}
+
+	if !dbRec.ExpiresOn.After(now.UTC()) {
+		if _, err := c.store.DeleteExpiredByKey(ctx, rec.Key, now.UTC()); err != nil {
+			return idempotency.Record{}, false, fmt.Errorf("reserve: %w", err)
+		}
+		if !retry {
+			return inProgress(rec), false, nil
+		}
+		return c.reserve(ctx, rec, now, false)
+	}
+
+	return toRecord(dbRec), false, nil
+}
+
+// inProgress returns the record of a key another request holds, without
+// its response.
+func inProgress(rec idempotency.Record) idempotency.Record {
+	return idempotency.Record{
+		Key:         rec.Key,
+		Fingerprint: rec.Fingerprint,
+		CreatedOn:   rec.CreatedOn,
+		ExpiresOn:   rec.ExpiresOn,
+	}
+}
+
+// Sweep removes the expired records every interval until the context is
+// canceled. Requests never pay for a table wide delete this way, Reserve
+// replaces the expired records it runs into in between.
+func (c Core) Sweep(ctx context.Context, log *zap.SugaredLogger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		res, err := c.store.DeleteExpired(ctx, time.Now().UTC())
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Errorw("idempotency", "status", "sweeping expired records", "ERROR", err)
+			}
+			continue
+		}
+		if res.AffectedRows > 0 {
+			log.Infow("idempotency", "status", "swept expired records", "removed", res.AffectedRows)
+		}
+	}
+}
+
+// Complete stores the response of a reserved record.
+func (c Core) Complete(ctx context.Context, rec idempotency.Record) error {
+	res, err := c.store.Complete(ctx, toDBRecord(rec))
+	if err != nil {
+		return fmt.Errorf("complete: %w", err)
+	}
+	if res.AffectedRows == 0 {
+		return errors.New("complete: idempotency record expired before the response was stored")
+	}
+
+	return nil
+}
+
+// Release removes a reserved record so the request can be retried.
+func (c Core) Release(ctx context.Context, key string) error {
+	if _, err := c.store.Delete(ctx, key); err != nil {
+		return fmt.Errorf("release: %w", err)
+	}
+
+	return nil
+}
+
+func toDBRecord(rec idempotency.Record) db.Record {
+	// A header map always marshals, there is nothing to handle.
+	var headers string
+	if len(rec.Header) > 0 {
+		b, _ := json.Marshal(rec.Header)
+		headers = string(b)
+	}
+
+	return db.Record{
+		Key:         rec.Key,
+		Fingerprint: rec.Fingerprint,
+		StatusCode:  rec.StatusCode,
+		ContentType: rec.ContentType,
+		Headers:     headers,
+		Body:        string(rec.Body),
+		CreatedOn:   rec.CreatedOn.UTC(),
+		ExpiresOn:   rec.ExpiresOn.UTC(),
+	}
+}
+
+func toRecord(dbRec db.Record) idempotency.Record {
+	// Records stored before the headers were kept replay without them.
+	var header http.Header
+	if dbRec.Headers != "" {
+		_ = json.Unmarshal([]byte(dbRec.Headers), &header)
+	}
+
+	return idempotency.Record{
+		Key:         dbRec.Key,
+		Fingerprint: dbRec.Fingerprint,
+		StatusCode:  dbRec.StatusCode,
+		ContentType: dbRec.ContentType,
+		Header:      header,
+		Body:        []byte(dbRec.Body),
+		CreatedOn:   dbRec.CreatedOn,
+		ExpiresOn:   dbRec.ExpiresOn,
+	}
+}
@@ -0,0 +1,26 @@
+// Package migrations embeds the versioned database schema of every
+// supported dialect. Scripts follow the Flyway naming convention:
+// V<version>__<name>.sql applies a change and U<version>__<name>.sql
+// undoes it.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+)
+
+//go:embed mysql/*.sql postgres/*.sql sqlite/*.sql
+var scripts embed.FS
+
+// FS returns the migration scripts for the database type.
+func FS(dbType string) (fs.FS, error) {
+	dialect := database.Dialect(dbType)
+	if dialect == "" {
+		return nil, fmt.Errorf("no migrations for database type %q", dbType)
+	}
+
+	return fs.Sub(scripts, dialect)
+}
@@ -0,0 +1 @@
+ALTER TABLE build_status DROP INDEX build_status_alias_uindex;
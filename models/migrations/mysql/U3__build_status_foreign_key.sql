@@ -0,0 +1,2 @@
+ALTER TABLE build DROP FOREIGN KEY build_build_status_id_fk;
+ALTER TABLE build DROP INDEX build_build_status_id_fk;
@@ -0,0 +1,13 @@
+ALTER TABLE api_key DROP INDEX api_key_org_uid_index;
+ALTER TABLE api_key DROP COLUMN org_uid;
+
+ALTER TABLE build_status DROP INDEX build_status_tenant_alias_uindex;
+ALTER TABLE build_status ADD UNIQUE INDEX build_status_alias_uindex (alias);
+ALTER TABLE build_status
+    DROP COLUMN org_uid,
+    DROP COLUMN site_uid;
+
+ALTER TABLE build DROP INDEX build_tenant_index;
+ALTER TABLE build
+    DROP COLUMN org_uid,
+    DROP COLUMN site_uid;
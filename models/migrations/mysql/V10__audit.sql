@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS audit_record (
+    id int unsigned auto_increment primary key,
+    entity varchar(64) not null,
+    entity_id varchar(64) not null,
+    action varchar(32) not null,
+    actor varchar(255) not null default '',
+    tracer_uid varchar(128) not null default '',
+    org_uid varchar(64) not null default '',
+    site_uid varchar(64) not null default '',
+    changes mediumtext not null,
+    created_on datetime not null default current_timestamp,
+    INDEX audit_record_entity_index (entity, entity_id),
+    INDEX audit_record_actor_index (actor),
+    INDEX audit_record_tenant_index (org_uid, site_uid, created_on)
+) engine = innodb;
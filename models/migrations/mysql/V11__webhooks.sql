@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS webhook (
+    id int unsigned auto_increment primary key,
+    url varchar(2048) not null,
+    secret varchar(255) not null,
+    event_types varchar(1024) not null default '',
+    active boolean not null default true,
+    org_uid varchar(64) not null default '',
+    site_uid varchar(64) not null default '',
+    created_on datetime not null default current_timestamp,
+    updated_on datetime not null default current_timestamp,
+    deleted_on datetime,
+    INDEX webhook_tenant_index (org_uid, site_uid)
+) engine = innodb;
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+    id int unsigned auto_increment primary key,
+    webhook_id int unsigned not null,
+    event_uid varchar(64) not null,
+    event varchar(64) not null,
+    payload mediumtext not null,
+    status varchar(16) not null default 'pending',
+    attempts int not null default 0,
+    next_attempt_on datetime,
+    last_attempt_on datetime,
+    response_code int not null default 0,
+    last_error varchar(1024) not null default '',
+    delivered_on datetime,
+    org_uid varchar(64) not null default '',
+    site_uid varchar(64) not null default '',
+    created_on datetime not null default current_timestamp,
+    updated_on datetime not null default current_timestamp,
+    INDEX webhook_delivery_due_index (status, next_attempt_on),
+    INDEX webhook_delivery_webhook_index (webhook_id, created_on),
+    CONSTRAINT webhook_delivery_webhook_id_fk FOREIGN KEY (webhook_id) REFERENCES webhook (id)
+) engine = innodb;
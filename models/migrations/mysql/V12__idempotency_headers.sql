@@ -0,0 +1 @@
+ALTER TABLE idempotency_record ADD COLUMN headers text not null;
@@ -0,0 +1 @@
+ALTER TABLE build_status ADD UNIQUE INDEX build_status_alias_uindex (alias);
@@ -0,0 +1,13 @@
+ALTER TABLE build
+    ADD COLUMN org_uid varchar(64) not null default '',
+    ADD COLUMN site_uid varchar(64) not null default '';
+CREATE INDEX build_tenant_index ON build (org_uid, site_uid);
+
+ALTER TABLE build_status
+    ADD COLUMN org_uid varchar(64) not null default '',
+    ADD COLUMN site_uid varchar(64) not null default '';
+ALTER TABLE build_status DROP INDEX build_status_alias_uindex;
+CREATE UNIQUE INDEX build_status_tenant_alias_uindex ON build_status (org_uid, site_uid, alias);
+
+ALTER TABLE api_key ADD COLUMN org_uid varchar(64) not null default '';
+CREATE INDEX api_key_org_uid_index ON api_key (org_uid);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS idempotency_record (
+    scoped_key char(64) primary key,
+    fingerprint char(64) not null,
+    status_code int not null default 0,
+    content_type varchar(255) not null default '',
+    body mediumtext not null,
+    created_on datetime not null default current_timestamp,
+    expires_on datetime not null,
+    INDEX idempotency_record_expires_on_index (expires_on)
+) engine = innodb;
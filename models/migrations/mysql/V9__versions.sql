@@ -0,0 +1,2 @@
+ALTER TABLE build ADD COLUMN version int unsigned not null default 1;
+ALTER TABLE build_status ADD COLUMN version int unsigned not null default 1;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS build;
+DROP TABLE IF EXISTS build_status;
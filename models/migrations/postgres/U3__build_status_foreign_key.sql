@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS build_build_status_id_index;
+ALTER TABLE build DROP CONSTRAINT IF EXISTS build_build_status_id_fk;
@@ -0,0 +1,3 @@
+ALTER TABLE build_status
+    DROP COLUMN is_terminal,
+    DROP COLUMN allowed_next;
@@ -0,0 +1 @@
+-- Nothing to undo, see V5__normalize_timestamps.sql
@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS api_key_org_uid_index;
+ALTER TABLE api_key DROP COLUMN org_uid;
+
+DROP INDEX IF EXISTS build_status_tenant_alias_uindex;
+CREATE UNIQUE INDEX build_status_alias_uindex ON build_status (alias);
+ALTER TABLE build_status
+    DROP COLUMN org_uid,
+    DROP COLUMN site_uid;
+
+DROP INDEX IF EXISTS build_tenant_index;
+ALTER TABLE build
+    DROP COLUMN org_uid,
+    DROP COLUMN site_uid;
@@ -0,0 +1,2 @@
+ALTER TABLE build DROP COLUMN version;
+ALTER TABLE build_status DROP COLUMN version;
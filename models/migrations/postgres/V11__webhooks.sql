@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS webhook (
+    id serial primary key,
+    url varchar(2048) not null,
+    secret varchar(255) not null,
+    event_types varchar(1024) not null default '',
+    active boolean not null default true,
+    org_uid varchar(64) not null default '',
+    site_uid varchar(64) not null default '',
+    created_on timestamp not null default current_timestamp,
+    updated_on timestamp not null default current_timestamp,
+    deleted_on timestamp
+);
+
+CREATE INDEX webhook_tenant_index ON webhook (org_uid, site_uid);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+    id serial primary key,
+    webhook_id integer not null references webhook (id),
+    event_uid varchar(64) not null,
+    event varchar(64) not null,
+    payload text not null default '',
+    status varchar(16) not null default 'pending',
+    attempts integer not null default 0,
+    next_attempt_on timestamp,
+    last_attempt_on timestamp,
+    response_code integer not null default 0,
+    last_error varchar(1024) not null default '',
+    delivered_on timestamp,
+    org_uid varchar(64) not null default '',
+    site_uid varchar(64) not null default '',
+    created_on timestamp not null default current_timestamp,
+    updated_on timestamp not null default current_timestamp
+);
+
+CREATE INDEX webhook_delivery_due_index ON webhook_delivery (status, next_attempt_on);
+CREATE INDEX webhook_delivery_webhook_index ON webhook_delivery (webhook_id, created_on);
@@ -0,0 +1 @@
+CREATE UNIQUE INDEX build_status_alias_uindex ON build_status (alias);
@@ -0,0 +1,5 @@
+ALTER TABLE build
+    ADD CONSTRAINT build_build_status_id_fk
+    FOREIGN KEY (build_status_id) REFERENCES build_status (id);
+
+CREATE INDEX build_build_status_id_index ON build (build_status_id);
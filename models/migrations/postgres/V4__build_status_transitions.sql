@@ -0,0 +1,6 @@
+ALTER TABLE build_status
+    ADD COLUMN is_terminal boolean not null default false,
+    ADD COLUMN allowed_next varchar(1024) not null default '';
+
+UPDATE build_status SET allowed_next = 'success,failed' WHERE alias = 'processing';
+UPDATE build_status SET is_terminal = true WHERE alias IN ('success', 'failed');
@@ -0,0 +1,2 @@
+-- Timestamps are stored in native datetime columns, the SQLite text values
+-- are the only ones that need normalizing.
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS api_key (
+    id serial primary key,
+    prefix varchar(32) not null,
+    hash char(64) not null,
+    name varchar(255) not null,
+    owner varchar(255) not null,
+    scopes varchar(1024) not null default '',
+    expires_on timestamp,
+    last_used_on timestamp,
+    revoked_on timestamp,
+    created_on timestamp not null default current_timestamp,
+    updated_on timestamp not null default current_timestamp
+);
+
+CREATE UNIQUE INDEX api_key_prefix_uindex ON api_key (prefix);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS build_status_alias_uindex;
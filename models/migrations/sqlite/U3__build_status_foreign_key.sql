@@ -0,0 +1,17 @@
+CREATE TABLE build_old (
+    id integer primary key autoincrement,
+    uuid varchar(255) not null,
+    label varchar(255) not null,
+    commit_sha varchar(255) default '',
+    build_status_id integer not null,
+    created_on datetime not null default current_timestamp,
+    updated_on datetime not null default current_timestamp,
+    deleted_on datetime
+);
+
+INSERT INTO build_old (id, uuid, label, commit_sha, build_status_id, created_on, updated_on, deleted_on)
+    SELECT id, uuid, label, commit_sha, build_status_id, created_on, updated_on, deleted_on FROM build;
+
+DROP TABLE build;
+
+ALTER TABLE build_old RENAME TO build;
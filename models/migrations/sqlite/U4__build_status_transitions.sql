@@ -0,0 +1,2 @@
+ALTER TABLE build_status DROP COLUMN is_terminal;
+ALTER TABLE build_status DROP COLUMN allowed_next;
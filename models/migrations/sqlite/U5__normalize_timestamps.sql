@@ -0,0 +1 @@
+-- The normalized timestamps are kept, they are equal to the original ones.
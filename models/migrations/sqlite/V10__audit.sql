@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS audit_record (
+    id integer primary key autoincrement,
+    entity varchar(64) not null,
+    entity_id varchar(64) not null,
+    action varchar(32) not null,
+    actor varchar(255) not null default '',
+    tracer_uid varchar(128) not null default '',
+    org_uid varchar(64) not null default '',
+    site_uid varchar(64) not null default '',
+    changes text not null default '',
+    created_on datetime not null default current_timestamp
+);
+
+CREATE INDEX audit_record_entity_index ON audit_record (entity, entity_id);
+CREATE INDEX audit_record_actor_index ON audit_record (actor);
+CREATE INDEX audit_record_tenant_index ON audit_record (org_uid, site_uid, created_on);
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS build (
+    id integer primary key autoincrement,
+    uuid varchar(255) not null,
+    label varchar(255) not null,
+    commit_sha varchar(255) default '',
+    build_status_id integer not null,
+    created_on datetime not null default current_timestamp,
+    updated_on datetime not null default current_timestamp,
+    deleted_on datetime
+);
+
+CREATE TABLE IF NOT EXISTS build_status  (
+    id integer primary key autoincrement,
+    alias varchar(255) not null,
+    name varchar(255) not null,
+    created_on datetime not null default current_timestamp,
+    updated_on datetime not null default current_timestamp,
+    deleted_on datetime
+);
+
+INSERT INTO build (uuid, label, commit_sha, build_status_id) VALUES 
+    ('84f29c10-fcd5-4057-a0f5-aa7778ecf3d4', 'Build 1', '1111111', 1),
+    ('9455c109-858a-4c3a-8ebf-8d1eb1f93ae9', 'Build 2', '2222222', 2),
+    ('c4bee891-4024-4afc-ab08-99389dc53131', 'Build 3', '3333333', 3)
+;
+
+INSERT INTO build_status (alias, name) VALUES 
+    ('processing', 'Processing'),
+    ('success', 'Success'),
+    ('failed', 'Failed')
+;
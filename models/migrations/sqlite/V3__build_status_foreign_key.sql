@@ -0,0 +1,21 @@
+-- SQLite can't add a constraint to an existing table, so the table is rebuilt.
+CREATE TABLE build_new (
+    id integer primary key autoincrement,
+    uuid varchar(255) not null,
+    label varchar(255) not null,
+    commit_sha varchar(255) default '',
+    build_status_id integer not null
+        constraint build_build_status_id_fk references build_status (id),
+    created_on datetime not null default current_timestamp,
+    updated_on datetime not null default current_timestamp,
+    deleted_on datetime
+);
+
+INSERT INTO build_new (id, uuid, label, commit_sha, build_status_id, created_on, updated_on, deleted_on)
+    SELECT id, uuid, label, commit_sha, build_status_id, created_on, updated_on, deleted_on FROM build;
+
+DROP TABLE build;
+
+ALTER TABLE build_new RENAME TO build;
+
+CREATE INDEX build_build_status_id_index ON build (build_status_id);
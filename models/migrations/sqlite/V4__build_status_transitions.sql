@@ -0,0 +1,5 @@
+ALTER TABLE build_status ADD COLUMN is_terminal boolean not null default 0;
+ALTER TABLE build_status ADD COLUMN allowed_next varchar(1024) not null default '';
+
+UPDATE build_status SET allowed_next = 'success,failed' WHERE alias = 'processing';
+UPDATE build_status SET is_terminal = 1 WHERE alias IN ('success', 'failed');
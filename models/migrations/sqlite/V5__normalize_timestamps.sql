@@ -0,0 +1,8 @@
+-- current_timestamp defaults are stored without the offset the driver writes,
+-- align them so timestamps compare correctly as text.
+UPDATE build_status SET created_on = created_on || '+00:00' WHERE length(created_on) = 19;
+UPDATE build_status SET updated_on = updated_on || '+00:00' WHERE length(updated_on) = 19;
+UPDATE build_status SET deleted_on = deleted_on || '+00:00' WHERE length(deleted_on) = 19;
+UPDATE build SET created_on = created_on || '+00:00' WHERE length(created_on) = 19;
+UPDATE build SET updated_on = updated_on || '+00:00' WHERE length(updated_on) = 19;
+UPDATE build SET deleted_on = deleted_on || '+00:00' WHERE length(deleted_on) = 19;
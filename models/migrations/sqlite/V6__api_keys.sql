@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS api_key (
+    id integer primary key autoincrement,
+    prefix varchar(32) not null,
+    hash char(64) not null,
+    name varchar(255) not null,
+    owner varchar(255) not null,
+    scopes varchar(1024) not null default '',
+    expires_on datetime,
+    last_used_on datetime,
+    revoked_on datetime,
+    created_on datetime not null default current_timestamp,
+    updated_on datetime not null default current_timestamp
+);
+
+CREATE UNIQUE INDEX api_key_prefix_uindex ON api_key (prefix);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS idempotency_record (
+    scoped_key char(64) primary key,
+    fingerprint char(64) not null,
+    status_code integer not null default 0,
+    content_type varchar(255) not null default '',
+    body text not null default '',
+    created_on datetime not null default current_timestamp,
+    expires_on datetime not null
+);
+
+CREATE INDEX idempotency_record_expires_on_index ON idempotency_record (expires_on);
@@ -0,0 +1,2 @@
+ALTER TABLE build ADD COLUMN version integer not null default 1;
+ALTER TABLE build_status ADD COLUMN version integer not null default 1;
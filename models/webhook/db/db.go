@@ -0,0 +1,558 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+	"github.com/chaitanyamaili/go_rest/pkg/validate"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of states a delivery goes through. Pending deliveries are sent once
+// their next attempt is due, the others are kept as the delivery log.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Store holds details for basic database needs
+type Store struct {
+	log          *zap.SugaredLogger
+	tr           database.Transactor
+	db           sqlx.ExtContext
+	rwmux        *sync.RWMutex
+	isWithinTran bool
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB, rwmux *sync.RWMutex) Store {
+	return Store{
+		log:   log,
+		tr:    db,
+		db:    db,
+		rwmux: rwmux,
+	}
+}
+
+// WithinTran runs passes function and do commit/rollback at the end.
+func (s Store) WithinTran(ctx context.Context, fn func(sqlx.ExtContext) error) error {
+	if s.isWithinTran {
+		return fn(s.db)
+	}
+	s.rwmux.Lock()
+	err := database.WithinTran(ctx, s.log, s.tr, fn)
+	s.rwmux.Unlock()
+
+	return err
+}
+
+// Tran return new Store with transaction in it.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log:          s.log,
+		tr:           s.tr,
+		db:           tx,
+		isWithinTran: true,
+	}
+}
+
+// -----------------------------------------------------------------------
+// Webhook Query Repository
+// -----------------------------------------------------------------------
+
+// Create inserts a new webhook into the database, owned by the tenant of
+// the request.
+func (s Store) Create(ctx context.Context, w Webhook) (database.DBResults, error) {
+	const q = `
+	INSERT INTO webhook
+		(url, secret, event_types, active, org_uid, site_uid, created_on, updated_on)
+	VALUES
+		(:url, :secret, :event_types, :active, :org_uid, :site_uid, :created_on, :updated_on)`
+
+	res, err := database.NamedInsertContext(ctx, s.log, s.db, q, database.TenantFrom(ctx).Data(w))
+	if err != nil {
+		return database.DBResults{}, fmt.Errorf("inserting webhook: %w", err)
+	}
+
+	return res, nil
+}
+
+// Update replaces a webhook record in the database.
+func (s Store) Update(ctx context.Context, w Webhook) (database.DBResults, error) {
+	t := database.TenantFrom(ctx)
+	q := database.TenantQuery(t, "", `
+	UPDATE
+		webhook
+	SET
+		url = :url,
+		secret = :secret,
+		event_types = :event_types,
+		active = :active,
+		updated_on = :updated_on
+	WHERE
+		id = :id
+		and deleted_on is null:tenant`)
+
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, t.Data(w))
+	if err != nil {
+		return database.DBResults{}, fmt.Errorf("updating webhook id[%s]: %w", w.ID, err)
+	}
+
+	return res, nil
+}
+
+// Delete removes a webhook from the database. Its deliveries are kept, the
+// pending ones fail when they are picked up.
+func (s Store) Delete(ctx context.Context, id string, now time.Time) (database.DBResults, error) {
+	data := struct {
+		ID        string    `db:"id"`
+		DeletedOn time.Time `db:"deleted_on"`
+	}{
+		ID:        id,
+		DeletedOn: now,
+	}
+
+	t := database.TenantFrom(ctx)
+	q := database.TenantQuery(t, "", `
+	UPDATE
+		webhook
+	SET
+		deleted_on = :deleted_on,
+		updated_on = :deleted_on
+	WHERE
+		id = :id
+		and deleted_on is null:tenant`)
+
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, t.Data(data))
+	if err != nil {
+		return database.DBResults{}, fmt.Errorf("deleting webhook id[%s]: %w", id, err)
+	}
+
+	return res, nil
+}
+
+// Fields lists the fields webhook reads can select. The secret is never
+// selected through it.
+var Fields = database.Fields{
+	Columns: map[string]string{
+		"id":          "id",
+		"url":         "url",
+		"event_types": "event_types",
+		"active":      "active",
+		"org_uid":     "org_uid",
+		"site_uid":    "site_uid",
+		"created_on":  "created_on",
+		"updated_on":  "updated_on",
+	},
+}
+
+// Query retrieves a page of the webhooks of the tenant from the database.
+func (s Store) Query(ctx context.Context, fs database.Fieldset, pagi database.Pagination) ([]Webhook, database.Page, error) {
+	t := database.TenantFrom(ctx)
+	q := database.PaginationQuery(pagi, database.KeysetQuery(pagi, "", `
+	SELECT
+		:columns
+	FROM
+		webhook
+	WHERE
+		deleted_on is null:tenant:keyset
+	ORDER BY
+		:sort :direction,
+		id :direction
+	LIMIT
+		:per_page OFFSET :page`))
+
+	const qc = `
+	SELECT
+		COUNT(*) AS total
+	FROM
+		webhook
+	WHERE
+		deleted_on is null:tenant`
+
+	pq := database.PageQuery{
+		Query:    database.FieldsQuery(Fields, fs, database.TenantQuery(t, "", q), "id", pagi.Sort),
+		Count:    database.TenantQuery(t, "", qc),
+		Table:    "webhook",
+		Filtered: !t.All,
+	}
+
+	// Slice to hold results
+	var res []Webhook
+	page, err := database.NamedQueryPage(ctx, s.log, s.db, pq, pagi, t.Data(pagi), &res)
+	if err != nil {
+		return nil, database.Page{}, fmt.Errorf("selecting webhooks: %w", err)
+	}
+
+	return res, page, nil
+}
+
+// QueryByID retrieves a webhook from the database.
+func (s Store) QueryByID(ctx context.Context, id string, fs database.Fieldset) (Webhook, error) {
+	data := struct {
+		ID string `db:"id"`
+	}{ID: id}
+	t := database.TenantFrom(ctx)
+	q := database.FieldsQuery(Fields, fs, database.TenantQuery(t, "", `
+	SELECT
+		:columns
+	FROM
+		webhook
+	WHERE
+		id = :id
+		and deleted_on is null:tenant`), "id")
+
+	var res Webhook
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, t.Data(data), &res); err != nil {
+		if database.IsError(err) && err.Error() == database.ErrDBNotFound.Error() {
+			return Webhook{}, database.ErrDBNotFound
+		}
+		return Webhook{}, fmt.Errorf("selecting webhook by ID[%q]: %w", id, err)
+	}
+
+	return res, nil
+}
+
+// QueryWithSecretByID retrieves a webhook with its secret from the
+// database.
+func (s Store) QueryWithSecretByID(ctx context.Context, id string) (Webhook, error) {
+	data := struct {
+		ID string `db:"id"`
+	}{ID: id}
+	t := database.TenantFrom(ctx)
+	q := database.TenantQuery(t, "", `
+	SELECT
+		*
+	FROM
+		webhook
+	WHERE
+		id = :id
+		and deleted_on is null:tenant`)
+
+	var res Webhook
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, t.Data(data), &res); err != nil {
+		if database.IsError(err) && err.Error() == database.ErrDBNotFound.Error() {
+			return Webhook{}, database.ErrDBNotFound
+		}
+		return Webhook{}, fmt.Errorf("selecting webhook by ID[%q]: %w", id, err)
+	}
+
+	return res, nil
+}
+
+// QueryActive retrieves the active webhooks of the tenant from the
+// database, the ones events are delivered to.
+func (s Store) QueryActive(ctx context.Context) ([]Webhook, error) {
+	data := struct {
+		Active bool `db:"active"`
+	}{Active: true}
+	t := database.TenantFrom(ctx)
+
+	// Events always belong to a single tenant, even when a platform admin
+	// reading across tenants triggered them.
+	t.All = false
+	q := database.TenantQuery(t, "", `
+	SELECT
+		id,
+		event_types
+	FROM
+		webhook
+	WHERE
+		active = :active
+		and deleted_on is null:tenant
+	ORDER BY
+		id`)
+
+	var res []Webhook
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, t.Data(data), &res); err != nil {
+		return nil, fmt.Errorf("selecting active webhooks: %w", err)
+	}
+
+	return res, nil
+}
+
+// -----------------------------------------------------------------------
+// Delivery Query Repository
+// -----------------------------------------------------------------------
+
+// CreateDelivery inserts a new pending delivery into the database, owned by
+// the tenant of the request.
+func (s Store) CreateDelivery(ctx context.Context, d Delivery) (database.DBResults, error) {
+	const q = `
+	INSERT INTO webhook_delivery
+		(webhook_id, event_uid, event, payload, status, next_attempt_on, org_uid, site_uid, created_on, updated_on)
+	VALUES
+		(:webhook_id, :event_uid, :event, :payload, :status, :next_attempt_on, :org_uid, :site_uid, :created_on, :updated_on)`
+
+	t := database.TenantFrom(ctx)
+	t.All = false
+	res, err := database.NamedInsertContext(ctx, s.log, s.db, q, t.Data(d))
+	if err != nil {
+		return database.DBResults{}, fmt.Errorf("inserting webhook delivery: %w", err)
+	}
+
+	return res, nil
+}
+
+// Redeliver puts a delivery back in the queue, due now and with all its
+// attempts available again.
+func (s Store) Redeliver(ctx context.Context, d Delivery) (database.DBResults, error) {
+	t := database.TenantFrom(ctx)
+	q := database.TenantQuery(t, "", `
+	UPDATE
+		webhook_delivery
+	SET
+		status = :status,
+		attempts = 0,
+		next_attempt_on = :next_attempt_on,
+		last_error = '',
+		updated_on = :updated_on
+	WHERE
+		id = :id
+		and webhook_id = :webhook_id:tenant`)
+
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, t.Data(d))
+	if err != nil {
+		return database.DBResults{}, fmt.Errorf("redelivering webhook delivery id[%s]: %w", d.ID, err)
+	}
+
+	return res, nil
+}
+
+// DeliveryFields lists the fields delivery reads can select.
+var DeliveryFields = database.Fields{
+	Columns: map[string]string{
+		"id":              "id",
+		"webhook_id":      "webhook_id",
+		"event_uid":       "event_uid",
+		"event":           "event",
+		"payload":         "payload",
+		"status":          "status",
+		"attempts":        "attempts",
+		"next_attempt_on": "next_attempt_on",
+		"last_attempt_on": "last_attempt_on",
+		"response_code":   "response_code",
+		"last_error":      "last_error",
+		"delivered_on":    "delivered_on",
+		"org_uid":         "org_uid",
+		"site_uid":        "site_uid",
+		"created_on":      "created_on",
+		"updated_on":      "updated_on",
+	},
+}
+
+// DeliveryFilters lists the query parameters deliveries can be filtered by.
+var DeliveryFilters = map[string]database.FilterField{
+	"status":         {Column: "status", Multi: true, Check: validate.CheckSlug},
+	"event":          {Column: "event", Multi: true},
+	"event_uid":      {Column: "event_uid"},
+	"created_after":  {Column: "created_on", Op: database.FilterAfter, Type: database.FilterTime},
+	"created_before": {Column: "created_on", Op: database.FilterBefore, Type: database.FilterTime},
+}
+
+// QueryDeliveries retrieves a page of the deliveries of a webhook from the
+// database.
+func (s Store) QueryDeliveries(ctx context.Context, webhookID string, filter database.Filter, fs database.Fieldset, pagi database.Pagination) ([]Delivery, database.Page, error) {
+	t := database.TenantFrom(ctx)
+	q := database.PaginationQuery(pagi, database.KeysetQuery(pagi, "", `
+	SELECT
+		:columns
+	FROM
+		webhook_delivery
+	WHERE
+		webhook_id = :webhook_id:tenant:filters:keyset
+	ORDER BY
+		:sort :direction,
+		id :direction
+	LIMIT
+		:per_page OFFSET :page`))
+
+	const qc = `
+	SELECT
+		COUNT(*) AS total
+	FROM
+		webhook_delivery
+	WHERE
+		webhook_id = :webhook_id:tenant:filters`
+
+	pq := database.PageQuery{
+		Query:    database.FieldsQuery(DeliveryFields, fs, database.TenantQuery(t, "", database.FilterQuery(filter, q)), "id", pagi.Sort),
+		Count:    database.TenantQuery(t, "", database.FilterQuery(filter, qc)),
+		Table:    "webhook_delivery",
+		Filtered: true,
+	}
+
+	// Slice to hold results
+	var res []Delivery
+	data := t.Data(filter.Data(pagi))
+	data["webhook_id"] = webhookID
+	page, err := database.NamedQueryPage(ctx, s.log, s.db, pq, pagi, data, &res)
+	if err != nil {
+		return nil, database.Page{}, fmt.Errorf("selecting webhook deliveries: %w", err)
+	}
+
+	return res, page, nil
+}
+
+// QueryDeliveryByID retrieves a delivery of a webhook from the database.
+func (s Store) QueryDeliveryByID(ctx context.Context, webhookID string, id string) (Delivery, error) {
+	data := struct {
+		ID        string `db:"id"`
+		WebhookID string `db:"webhook_id"`
+	}{
+		ID:        id,
+		WebhookID: webhookID,
+	}
+	t := database.TenantFrom(ctx)
+	q := database.TenantQuery(t, "", `
+	SELECT
+		*
+	FROM
+		webhook_delivery
+	WHERE
+		id = :id
+		and webhook_id = :webhook_id:tenant`)
+
+	var res Delivery
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, t.Data(data), &res); err != nil {
+		if database.IsError(err) && err.Error() == database.ErrDBNotFound.Error() {
+			return Delivery{}, database.ErrDBNotFound
+		}
+		return Delivery{}, fmt.Errorf("selecting webhook delivery by ID[%q]: %w", id, err)
+	}
+
+	return res, nil
+}
+
+// -----------------------------------------------------------------------
+// Dispatcher Query Repository
+// -----------------------------------------------------------------------
+// The dispatcher works for every tenant so none of these are scoped.
+
+// QueryDue retrieves the pending deliveries whose next attempt is due, the
+// oldest first, along with the webhook they are sent to.
+func (s Store) QueryDue(ctx context.Context, now time.Time, limit int) ([]Due, error) {
+	data := struct {
+		Status string    `db:"status"`
+		Now    time.Time `db:"now"`
+		Limit  int       `db:"limit"`
+	}{
+		Status: StatusPending,
+		Now:    now,
+		Limit:  limit,
+	}
+
+	const q = `
+	SELECT
+		d.id,
+		d.webhook_id,
+		d.event_uid,
+		d.event,
+		d.payload,
+		d.status,
+		d.attempts,
+		d.next_attempt_on,
+		d.created_on,
+		d.updated_on,
+		w.url,
+		w.secret,
+		w.active,
+		w.deleted_on
+	FROM
+		webhook_delivery d
+		JOIN webhook w ON w.id = d.webhook_id
+	WHERE
+		d.status = :status
+		and d.next_attempt_on <= :now
+	ORDER BY
+		d.next_attempt_on,
+		d.id
+	LIMIT
+		:limit`
+
+	var res []Due
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, data, &res); err != nil {
+		return nil, fmt.Errorf("selecting due webhook deliveries: %w", err)
+	}
+
+	return res, nil
+}
+
+// Claim takes a due delivery for an attempt, hiding it from the other
+// pollers until the lease ends. Nothing is affected when another poller
+// claimed it first.
+func (s Store) Claim(ctx context.Context, d Delivery, now time.Time, lease time.Time) (database.DBResults, error) {
+	data := struct {
+		ID       string    `db:"id"`
+		Attempts int       `db:"attempts"`
+		Status   string    `db:"status"`
+		Now      time.Time `db:"now"`
+		Lease    time.Time `db:"lease"`
+	}{
+		ID:       d.ID,
+		Attempts: d.Attempts,
+		Status:   StatusPending,
+		Now:      now,
+		Lease:    lease,
+	}
+
+	const q = `
+	UPDATE
+		webhook_delivery
+	SET
+		attempts = attempts + 1,
+		next_attempt_on = :lease,
+		last_attempt_on = :now,
+		updated_on = :now
+	WHERE
+		id = :id
+		and status = :status
+		and attempts = :attempts
+		and next_attempt_on <= :now`
+
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, data)
+	if err != nil {
+		return database.DBResults{}, fmt.Errorf("claiming webhook delivery id[%s]: %w", d.ID, err)
+	}
+
+	return res, nil
+}
+
+// Complete records the outcome of the attempt a delivery was claimed for.
+// Nothing is affected when the delivery was redelivered in the meantime.
+func (s Store) Complete(ctx context.Context, d Delivery) (database.DBResults, error) {
+	data := struct {
+		Delivery
+		Pending string `db:"pending"`
+	}{
+		Delivery: d,
+		Pending:  StatusPending,
+	}
+
+	const q = `
+	UPDATE
+		webhook_delivery
+	SET
+		status = :status,
+		next_attempt_on = :next_attempt_on,
+		response_code = :response_code,
+		last_error = :last_error,
+		delivered_on = :delivered_on,
+		updated_on = :updated_on
+	WHERE
+		id = :id
+		and status = :pending
+		and attempts = :attempts`
+
+	res, err := database.NamedExecContext(ctx, s.log, s.db, q, data)
+	if err != nil {
+		return database.DBResults{}, fmt.Errorf("completing webhook delivery id[%s]: %w", d.ID, err)
+	}
+
+	return res, nil
+}
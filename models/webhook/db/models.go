@@ -0,0 +1,65 @@
+package db
+
+import "time"
+
+// Webhook represent the structure we need for moving data
+// between the app and the database.
+type Webhook struct {
+	ID         string     `db:"id"`
+	URL        string     `db:"url"`
+	Secret     string     `db:"secret"`
+	EventTypes string     `db:"event_types"`
+	Active     bool       `db:"active"`
+	OrgUID     string     `db:"org_uid"`
+	SiteUID    string     `db:"site_uid"`
+	CreatedOn  time.Time  `db:"created_on"`
+	UpdatedOn  time.Time  `db:"updated_on"`
+	DeletedOn  *time.Time `db:"deleted_on"`
+}
+
+// Keyset returns the id and the sort value cursors are issued from.
+func (w Webhook) Keyset(sort string) (string, time.Time) {
+	if sort == "updated_on" {
+		return w.ID, w.UpdatedOn
+	}
+	return w.ID, w.CreatedOn
+}
+
+// Delivery represent the structure we need for moving data
+// between the app and the database.
+type Delivery struct {
+	ID            string     `db:"id"`
+	WebhookID     string     `db:"webhook_id"`
+	EventUID      string     `db:"event_uid"`
+	Event         string     `db:"event"`
+	Payload       string     `db:"payload"`
+	Status        string     `db:"status"`
+	Attempts      int        `db:"attempts"`
+	NextAttemptOn *time.Time `db:"next_attempt_on"`
+	LastAttemptOn *time.Time `db:"last_attempt_on"`
+	ResponseCode  int        `db:"response_code"`
+	LastError     string     `db:"last_error"`
+	DeliveredOn   *time.Time `db:"delivered_on"`
+	OrgUID        string     `db:"org_uid"`
+	SiteUID       string     `db:"site_uid"`
+	CreatedOn     time.Time  `db:"created_on"`
+	UpdatedOn     time.Time  `db:"updated_on"`
+}
+
+// Keyset returns the id and the sort value cursors are issued from.
+func (d Delivery) Keyset(sort string) (string, time.Time) {
+	if sort == "updated_on" {
+		return d.ID, d.UpdatedOn
+	}
+	return d.ID, d.CreatedOn
+}
+
+// Due is a delivery waiting to be sent along with the subscription it is
+// sent to.
+type Due struct {
+	Delivery
+	URL       string     `db:"url"`
+	Secret    string     `db:"secret"`
+	Active    bool       `db:"active"`
+	DeletedOn *time.Time `db:"deleted_on"`
+}
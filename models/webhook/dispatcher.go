@@ -0,0 +1,176 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/models/webhook/db"
+	hook "github.com/chaitanyamaili/go_rest/pkg/webhook"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// maxErrorLength bounds the error kept with a delivery, the column holds
+// 1024 characters.
+const maxErrorLength = 1024
+
+// Dispatcher sends the pending deliveries in the background. Deliveries
+// are claimed in the database before they are sent so several instances of
+// the service can run a dispatcher, and one that stopped mid attempt has its
+// deliveries picked up again once their lease ends.
+type Dispatcher struct {
+	log    *zap.SugaredLogger
+	store  db.Store
+	client *hook.Client
+	cfg    hook.Config
+}
+
+// NewDispatcher constructs a dispatcher sending deliveries as configured.
+func NewDispatcher(log *zap.SugaredLogger, sqlxDB *sqlx.DB, rwmux *sync.RWMutex, cfg hook.Config, userAgent string) (*Dispatcher, error) {
+	client, err := hook.NewClient(cfg, userAgent)
+	if err != nil {
+		return nil, fmt.Errorf("constructing webhook client: %w", err)
+	}
+
+	return &Dispatcher{
+		log:    log,
+		store:  db.NewStore(log, sqlxDB, rwmux),
+		client: client,
+		cfg:    cfg,
+	}, nil
+}
+
+// Run polls for due deliveries until the context is canceled, then waits
+// for the attempts under way to complete.
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	sem := make(chan struct{}, d.cfg.Workers)
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.poll(ctx, &wg, sem)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll claims a batch of due deliveries and sends them, at most as many
+// at once as there are workers.
+func (d *Dispatcher) poll(ctx context.Context, wg *sync.WaitGroup, sem chan struct{}) {
+	now := time.Now().UTC()
+
+	due, err := d.store.QueryDue(ctx, now, d.cfg.BatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			d.log.Errorw("webhook", "status", "polling deliveries", "ERROR", err)
+		}
+		return
+	}
+
+	for _, dd := range due {
+		select {
+		case <-ctx.Done():
+			return
+		case sem <- struct{}{}:
+		}
+
+		// The lease starts when a worker is free, not when the batch was
+		// read, so it always covers the whole attempt.
+		now := time.Now().UTC()
+		res, err := d.store.Claim(ctx, dd.Delivery, now, now.Add(d.cfg.Lease()))
+		if err != nil || res.AffectedRows == 0 {
+			<-sem
+			if err != nil {
+				d.log.Errorw("webhook", "status", "claiming delivery", "delivery", dd.ID, "ERROR", err)
+			}
+			continue
+		}
+		dd.Attempts++
+
+		wg.Add(1)
+		go func(dd db.Due) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			d.deliver(dd, now)
+		}(dd)
+	}
+}
+
+// deliver makes an attempt at a claimed delivery and records its outcome.
+// It is not tied to the context of Run so a shutdown lets it complete, the
+// client bounds the attempt.
+func (d *Dispatcher) deliver(dd db.Due, now time.Time) {
+	dbD := dd.Delivery
+
+	var (
+		code int
+		err  error
+	)
+	if !dd.Active || dd.DeletedOn != nil {
+		err = ErrInactive
+	} else {
+		code, err = d.client.Send(context.Background(), hook.Message{
+			URL:        dd.URL,
+			Secret:     dd.Secret,
+			Event:      dd.Event,
+			EventID:    dd.EventUID,
+			DeliveryID: dd.ID,
+			Body:       []byte(dd.Payload),
+		}, now)
+	}
+
+	done := time.Now().UTC()
+	dbD.ResponseCode = code
+	dbD.UpdatedOn = done
+	switch {
+	case err == nil:
+		dbD.Status = db.StatusSucceeded
+		dbD.NextAttemptOn = nil
+		dbD.LastError = ""
+		dbD.DeliveredOn = &done
+
+	// An address that isn't allowed won't be by the next attempt.
+	case errors.Is(err, ErrInactive) || errors.Is(err, hook.ErrForbiddenAddress) || dbD.Attempts >= d.cfg.MaxAttempts:
+		dbD.Status = db.StatusFailed
+		dbD.NextAttemptOn = nil
+		dbD.LastError = truncate(err.Error())
+
+	default:
+		next := done.Add(d.cfg.Backoff(dbD.Attempts))
+		dbD.Status = db.StatusPending
+		dbD.NextAttemptOn = &next
+		dbD.LastError = truncate(err.Error())
+	}
+
+	if err != nil {
+		d.log.Infow("webhook", "status", "delivery failed", "delivery", dd.ID, "webhook", dd.WebhookID,
+			"event", dd.Event, "attempt", dbD.Attempts, "code", code, "next", dbD.NextAttemptOn, "ERROR", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.Timeout)
+	defer cancel()
+
+	if _, err := d.store.Complete(ctx, dbD); err != nil {
+		d.log.Errorw("webhook", "status", "recording delivery", "delivery", dd.ID, "ERROR", err)
+	}
+}
+
+// truncate shortens an error to what the delivery log keeps.
+func truncate(s string) string {
+	if len(s) <= maxErrorLength {
+		return s
+	}
+	return s[:maxErrorLength]
+}
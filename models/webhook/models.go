@@ -0,0 +1,254 @@
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/models/webhook/db"
+)
+
+// Set of events webhooks can subscribe to.
+const (
+	EventBuildCreated       = "build.created"
+	EventBuildUpdated       = "build.updated"
+	EventBuildStatusChanged = "build.status_changed"
+	EventBuildDeleted       = "build.deleted"
+	EventBuildUnDeleted     = "build.undeleted"
+)
+
+// Events lists every event webhooks can subscribe to.
+var Events = []string{
+	EventBuildCreated,
+	EventBuildUpdated,
+	EventBuildStatusChanged,
+	EventBuildDeleted,
+	EventBuildUnDeleted,
+}
+
+// Set of states a delivery goes through.
+const (
+	DeliveryPending   = db.StatusPending
+	DeliverySucceeded = db.StatusSucceeded
+	DeliveryFailed    = db.StatusFailed
+)
+
+// Webhook is a subscription of a url to some of the build events. The
+// secret signing the deliveries is never returned past its creation.
+//
+//swagger:model Webhook
+type Webhook struct {
+	// Primary Key
+	// type: integer
+	// example: 1
+	ID string `json:"id"`
+	// Url the events are posted to
+	// example: https://ci.example.com/hooks/builds
+	URL string `json:"url"`
+	// Events delivered to the url
+	// example: ["build.created", "build.status_changed"]
+	EventTypes []string `json:"event_types"`
+	// Events are only delivered to active webhooks
+	// example: true
+	Active bool `json:"active"`
+	// Org the webhook belongs to
+	// example: 8d8ac610-566d-4ef0-9c22-186b2a5ed793
+	OrgUID string `json:"org_uid"`
+	// Site the webhook belongs to
+	// example: 4b4d4a3e-8f2b-4f5c-9a0d-3a1b2c3d4e5f
+	SiteUID string `json:"site_uid"`
+	// Database created value
+	// example: 2021-05-25T00:53:16.535668Z
+	CreatedOn time.Time `json:"created_on"`
+	// Database last updated value
+	// example: 2021-05-25T00:53:16.535668Z
+	UpdatedOn time.Time `json:"updated_on"`
+}
+
+// CreatedWebhook is a webhook along with the secret signing its
+// deliveries, only returned when the webhook is created.
+//
+//swagger:model CreatedWebhook
+type CreatedWebhook struct {
+	Webhook
+	// Key of the HMAC-SHA256 signature of the deliveries, shown only once
+	// example: whsec_8c1e0f...
+	Secret string `json:"secret"`
+}
+
+// NewWebhook contains information needed to create a new webhook.
+//
+//swagger:model NewWebhook
+type NewWebhook struct {
+	// Url the events are posted to, http or https
+	// in: string
+	// required: true
+	// example: https://ci.example.com/hooks/builds
+	URL string `json:"url" validate:"required,url,max=2048"`
+	// Key of the HMAC-SHA256 signature of the deliveries, generated when
+	// empty
+	// in: string
+	// example: 3b1f0c8e2d4a6b9c7e5f1a2b
+	Secret string `json:"secret" validate:"omitempty,min=16,max=255"`
+	// Events delivered to the url
+	// in: array
+	// required: true
+	// example: ["build.created", "build.status_changed"]
+	EventTypes []string `json:"event_types" validate:"required,min=1,dive,required,notblank"`
+	// Events are only delivered to active webhooks, true by default
+	// in: boolean
+	// example: true
+	Active *bool `json:"active"`
+}
+
+// UpdateWebhook defines what information may be provided to modify an
+// existing webhook. All fields are optional.
+//
+//swagger:model UpdateWebhook
+type UpdateWebhook struct {
+	// Url the events are posted to, http or https
+	// in: string
+	// example: https://ci.example.com/hooks/builds
+	URL *string `json:"url" validate:"omitempty,url,max=2048"`
+	// Key of the HMAC-SHA256 signature of the deliveries
+	// in: string
+	// example: 3b1f0c8e2d4a6b9c7e5f1a2b
+	Secret *string `json:"secret" validate:"omitempty,min=16,max=255"`
+	// Events delivered to the url
+	// in: array
+	// example: ["build.status_changed"]
+	EventTypes []string `json:"event_types" validate:"omitempty,min=1,dive,required,notblank"`
+	// Events are only delivered to active webhooks
+	// in: boolean
+	// example: false
+	Active *bool `json:"active"`
+}
+
+// Delivery is an event sent, or waiting to be sent, to a webhook. It keeps
+// the outcome of its last attempt.
+//
+//swagger:model WebhookDelivery
+type Delivery struct {
+	// Primary Key
+	// type: integer
+	// example: 1
+	ID string `json:"id"`
+	// Webhook the event is delivered to
+	// type: integer
+	// example: 1
+	WebhookID string `json:"webhook_id"`
+	// ID of the event, the same for every webhook it is delivered to
+	// example: 0f8fad5b-d9cb-469f-a165-70867728950e
+	EventUID string `json:"event_uid"`
+	// Event delivered
+	// example: build.status_changed
+	Event string `json:"event"`
+	// Body posted to the url
+	Payload json.RawMessage `json:"payload"`
+	// One of pending, succeeded or failed
+	// example: pending
+	Status string `json:"status"`
+	// Number of attempts made
+	// example: 2
+	Attempts int `json:"attempts"`
+	// When the next attempt is due, while pending
+	// example: 2021-05-25T00:54:16Z
+	NextAttemptOn *time.Time `json:"next_attempt_on"`
+	// When the last attempt was made
+	// example: 2021-05-25T00:53:16Z
+	LastAttemptOn *time.Time `json:"last_attempt_on"`
+	// Status code the url answered the last attempt with, 0 when it didn't
+	// answer
+	// example: 503
+	ResponseCode int `json:"response_code"`
+	// Why the last attempt failed
+	// example: receiver answered 503 Service Unavailable
+	LastError string `json:"last_error"`
+	// When the url accepted the event
+	// example: 2021-05-25T00:53:16Z
+	DeliveredOn *time.Time `json:"delivered_on"`
+	// Database created value
+	// example: 2021-05-25T00:53:16.535668Z
+	CreatedOn time.Time `json:"created_on"`
+	// Database last updated value
+	// example: 2021-05-25T00:53:16.535668Z
+	UpdatedOn time.Time `json:"updated_on"`
+}
+
+// Event is the body posted to the webhooks.
+type Event struct {
+	// ID of the event, also sent as the X-Webhook-ID header
+	ID string `json:"id"`
+	// Event delivered, also sent as the X-Webhook-Event header
+	Event string `json:"event"`
+	// When the event happened
+	CreatedOn time.Time `json:"created_on"`
+	// State of the entity once the event happened
+	Data interface{} `json:"data"`
+}
+
+func toWebhook(dbW db.Webhook) Webhook {
+	return Webhook{
+		ID:         dbW.ID,
+		URL:        dbW.URL,
+		EventTypes: splitEvents(dbW.EventTypes),
+		Active:     dbW.Active,
+		OrgUID:     dbW.OrgUID,
+		SiteUID:    dbW.SiteUID,
+		CreatedOn:  dbW.CreatedOn,
+		UpdatedOn:  dbW.UpdatedOn,
+	}
+}
+
+func toWebhookSlice(dbWs []db.Webhook) []Webhook {
+	ws := make([]Webhook, len(dbWs))
+	for i, dbW := range dbWs {
+		ws[i] = toWebhook(dbW)
+	}
+	return ws
+}
+
+func toDelivery(dbD db.Delivery) Delivery {
+	d := Delivery{
+		ID:            dbD.ID,
+		WebhookID:     dbD.WebhookID,
+		EventUID:      dbD.EventUID,
+		Event:         dbD.Event,
+		Status:        dbD.Status,
+		Attempts:      dbD.Attempts,
+		LastAttemptOn: dbD.LastAttemptOn,
+		ResponseCode:  dbD.ResponseCode,
+		LastError:     dbD.LastError,
+		DeliveredOn:   dbD.DeliveredOn,
+		CreatedOn:     dbD.CreatedOn,
+		UpdatedOn:     dbD.UpdatedOn,
+	}
+	if dbD.Status == db.StatusPending {
+		d.NextAttemptOn = dbD.NextAttemptOn
+	}
+	if dbD.Payload != "" {
+		d.Payload = json.RawMessage(dbD.Payload)
+	}
+	return d
+}
+
+func toDeliverySlice(dbDs []db.Delivery) []Delivery {
+	ds := make([]Delivery, len(dbDs))
+	for i, dbD := range dbDs {
+		ds[i] = toDelivery(dbD)
+	}
+	return ds
+}
+
+// joinEvents stores the events of a webhook as a comma separated list.
+func joinEvents(events []string) string {
+	return strings.Join(events, ",")
+}
+
+// splitEvents reads back a list of events stored by joinEvents.
+func splitEvents(events string) []string {
+	if events == "" {
+		return []string{}
+	}
+	return strings.Split(events, ",")
+}
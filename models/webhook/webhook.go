@@ -0,0 +1,369 @@
+// Package webhook manages the subscriptions of urls to the build events and
+// the deliveries of those events. Deliveries are written by the cores of the
+// entities, in the transaction of the change they describe, and sent later
+// by the Dispatcher so a slow receiver never holds up a request.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/models/webhook/db"
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+	"github.com/chaitanyamaili/go_rest/pkg/validate"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of error variables for CRUD operations.
+var (
+	ErrNotFound         = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrInvalidID        = errors.New("ID is not in its proper form")
+	ErrInactive         = errors.New("webhook is not active")
+)
+
+// Fields lists the fields webhook reads can select.
+var Fields = db.Fields
+
+// DeliveryFields lists the fields delivery reads can select.
+var DeliveryFields = db.DeliveryFields
+
+// DeliveryFilters lists the query parameters deliveries can be filtered by.
+var DeliveryFilters = db.DeliveryFilters
+
+// Generated secrets look like whsec_<hex>.
+const (
+	secretTag   = "whsec"
+	secretBytes = 32
+)
+
+// Core manages the set of APIs for webhook access.
+type Core struct {
+	store db.Store
+}
+
+// NewCore constructs a core for webhook api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB, rwmux *sync.RWMutex) Core {
+	return Core{
+		store: db.NewStore(log, sqlxDB, rwmux),
+	}
+}
+
+// Tran return new Core writing in the transaction.
+func (c Core) Tran(tx sqlx.ExtContext) Core {
+	return Core{
+		store: c.store.Tran(tx),
+	}
+}
+
+// -----------------------------------------------------------------------
+// CRUD Methods
+// -----------------------------------------------------------------------
+
+// Create inserts a new webhook into the database. The returned webhook is
+// the only time the secret is available.
+func (c Core) Create(ctx context.Context, nw NewWebhook, now time.Time) (CreatedWebhook, error) {
+	if err := validate.Check(nw); err != nil {
+		return CreatedWebhook{}, err
+	}
+	if err := checkWebhook(nw.URL, nw.EventTypes); err != nil {
+		return CreatedWebhook{}, err
+	}
+
+	secret := nw.Secret
+	if secret == "" {
+		var err error
+		if secret, err = generateSecret(); err != nil {
+			return CreatedWebhook{}, err
+		}
+	}
+
+	t := database.TenantFrom(ctx)
+	dbW := db.Webhook{
+		URL:        strings.TrimSpace(nw.URL),
+		Secret:     secret,
+		EventTypes: joinEvents(nw.EventTypes),
+		Active:     nw.Active == nil || *nw.Active,
+		OrgUID:     t.OrgUID,
+		SiteUID:    t.SiteUID,
+		CreatedOn:  now,
+		UpdatedOn:  now,
+	}
+
+	res, err := c.store.Create(ctx, dbW)
+	if err != nil {
+		return CreatedWebhook{}, fmt.Errorf("create: %w", err)
+	}
+	dbW.ID = fmt.Sprintf("%d", res.LastInsertID)
+
+	return CreatedWebhook{Webhook: toWebhook(dbW), Secret: secret}, nil
+}
+
+// Update modifies a webhook in the database.
+func (c Core) Update(ctx context.Context, id string, uw UpdateWebhook, now time.Time) (Webhook, error) {
+	if err := validate.Check(uw); err != nil {
+		return Webhook{}, err
+	}
+	if err := validate.CheckID(id); err != nil {
+		return Webhook{}, ErrInvalidID
+	}
+
+	dbW, err := c.store.QueryWithSecretByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return Webhook{}, ErrNotFound
+		}
+		return Webhook{}, fmt.Errorf("updating webhook id[%s]: %w", id, err)
+	}
+
+	if uw.URL != nil {
+		dbW.URL = strings.TrimSpace(*uw.URL)
+	}
+	if uw.Secret != nil {
+		dbW.Secret = *uw.Secret
+	}
+	if uw.EventTypes != nil {
+		dbW.EventTypes = joinEvents(uw.EventTypes)
+	}
+	if uw.Active != nil {
+		dbW.Active = *uw.Active
+	}
+	if err := checkWebhook(dbW.URL, splitEvents(dbW.EventTypes)); err != nil {
+		return Webhook{}, err
+	}
+	dbW.UpdatedOn = now
+
+	res, err := c.store.Update(ctx, dbW)
+	if err != nil {
+		return Webhook{}, fmt.Errorf("update id[%s]: %w", id, err)
+	}
+	if res.AffectedRows == 0 {
+		return Webhook{}, ErrNotFound
+	}
+
+	return toWebhook(dbW), nil
+}
+
+// Delete removes a webhook from the database, nothing is delivered to it
+// anymore.
+func (c Core) Delete(ctx context.Context, id string, now time.Time) error {
+	if err := validate.CheckID(id); err != nil {
+		return ErrInvalidID
+	}
+
+	res, err := c.store.Delete(ctx, id, now)
+	if err != nil {
+		return fmt.Errorf("delete id[%s]: %w", id, err)
+	}
+	if res.AffectedRows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Query retrieves a list of existing webhooks from the database.
+func (c Core) Query(ctx context.Context, fs database.Fieldset, pagi database.Pagination) ([]Webhook, database.Page, error) {
+	res, page, err := c.store.Query(ctx, fs, pagi)
+	if err != nil {
+		return []Webhook{}, database.Page{}, fmt.Errorf("query: %w", err)
+	}
+
+	return toWebhookSlice(res), page, nil
+}
+
+// QueryByID retrieves a single webhook from the database by id.
+func (c Core) QueryByID(ctx context.Context, id string, fs database.Fieldset) (Webhook, error) {
+	if err := validate.CheckID(id); err != nil {
+		return Webhook{}, ErrInvalidID
+	}
+
+	res, err := c.store.QueryByID(ctx, id, fs)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return Webhook{}, ErrNotFound
+		}
+		return Webhook{}, fmt.Errorf("query: %w", err)
+	}
+
+	return toWebhook(res), nil
+}
+
+// -----------------------------------------------------------------------
+// Delivery Methods
+// -----------------------------------------------------------------------
+
+// Publish queues an event for every active webhook of the tenant that
+// subscribed to it. The data is the state of the entity once the event
+// happened. Nothing is sent here, when called in the transaction of the
+// change the event is only delivered if the change is committed.
+func (c Core) Publish(ctx context.Context, event string, data interface{}, now time.Time) error {
+	ws, err := c.store.QueryActive(ctx)
+	if err != nil {
+		return fmt.Errorf("publishing %s: %w", event, err)
+	}
+
+	var subscribed []db.Webhook
+	for _, w := range ws {
+		for _, e := range splitEvents(w.EventTypes) {
+			if e == event {
+				subscribed = append(subscribed, w)
+				break
+			}
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil
+	}
+
+	now = now.UTC()
+	ev := Event{
+		ID:        uuid.NewString(),
+		Event:     event,
+		CreatedOn: now,
+		Data:      data,
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", event, err)
+	}
+
+	for _, w := range subscribed {
+		dbD := db.Delivery{
+			WebhookID:     w.ID,
+			EventUID:      ev.ID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        db.StatusPending,
+			NextAttemptOn: &now,
+			CreatedOn:     now,
+			UpdatedOn:     now,
+		}
+		if _, err := c.store.CreateDelivery(ctx, dbD); err != nil {
+			return fmt.Errorf("publishing %s to webhook id[%s]: %w", event, w.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// QueryDeliveries retrieves the delivery log of a webhook.
+func (c Core) QueryDeliveries(ctx context.Context, id string, filter database.Filter, fs database.Fieldset, pagi database.Pagination) ([]Delivery, database.Page, error) {
+	if _, err := c.QueryByID(ctx, id, database.Fieldset{}); err != nil {
+		return []Delivery{}, database.Page{}, err
+	}
+
+	res, page, err := c.store.QueryDeliveries(ctx, id, filter, fs, pagi)
+	if err != nil {
+		return []Delivery{}, database.Page{}, fmt.Errorf("query: %w", err)
+	}
+
+	return toDeliverySlice(res), page, nil
+}
+
+// Redeliver sends a delivery again as soon as possible, with all its
+// attempts available again. The webhook must still be active.
+func (c Core) Redeliver(ctx context.Context, id string, deliveryID string, now time.Time) (Delivery, error) {
+	if err := validate.CheckID(id); err != nil {
+		return Delivery{}, ErrInvalidID
+	}
+	if err := validate.CheckID(deliveryID); err != nil {
+		return Delivery{}, ErrInvalidID
+	}
+
+	w, err := c.QueryByID(ctx, id, database.Fieldset{})
+	if err != nil {
+		return Delivery{}, err
+	}
+	if !w.Active {
+		return Delivery{}, ErrInactive
+	}
+
+	dbD, err := c.store.QueryDeliveryByID(ctx, id, deliveryID)
+	if err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return Delivery{}, ErrDeliveryNotFound
+		}
+		return Delivery{}, fmt.Errorf("redelivering webhook delivery id[%s]: %w", deliveryID, err)
+	}
+
+	now = now.UTC()
+	dbD.Status = db.StatusPending
+	dbD.Attempts = 0
+	dbD.NextAttemptOn = &now
+	dbD.LastError = ""
+	dbD.UpdatedOn = now
+
+	res, err := c.store.Redeliver(ctx, dbD)
+	if err != nil {
+		return Delivery{}, fmt.Errorf("redeliver id[%s]: %w", deliveryID, err)
+	}
+	if res.AffectedRows == 0 {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+
+	return toDelivery(dbD), nil
+}
+
+// checkWebhook validates what the struct tags can't: only http and https
+// urls are posted to and only known events can be subscribed to.
+func checkWebhook(rawURL string, events []string) error {
+	var fe validate.FieldErrors
+	if u, err := url.Parse(strings.TrimSpace(rawURL)); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fe.FieldError = append(fe.FieldError, validate.FieldError{
+			Field: "url",
+			Error: "url must be an absolute http or https url",
+		})
+	}
+
+	seen := make(map[string]bool, len(events))
+	for _, e := range events {
+		switch {
+		case !knownEvent(e):
+			fe.FieldError = append(fe.FieldError, validate.FieldError{
+				Field: "event_types",
+				Error: fmt.Sprintf("event %q is not one of %s", e, strings.Join(Events, ", ")),
+			})
+		case seen[e]:
+			fe.FieldError = append(fe.FieldError, validate.FieldError{
+				Field: "event_types",
+				Error: fmt.Sprintf("event %q is listed twice", e),
+			})
+		}
+		seen[e] = true
+	}
+
+	if len(fe.FieldError) > 0 {
+		return fe
+	}
+	return nil
+}
+
+// knownEvent reports whether webhooks can subscribe to the event.
+func knownEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// generateSecret returns a new random secret to sign deliveries with.
+func generateSecret() (string, error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating secret: %w", err)
+	}
+	return secretTag + "_" + hex.EncodeToString(b), nil
+}
@@ -80,15 +80,21 @@ func (a *API) Handle(method string, path string, handler Handler, mw ...Middlewa
 		// use it as a separate parameter.
 		ctx := r.Context()
 
-		// Capture the parent request span from the context.
-		// Once we properly setup otel / tracing
-		span := trace.SpanFromContext(ctx)
-
 		// Set the context with the required values to
 		// process the request.
 		v := ContextValues{
-			TracerUID: span.SpanContext().TraceID().String(),
-			Now:       time.Now(),
+			Now: time.Now(),
+			Conditions: Conditions{
+				Method:          r.Method,
+				IfNoneMatch:     r.Header.Get("If-None-Match"),
+				IfModifiedSince: r.Header.Get("If-Modified-Since"),
+			},
+		}
+
+		// Default the tracer uid to the one of the parent request span,
+		// the RequestID middleware replaces it with the caller's id.
+		if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+			v.TracerUID = sc.TraceID().String()
 		}
 		ctx = context.WithValue(ctx, key, &v)
 
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// validate sets the validators and the cache policy of a successful read
+// and reports whether the copy the client holds is still current, in which
+// case a 304 is sent instead of the data. Handlers may set a strong ETag of
+// their own, a weak one is derived from the data and meta otherwise. The
+// Last-Modified time is the latest updated_on of the data, it is left out
+// of pages since removed rows don't move it.
+func validate(ctx context.Context, w http.ResponseWriter, data interface{}, meta interface{}) (bool, error) {
+	v, err := GetContextValues(ctx)
+	if err != nil {
+		return false, nil
+	}
+	if v.Conditions.Method != http.MethodGet && v.Conditions.Method != http.MethodHead {
+		return false, nil
+	}
+
+	h := w.Header()
+	if v.CacheControl != "" {
+		h.Set("Cache-Control", v.CacheControl)
+	}
+
+	jd, err := json.Marshal(data)
+	if err != nil {
+		return false, err
+	}
+
+	etag := h.Get("ETag")
+	if etag == "" {
+		jm, err := json.Marshal(meta)
+		if err != nil {
+			return false, err
+		}
+
+		sum := sha256.New()
+		sum.Write(jd)
+		sum.Write(jm)
+		etag = `W/"` + hex.EncodeToString(sum.Sum(nil)[:16]) + `"`
+		h.Set("ETag", etag)
+	}
+
+	var modified time.Time
+	if meta == nil {
+		modified = lastModified(jd)
+		if !modified.IsZero() {
+			h.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+		}
+	}
+
+	// If-None-Match takes precedence, If-Modified-Since is only a fallback
+	// for clients without an entity tag.
+	switch {
+	case v.Conditions.IfNoneMatch != "":
+		return noneMatch(v.Conditions.IfNoneMatch, etag), nil
+
+	case v.Conditions.IfModifiedSince != "" && !modified.IsZero():
+		since, err := http.ParseTime(v.Conditions.IfModifiedSince)
+		if err != nil {
+			return false, nil
+		}
+		return !modified.Truncate(time.Second).After(since), nil
+	}
+
+	return false, nil
+}
+
+// noneMatch reports whether the If-None-Match header lists the entity tag,
+// or the tag of one of its encodings, using the weak comparison.
+func noneMatch(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || sameTag(strings.TrimPrefix(tag, "W/"), strings.TrimPrefix(etag, "W/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// lastModified returns the latest updated_on of the json object, or array
+// of objects, of the data.
+func lastModified(jd []byte) time.Time {
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(jd, &rows); err != nil {
+		var row map[string]interface{}
+		if err := json.Unmarshal(jd, &row); err != nil {
+			return time.Time{}
+		}
+		rows = append(rows, row)
+	}
+
+	var latest time.Time
+	for _, row := range rows {
+		val, ok := row["updated_on"].(string)
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, val)
+		if err == nil && t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
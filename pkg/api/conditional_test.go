@@ -0,0 +1,105 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+)
+
+// updated is the updated_on of the resource the test routes serve.
+var updated = time.Date(2021, 5, 25, 10, 30, 0, 0, time.UTC)
+
+func newConditionalAPI() *api.API {
+	resource := map[string]interface{}{"id": "1", "updated_on": updated.Format(time.RFC3339Nano)}
+
+	a := api.NewAPI(make(chan os.Signal, 1))
+	a.Handle(http.MethodGet, "/tagged", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("ETag", api.ETag("1", 2))
+		return api.Respond(ctx, w, resource, http.StatusOK)
+	})
+	a.Handle(http.MethodGet, "/derived", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		if err := api.SetCacheControl(ctx, "private, max-age=60"); err != nil {
+			return err
+		}
+		return api.Respond(ctx, w, resource, http.StatusOK)
+	})
+	a.Handle(http.MethodPost, "/tagged", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("ETag", api.ETag("1", 2))
+		return api.Respond(ctx, w, resource, http.StatusOK)
+	})
+
+	return a
+}
+
+func get(a *api.API, method string, target string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for name, vals := range header {
+		r.Header[name] = vals
+	}
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	return w
+}
+
+func TestConditionalGet(t *testing.T) {
+	a := newConditionalAPI()
+
+	derived := get(a, http.MethodGet, "/derived", nil).Header().Get("ETag")
+	if !strings.HasPrefix(derived, "W/") {
+		t.Fatalf("derived tag: got %q, want a weak tag", derived)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		code   int
+	}{
+		{"no condition", "/tagged", nil, http.StatusOK},
+		{"same tag", "/tagged", http.Header{"If-None-Match": {`"1-2"`}}, http.StatusNotModified},
+		{"weak comparison", "/tagged", http.Header{"If-None-Match": {`W/"1-2"`}}, http.StatusNotModified},
+		{"one of the list", "/tagged", http.Header{"If-None-Match": {`"1-1", "1-2"`}}, http.StatusNotModified},
+		{"compressed tag", "/tagged", http.Header{"If-None-Match": {`"1-2-gzip"`}}, http.StatusNotModified},
+		{"any", "/tagged", http.Header{"If-None-Match": {"*"}}, http.StatusNotModified},
+		{"older version", "/tagged", http.Header{"If-None-Match": {`"1-1"`}}, http.StatusOK},
+		{"derived tag", "/derived", http.Header{"If-None-Match": {derived}}, http.StatusNotModified},
+		{"not modified since", "/tagged", http.Header{"If-Modified-Since": {updated.Format(http.TimeFormat)}}, http.StatusNotModified},
+		{"modified since", "/tagged", http.Header{"If-Modified-Since": {updated.Add(-time.Second).Format(http.TimeFormat)}}, http.StatusOK},
+		{"bad date", "/tagged", http.Header{"If-Modified-Since": {"yesterday"}}, http.StatusOK},
+		{"tag takes precedence", "/tagged", http.Header{"If-None-Match": {`"1-1"`}, "If-Modified-Since": {updated.Format(http.TimeFormat)}}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(a, http.MethodGet, tt.target, tt.header)
+			if w.Code != tt.code {
+				t.Fatalf("got %d, want %d", w.Code, tt.code)
+			}
+			if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("body: got %q, want none", w.Body)
+			}
+			if w.Header().Get("ETag") == "" {
+				t.Error("etag: got none")
+			}
+		})
+	}
+
+	w := get(a, http.MethodGet, "/tagged", nil)
+	if got, want := w.Header().Get("Last-Modified"), updated.Format(http.TimeFormat); got != want {
+		t.Errorf("last modified: got %q, want %q", got, want)
+	}
+	if got := get(a, http.MethodGet, "/derived", nil).Header().Get("Cache-Control"); got != "private, max-age=60" {
+		t.Errorf("cache control: got %q", got)
+	}
+
+	// Only reads are answered with a 304.
+	if w := get(a, http.MethodPost, "/tagged", http.Header{"If-None-Match": {`"1-2"`}}); w.Code != http.StatusOK {
+		t.Errorf("write: got %d, want %d", w.Code, http.StatusOK)
+	}
+}
@@ -20,6 +20,26 @@ type ContextValues struct {
 	IsError    bool
 	IsPanic    bool
 	Path       string
+	Subject    string
+	// UserUID is the user_uid header, once the Headers middleware validated
+	// it.
+	UserUID    string
+	OrgUID     string
+	SiteUID    string
+	AllTenants bool
+	Scopes     []string
+	Roles      []string
+	// Conditions are the conditional headers of a GET request.
+	Conditions Conditions
+	// CacheControl is the Cache-Control policy of the route.
+	CacheControl string
+}
+
+// Conditions are the conditional request headers Respond honours.
+type Conditions struct {
+	Method          string
+	IfNoneMatch     string
+	IfModifiedSince string
 }
 
 // GetContextValues returns the values from the context.
@@ -31,11 +51,12 @@ func GetContextValues(ctx context.Context) (*ContextValues, error) {
 	return v, nil
 }
 
-// GetTracerUID returns the trace id from the context.
+// GetTracerUID returns the trace id from the context, or an empty string
+// outside of a request.
 func GetTracerUID(ctx context.Context) string {
 	v, ok := ctx.Value(key).(*ContextValues)
 	if !ok {
-		return "deadbeef-dead-beef-aaaa-000000000000"
+		return ""
 	}
 	return v.TracerUID
 }
@@ -90,3 +111,51 @@ func SetPath(ctx context.Context, path string) error {
 	v.Path = path
 	return nil
 }
+
+// SetCacheControl stores the Cache-Control policy successful responses of
+// the route are sent with.
+func SetCacheControl(ctx context.Context, policy string) error {
+	v, ok := ctx.Value(key).(*ContextValues)
+	if !ok {
+		return errors.New("api value missing from context")
+	}
+	v.CacheControl = policy
+	return nil
+}
+
+// SetClaims stores the verified identity of the caller in the context.
+func SetClaims(ctx context.Context, subject string, orgUID string, siteUID string, scopes []string, roles []string) error {
+	v, ok := ctx.Value(key).(*ContextValues)
+	if !ok {
+		return errors.New("api value missing from context")
+	}
+	v.Subject = subject
+	v.OrgUID = orgUID
+	v.SiteUID = siteUID
+	v.Scopes = scopes
+	v.Roles = roles
+	return nil
+}
+
+// SetUserUID stores the user the headers of the request identify.
+func SetUserUID(ctx context.Context, userUID string) error {
+	v, ok := ctx.Value(key).(*ContextValues)
+	if !ok {
+		return errors.New("api value missing from context")
+	}
+	v.UserUID = userUID
+	return nil
+}
+
+// SetTenant stores the org and site the request is scoped to in the
+// context, all is set when the request reads across tenants.
+func SetTenant(ctx context.Context, orgUID string, siteUID string, all bool) error {
+	v, ok := ctx.Value(key).(*ContextValues)
+	if !ok {
+		return errors.New("api value missing from context")
+	}
+	v.OrgUID = orgUID
+	v.SiteUID = siteUID
+	v.AllTenants = all
+	return nil
+}
@@ -15,6 +15,10 @@ type ErrorResponse struct {
 	//
 	//example: {"field": "error message for this specific field"}
 	Fields map[string]string `json:"fields,omitempty"`
+	// in:body
+	//
+	//example: 0f8fad5b-d9cb-469f-a165-70867728950e
+	TracerUID string `json:"tracer_uid,omitempty"`
 }
 
 // ErrorResponseID is the form used for API responses from failures in the API.
@@ -28,6 +32,10 @@ type ErrorResponseID struct {
 	//
 	//example: {"field": "error message for this specific field"}
 	Fields map[string]string `json:"fields,omitempty"`
+	// in:body
+	//
+	//example: 0f8fad5b-d9cb-469f-a165-70867728950e
+	TracerUID string `json:"tracer_uid,omitempty"`
 }
 
 // ErrorResponseIDs is the form used for API responses from failures in the API.
@@ -41,6 +49,10 @@ type ErrorResponseIDs struct {
 	//
 	//example: {"field": "error message for this specific field"}
 	Fields map[string]string `json:"fields,omitempty"`
+	// in:body
+	//
+	//example: 0f8fad5b-d9cb-469f-a165-70867728950e
+	TracerUID string `json:"tracer_uid,omitempty"`
 }
 
 // ErrorResponseUUID is the form used for API responses from failures in the API.
@@ -54,6 +66,10 @@ type ErrorResponseUUID struct {
 	//
 	//example: {"field": "error message for this specific field"}
 	Fields map[string]string `json:"fields,omitempty"`
+	// in:body
+	//
+	//example: 0f8fad5b-d9cb-469f-a165-70867728950e
+	TracerUID string `json:"tracer_uid,omitempty"`
 }
 
 // RequestError is used to pass an error during the request through the
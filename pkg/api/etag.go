@@ -0,0 +1,86 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ETag returns the strong entity tag of a version of a resource.
+func ETag(id string, version int) string {
+	return fmt.Sprintf(`"%s-%d"`, id, version)
+}
+
+// EncodedETag returns the entity tag of the resource once compressed with
+// the content coding, a strong tag gets the coding appended so "1-2" becomes
+// "1-2-gzip". Weak tags are left as is, their comparison already allows
+// for the encoding.
+func EncodedETag(etag string, coding string) string {
+	if strings.HasPrefix(etag, "W/") || !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + coding + `"`
+}
+
+// sameTag reports whether the tag sent by the client names the entity tag,
+// or the entity tag of one of its encodings as returned by EncodedETag.
+func sameTag(tag string, etag string) bool {
+	if tag == etag {
+		return true
+	}
+
+	base := strings.TrimSuffix(etag, `"`) + "-"
+	if len(tag) <= len(base)+1 || !strings.HasPrefix(tag, base) || !strings.HasSuffix(tag, `"`) {
+		return false
+	}
+	for _, c := range tag[len(base) : len(tag)-1] {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Precondition is the If-Match header of a request. The zero value, when the
+// header was not sent, matches every entity tag.
+type Precondition struct {
+	tags []string
+	any  bool
+}
+
+// IfMatch returns the precondition of the If-Match header of the request.
+func IfMatch(r *http.Request) Precondition {
+	var p Precondition
+	for _, val := range r.Header.Values("If-Match") {
+		for _, tag := range strings.Split(val, ",") {
+			switch tag = strings.TrimSpace(tag); tag {
+			case "":
+			case "*":
+				p.any = true
+			default:
+				p.tags = append(p.tags, tag)
+			}
+		}
+	}
+	return p
+}
+
+// Empty reports whether the request did not send an If-Match header.
+func (p Precondition) Empty() bool {
+	return !p.any && len(p.tags) == 0
+}
+
+// Matches reports whether the entity tag of the current version of the
+// resource satisfies the precondition. Weak tags never match, the comparison
+// is strong, but the tags of the compressed responses do.
+func (p Precondition) Matches(etag string) bool {
+	if p.Empty() || p.any {
+		return true
+	}
+	for _, tag := range p.tags {
+		if !strings.HasPrefix(tag, "W/") && sameTag(tag, etag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+)
+
+func TestEncodedETag(t *testing.T) {
+	tests := map[string]string{
+		`"1-2"`:   `"1-2-gzip"`,
+		`W/"1-2"`: `W/"1-2"`,
+		`1-2`:     `1-2`,
+	}
+
+	for etag, want := range tests {
+		if got := api.EncodedETag(etag, "gzip"); got != want {
+			t.Errorf("%s: got %s, want %s", etag, got, want)
+		}
+	}
+}
+
+func TestPreconditionMatches(t *testing.T) {
+	etag := api.ETag("1", 2)
+
+	tests := []struct {
+		name    string
+		ifMatch []string
+		matches bool
+	}{
+		{"no header", nil, true},
+		{"any", []string{"*"}, true},
+		{"same tag", []string{`"1-2"`}, true},
+		{"one of the list", []string{`"1-1", "1-2"`}, true},
+		{"one of the headers", []string{`"1-1"`, `"1-2"`}, true},
+		{"encoded tag", []string{`"1-2-gzip"`}, true},
+		{"older version", []string{`"1-1"`}, false},
+		{"older encoded version", []string{`"1-1-gzip"`}, false},
+		{"other resource", []string{`"12-2"`}, false},
+		{"weak tag", []string{`W/"1-2"`}, false},
+		{"not a coding", []string{`"1-2-3"`}, false},
+		{"empty coding", []string{`"1-2-"`}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/v1/build/1", nil)
+			for _, val := range tt.ifMatch {
+				r.Header.Add("If-Match", val)
+			}
+
+			p := api.IfMatch(r)
+			if p.Empty() != (tt.ifMatch == nil) {
+				t.Errorf("empty: got %t", p.Empty())
+			}
+			if got := p.Matches(etag); got != tt.matches {
+				t.Errorf("got %t, want %t", got, tt.matches)
+			}
+		})
+	}
+}
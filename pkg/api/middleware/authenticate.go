@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/auth"
+)
+
+// Set of authorization schemes the API accepts.
+const (
+	schemeBearer = "Bearer"
+	schemeAPIKey = "ApiKey"
+)
+
+// Authenticate verifies the credentials of the request and stores their
+// subject, org, scopes and roles in the context values. Bearer tokens are
+// verified by a and API keys by keys, either of them can be nil to turn the
+// scheme off.
+func Authenticate(a *auth.Auth, keys auth.KeyVerifier) api.Middleware {
+	var schemes []string
+	if a != nil {
+		schemes = append(schemes, schemeBearer)
+	}
+	if keys != nil {
+		schemes = append(schemes, schemeAPIKey)
+	}
+	usage := errors.New("expected authorization header format: " + strings.Join(schemes, " or ") + " <credentials>")
+
+	// This is the actual middleware function to be executed.
+	m := func(handler api.Handler) api.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+			// CORS preflight requests never carry credentials.
+			if r.Method == http.MethodOptions {
+				return handler(ctx, w, r)
+			}
+
+			scheme, cred, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+			cred = strings.TrimSpace(cred)
+
+			var id auth.Identity
+			switch {
+			case a != nil && strings.EqualFold(scheme, schemeBearer) && cred != "":
+				claims, err := a.Authenticate(ctx, cred)
+				if err != nil {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					return api.NewRequestError(err, http.StatusUnauthorized)
+				}
+				id = auth.Identity{Subject: claims.Subject, OrgUID: claims.OrgUID, SiteUID: claims.SiteUID, Scopes: claims.Scopes(), Roles: claims.Roles}
+
+			case keys != nil && strings.EqualFold(scheme, schemeAPIKey) && cred != "":
+				var err error
+				id, err = keys.VerifyKey(ctx, cred)
+				if err != nil {
+					if errors.Is(err, auth.ErrUnauthenticated) {
+						w.Header().Set("WWW-Authenticate", schemeAPIKey)
+						return api.NewRequestError(err, http.StatusUnauthorized)
+					}
+					return err
+				}
+
+			default:
+				for _, s := range schemes {
+					w.Header().Add("WWW-Authenticate", s)
+				}
+				return api.NewRequestError(usage, http.StatusUnauthorized)
+			}
+
+			if err := api.SetClaims(ctx, id.Subject, id.OrgUID, id.SiteUID, id.Scopes, id.Roles); err != nil {
+				return api.NewShutdownError("api value missing from context")
+			}
+
+			// Call the next handler.
+			return handler(ctx, w, r)
+		}
+		return h
+	}
+	return m
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/auth"
+	"go.uber.org/zap"
+)
+
+// Authorize makes sure the authenticated caller was granted every scope the
+// route requires, either directly or through the roles of the policy. It
+// must run after Authenticate. Every decision is logged.
+func Authorize(log *zap.SugaredLogger, policy auth.Policy, scopes ...string) api.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler api.Handler) api.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			v, err := api.GetContextValues(ctx)
+			if err != nil {
+				return api.NewShutdownError("api value missing from context")
+			}
+
+			missing := policy.Missing(v.Roles, v.Scopes, scopes)
+
+			lw := log.With("component", "middleware:authorize",
+				"tracer_uid", v.TracerUID,
+				"subject", v.Subject,
+				"method", r.Method,
+				"path", v.Path,
+				"required", scopes,
+			)
+
+			if len(missing) > 0 {
+				lw.Infow("authorization denied", "missing", missing)
+
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, strings.Join(scopes, " ")))
+				return api.NewRequestError(fmt.Errorf("missing required scope: %s", strings.Join(missing, ", ")), http.StatusForbidden)
+			}
+
+			lw.Infow("authorization granted")
+
+			// Call the next handler.
+			return handler(ctx, w, r)
+		}
+		return h
+	}
+	return m
+}
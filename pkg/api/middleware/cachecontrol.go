@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+)
+
+// CacheControl declares the Cache-Control policy of a route, such as
+// "private, no-cache" or "private, max-age=300". It is only sent along
+// successful reads, errors are never cached.
+func CacheControl(policy string) api.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler api.Handler) api.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if err := api.SetCacheControl(ctx, policy); err != nil {
+				return api.NewShutdownError("api value missing from context")
+			}
+
+			// Call the next handler.
+			return handler(ctx, w, r)
+		}
+		return h
+	}
+	return m
+}
@@ -0,0 +1,301 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+)
+
+// Encoder compresses responses with a content coding, such as gzip. Other
+// codings, brotli or zstd, can be plugged in through CompressConfig.
+type Encoder struct {
+	// Name is the content coding, as listed in Accept-Encoding.
+	Name string
+	// New returns a writer compressing into w at the level.
+	New func(w io.Writer, level int) (io.WriteCloser, error)
+}
+
+// Gzip is the gzip encoder, its writers are reused across responses.
+var Gzip = Encoder{
+	Name: "gzip",
+	New:  newGzip,
+}
+
+// CompressConfig holds how responses are compressed.
+type CompressConfig struct {
+	// MinSize is the smallest body compressed, in bytes. Smaller bodies
+	// cost more to compress than they save.
+	MinSize int `mapstructure:"minSize"`
+	// Level is the compression level of the encoders, gzip.DefaultCompression
+	// when zero.
+	Level int `mapstructure:"level"`
+	// Encoders are the codings offered, preferred first. Gzip when empty.
+	Encoders []Encoder `mapstructure:"-"`
+}
+
+// Validate makes sure the level fits gzip.
+func (c CompressConfig) Validate() error {
+	if c.MinSize < 0 {
+		return fmt.Errorf("minimum size can't be negative, got %d", c.MinSize)
+	}
+	if c.Level < gzip.HuffmanOnly || c.Level > gzip.BestCompression {
+		return fmt.Errorf("level must be between %d and %d, got %d", gzip.HuffmanOnly, gzip.BestCompression, c.Level)
+	}
+	return nil
+}
+
+// Compress compresses the responses with the first coding of the config
+// the client accepts. Bodies smaller than the minimum size, already encoded
+// or of a compressed content type are sent as is. The decision is made once
+// MinSize bytes were written, or as soon as the handler flushes so streamed
+// responses go out right away. Compressed responses get the coding added to
+// their strong ETag, see api.EncodedETag.
+func Compress(cfg CompressConfig) api.Middleware {
+	if cfg.Level == 0 {
+		cfg.Level = gzip.DefaultCompression
+	}
+	if len(cfg.Encoders) == 0 {
+		cfg.Encoders = []Encoder{Gzip}
+	}
+
+	// This is the actual middleware function to be executed.
+	m := func(handler api.Handler) api.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			enc, ok := negotiate(r.Header.Get("Accept-Encoding"), cfg.Encoders)
+			if !ok || r.Method == http.MethodHead {
+				return handler(ctx, w, r)
+			}
+
+			cw := compressWriter{
+				ResponseWriter: w,
+				enc:            enc,
+				level:          cfg.Level,
+				minSize:        cfg.MinSize,
+			}
+
+			// Call the next handler.
+			err := handler(ctx, &cw, r)
+
+			if cerr := cw.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+			return err
+		}
+		return h
+	}
+	return m
+}
+
+// negotiate returns the first encoder the Accept-Encoding header accepts.
+func negotiate(header string, encoders []Encoder) (Encoder, bool) {
+	if header == "" {
+		return Encoder{}, false
+	}
+
+	accepted := make(map[string]bool)
+	wildcard := false
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		ok := true
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			if q, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64); err == nil && q == 0 {
+				ok = false
+			}
+		}
+
+		if name == "*" {
+			wildcard = ok
+			continue
+		}
+		accepted[name] = ok
+	}
+
+	for _, enc := range encoders {
+		ok, listed := accepted[enc.Name]
+		if ok || (!listed && wildcard) {
+			return enc, true
+		}
+	}
+	return Encoder{}, false
+}
+
+// compressWriter holds the body back until it knows whether compressing it
+// is worth it.
+type compressWriter struct {
+	http.ResponseWriter
+	enc     Encoder
+	level   int
+	minSize int
+
+	status  int
+	buf     bytes.Buffer
+	decided bool
+	zw      io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	if !cw.decided {
+		cw.buf.Write(b)
+		if cw.buf.Len() < cw.minSize {
+			return len(b), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if cw.zw != nil {
+		return cw.zw.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush sends what was written so far, compressed if it can be, for
+// streamed responses.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		if err := cw.decide(true); err != nil {
+			return
+		}
+	}
+
+	if f, ok := cw.zw.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close sends the rest of the body. Bodies that never reached the minimum
+// size are sent uncompressed.
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		if cw.status == 0 {
+			return nil
+		}
+		if err := cw.decide(false); err != nil {
+			return err
+		}
+	}
+
+	if cw.zw != nil {
+		err := cw.zw.Close()
+		if zw, ok := cw.zw.(*gzip.Writer); ok {
+			gzipPools[cw.level].Put(zw)
+		}
+		return err
+	}
+	return nil
+}
+
+// decide writes the header, with the content coding when compress is set
+// and the response can be compressed, then the buffered body.
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+
+	h := cw.Header()
+	if compress && compressible(cw.status, h) {
+		zw, err := cw.enc.New(cw.ResponseWriter, cw.level)
+		if err != nil {
+			return err
+		}
+		cw.zw = zw
+
+		h.Set("Content-Encoding", cw.enc.Name)
+		h.Del("Content-Length")
+
+		// The compressed bytes are another representation, they can't
+		// share the strong tag of the uncompressed ones.
+		if etag := h.Get("ETag"); etag != "" {
+			h.Set("ETag", api.EncodedETag(etag, cw.enc.Name))
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if cw.zw != nil {
+		_, err = cw.zw.Write(cw.buf.Bytes())
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf.Bytes())
+	}
+	cw.buf.Reset()
+	return err
+}
+
+// compressible reports whether a response with the status and headers can
+// be compressed.
+func compressible(status int, h http.Header) bool {
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	ct := strings.ToLower(h.Get("Content-Type"))
+	for _, prefix := range []string{"image/", "video/", "audio/", "font/woff", "application/zip", "application/gzip", "application/x-gzip", "application/zstd", "application/octet-stream"} {
+		if strings.HasPrefix(ct, prefix) && ct != "image/svg+xml" {
+			return false
+		}
+	}
+	return true
+}
+
+// gzipPools reuses the gzip writers of every level, allocating them is
+// most of the cost of compressing small responses.
+var gzipPools = func() map[int]*sync.Pool {
+	pools := make(map[int]*sync.Pool)
+	for level := gzip.HuffmanOnly; level <= gzip.BestCompression; level++ {
+		level := level
+		pools[level] = &sync.Pool{
+			New: func() interface{} {
+				zw, _ := gzip.NewWriterLevel(io.Discard, level)
+				return zw
+			},
+		}
+	}
+	return pools
+}()
+
+func newGzip(w io.Writer, level int) (io.WriteCloser, error) {
+	p, ok := gzipPools[level]
+	if !ok {
+		return nil, fmt.Errorf("invalid gzip level %d", level)
+	}
+	zw := p.Get().(*gzip.Writer)
+	zw.Reset(w)
+	return zw, nil
+}
@@ -0,0 +1,109 @@
+package middleware_test
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/api/middleware"
+)
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("build ", 100)
+
+	a := api.NewAPI(make(chan os.Signal, 1), middleware.Compress(middleware.CompressConfig{MinSize: 256}))
+	a.Handle(http.MethodGet, "/large", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("ETag", api.ETag("1", 2))
+		return api.Respond(ctx, w, large, http.StatusOK)
+	})
+	a.Handle(http.MethodGet, "/small", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("ETag", api.ETag("1", 2))
+		return api.Respond(ctx, w, "build", http.StatusOK)
+	})
+	a.Handle(http.MethodGet, "/image", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "image/png")
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(large))
+		return err
+	})
+
+	tests := []struct {
+		name           string
+		target         string
+		acceptEncoding string
+		compressed     bool
+	}{
+		{"gzip", "/large", "gzip", true},
+		{"among others", "/large", "br;q=1.0, gzip;q=0.8", true},
+		{"wildcard", "/large", "*", true},
+		{"not accepted", "/large", "", false},
+		{"refused", "/large", "gzip;q=0, *", false},
+		{"other coding", "/large", "br", false},
+		{"small body", "/small", "gzip", false},
+		{"compressed type", "/image", "gzip", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("got %d, want %d", w.Code, http.StatusOK)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("vary: got %q, want Accept-Encoding", got)
+			}
+
+			body := w.Body.String()
+			if got := w.Header().Get("Content-Encoding"); (got == "gzip") != tt.compressed {
+				t.Fatalf("content encoding: got %q", got)
+			}
+			if tt.compressed {
+				zr, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatalf("reading gzip: %s", err)
+				}
+				b, err := io.ReadAll(zr)
+				if err != nil {
+					t.Fatalf("reading gzip: %s", err)
+				}
+				body = string(b)
+			}
+			if !strings.Contains(body, "build") {
+				t.Errorf("body: got %q", body)
+			}
+
+			if tt.target == "/image" {
+				return
+			}
+			want := api.ETag("1", 2)
+			if tt.compressed {
+				want = `"1-2-gzip"`
+			}
+			if got := w.Header().Get("ETag"); got != want {
+				t.Errorf("etag: got %s, want %s", got, want)
+			}
+		})
+	}
+
+	// The tag of the compressed response revalidates it.
+	r := httptest.NewRequest(http.MethodGet, "/large", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Header.Set("If-None-Match", `"1-2-gzip"`)
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("revalidation: got %d with %d bytes, want %d", w.Code, w.Body.Len(), http.StatusNotModified)
+	}
+}
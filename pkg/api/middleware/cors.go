@@ -18,8 +18,9 @@ func Cors(origin string) api.Middleware {
 
 			// Set the CORS headers to the response
 			w.Header().Set("Access-Control-Allow-Origin", origin)
-			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Request-ID, traceparent, Idempotency-Key, If-Match, If-None-Match, If-Modified-Since")
+			w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, Idempotent-Replayed, ETag")
 
 			// Call the next handler.
 			return handler(ctx, w, r)
@@ -73,6 +73,9 @@ func Errors(log *zap.SugaredLogger) api.Middleware {
 					}
 				}
 
+				// Let the caller quote the request to support.
+				er.TracerUID = v.TracerUID
+
 				// Respond with the error back to the client
 				if err := api.Respond(ctx, w, er, status); err != nil {
 					return err
@@ -8,15 +8,16 @@ import (
 	"github.com/chaitanyamaili/go_rest/pkg/validate"
 )
 
-// RequiredHeaders contains all required header fields
+// RequiredHeaders contains all required header fields. The tracer_uid is
+// not one of them, RequestID takes it from the caller or generates one.
 type RequiredHeaders struct {
-	TracerUID string `json:"tracer_uid" validate:"header,uuid4"`
-	UserUID   string `json:"user_uid" validate:"header,uuid4"`
-	SiteUID   string `json:"site_uid" validate:"header,uuid4"`
-	OrgUID    string `json:"org_uid" validate:"omitempty,uuid4"`
+	UserUID string `json:"user_uid" validate:"header,uuid4"`
+	SiteUID string `json:"site_uid" validate:"header,uuid4"`
+	OrgUID  string `json:"org_uid" validate:"omitempty,uuid4"`
 }
 
-// Headers check to see if the minimum number of headers are set and valid
+// Headers check to see if the minimum number of headers are set and valid,
+// the user they identify is kept in the context.
 func Headers() api.Middleware {
 
 	// This is the actual middleware function to be executed.
@@ -26,16 +27,19 @@ func Headers() api.Middleware {
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 
 			nrs := RequiredHeaders{
-				TracerUID: r.Header.Get("tracer_uid"),
-				OrgUID:    r.Header.Get("org_uid"),
-				UserUID:   r.Header.Get("user_uid"),
-				SiteUID:   r.Header.Get("site_uid"),
+				OrgUID:  r.Header.Get("org_uid"),
+				UserUID: r.Header.Get("user_uid"),
+				SiteUID: r.Header.Get("site_uid"),
 			}
 
 			if err := validate.Check(nrs); err != nil {
 				return err
 			}
 
+			if err := api.SetUserUID(ctx, nrs.UserUID); err != nil {
+				return api.NewShutdownError("api value missing from context")
+			}
+
 			// Call the next handler.
 			return handler(ctx, w, r)
 		}
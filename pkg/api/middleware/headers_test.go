@@ -0,0 +1,54 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/api/middleware"
+	"go.uber.org/zap"
+)
+
+func TestHeaders(t *testing.T) {
+	log := zap.NewNop().Sugar()
+
+	a := api.NewAPI(make(chan os.Signal, 1), middleware.RequestID(), middleware.Errors(log), middleware.Headers())
+	a.Handle(http.MethodGet, "/build", ok)
+
+	const (
+		userUID = "0f8b2a8e-3f8e-4c36-9d3e-6a1b2c3d4e5f"
+		siteUID = "4b4d4a3e-8f2b-4f5c-9a0d-3a1b2c3d4e5f"
+	)
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		code   int
+	}{
+		{"no tracer uid", map[string]string{"user_uid": userUID, "site_uid": siteUID}, http.StatusOK},
+		{"traceparent", map[string]string{"user_uid": userUID, "site_uid": siteUID, "traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, http.StatusOK},
+		{"request id", map[string]string{"user_uid": userUID, "site_uid": siteUID, "X-Request-ID": "req-1"}, http.StatusOK},
+		{"no user", map[string]string{"site_uid": siteUID}, http.StatusBadRequest},
+		{"bad site", map[string]string{"user_uid": userUID, "site_uid": "site-1"}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/build", nil)
+			for name, val := range tt.header {
+				r.Header.Set(name, val)
+			}
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, r)
+
+			if w.Code != tt.code {
+				t.Errorf("got %d, want %d: %s", w.Code, tt.code, w.Body)
+			}
+			if w.Header().Get(middleware.HeaderTracerUID) == "" {
+				t.Error("tracer uid: got none")
+			}
+		})
+	}
+}
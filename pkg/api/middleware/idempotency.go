@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/idempotency"
+	"go.uber.org/zap"
+)
+
+// Idempotency makes the mutating requests sent with an Idempotency-Key
+// header safe to retry. The first request holds the key while it runs and
+// its response is stored for cfg.TTL, requests sent again with the key get
+// the stored response back. Reusing a key for another request is rejected
+// with a 422, retrying while the first request still runs with a 409.
+// Requests that failed are not stored so they can be retried. Keys are
+// scoped to the caller and its tenant, it must run after Tenant and, as a
+// route middleware, after Authorize.
+func Idempotency(log *zap.SugaredLogger, store idempotency.Store, cfg idempotency.Config) api.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler api.Handler) api.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" || !mutating(r.Method) {
+				return handler(ctx, w, r)
+			}
+
+			v, err := api.GetContextValues(ctx)
+			if err != nil {
+				return api.NewShutdownError("api value missing from context")
+			}
+
+			if err := idempotency.CheckKey(key); err != nil {
+				return api.NewRequestError(err, http.StatusBadRequest)
+			}
+
+			// Need buffers to make sure the handler can still read the body.
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, cfg.MaxBody))
+			if err != nil {
+				var mbe *http.MaxBytesError
+				if errors.As(err, &mbe) {
+					return api.NewRequestError(fmt.Errorf("request body is larger than %d bytes", mbe.Limit), http.StatusRequestEntityTooLarge)
+				}
+				return api.NewRequestError(err, http.StatusBadRequest)
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			caller := strings.Join([]string{callerKey(v, r), v.OrgUID, v.SiteUID}, "|")
+			rec := idempotency.Record{
+				Key:         idempotency.ScopedKey(caller, key),
+				Fingerprint: idempotency.Fingerprint(r.Method, r.URL.Path, body),
+				CreatedOn:   v.Now,
+				ExpiresOn:   v.Now.Add(cfg.LockTimeout),
+			}
+
+			got, reserved, err := store.Reserve(ctx, rec, v.Now)
+			if err != nil {
+				return err
+			}
+
+			lw := log.With("component", "middleware:idempotency",
+				"tracer_uid", v.TracerUID,
+				"method", r.Method,
+				"path", v.Path,
+			)
+
+			if !reserved {
+				switch {
+				case got.Fingerprint != rec.Fingerprint:
+					lw.Infow("idempotency key reused")
+					return api.NewRequestError(errors.New("idempotency key was already used for another request"), http.StatusUnprocessableEntity)
+
+				case !got.Completed():
+					return api.NewRequestError(errors.New("a request with this idempotency key is in progress"), http.StatusConflict)
+				}
+
+				lw.Infow("idempotent replay", "status_code", got.StatusCode)
+
+				if err := api.SetStatusCode(ctx, got.StatusCode); err != nil {
+					return err
+				}
+				if got.ContentType != "" {
+					w.Header().Set("Content-Type", got.ContentType)
+				}
+				for _, name := range replayedHeaders {
+					for _, val := range got.Header.Values(name) {
+						w.Header().Add(name, val)
+					}
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(got.StatusCode)
+				_, err := w.Write(got.Body)
+				return err
+			}
+
+			rw := recorder{ResponseWriter: w}
+
+			// Call the next handler.
+			if err := handler(ctx, &rw, r); err != nil || rw.status == 0 || rw.status >= http.StatusInternalServerError {
+				if err := store.Release(ctx, rec.Key); err != nil {
+					lw.Errorw("releasing idempotency key", "ERROR", err)
+				}
+				return err
+			}
+
+			rec.StatusCode = rw.status
+			rec.ContentType = rw.Header().Get("Content-Type")
+			rec.Header = make(http.Header)
+			for _, name := range replayedHeaders {
+				for _, val := range rw.Header().Values(name) {
+					rec.Header.Add(name, val)
+				}
+			}
+			rec.Body = rw.body.Bytes()
+			rec.ExpiresOn = time.Now().Add(cfg.TTL)
+
+			// The response was already sent, failing to store it only means
+			// a retry runs the request again.
+			if err := store.Complete(ctx, rec); err != nil {
+				lw.Errorw("storing idempotent response", "ERROR", err)
+			}
+
+			return nil
+		}
+		return h
+	}
+	return m
+}
+
+// replayedHeaders are the response headers, besides Content-Type, stored
+// with the response and sent again when it is replayed.
+var replayedHeaders = []string{"ETag", "Last-Modified", "Location", "Cache-Control"}
+
+// mutating reports whether requests of the method change resources.
+func mutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// recorder keeps a copy of the response written through it.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recorder) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recorder) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,159 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/api/middleware"
+	"github.com/chaitanyamaili/go_rest/pkg/idempotency"
+	"go.uber.org/zap"
+)
+
+// memoryStore keeps the idempotency records in a map.
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]idempotency.Record
+}
+
+func (s *memoryStore) Reserve(ctx context.Context, rec idempotency.Record, now time.Time) (idempotency.Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if got, exists := s.records[rec.Key]; exists && got.ExpiresOn.After(now) {
+		return got, false, nil
+	}
+	s.records[rec.Key] = rec
+	return rec, true, nil
+}
+
+func (s *memoryStore) Complete(ctx context.Context, rec idempotency.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[rec.Key] = rec
+	return nil
+}
+
+func (s *memoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	log := zap.NewNop().Sugar()
+	store := &memoryStore{records: make(map[string]idempotency.Record)}
+	cfg := idempotency.Config{TTL: time.Hour, LockTimeout: time.Minute, MaxBody: 64, SweepInterval: time.Hour}
+
+	var calls int
+	created := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		calls++
+		w.Header().Set("ETag", api.ETag("1", calls))
+		w.Header().Set("Location", "/build/1")
+		return api.Respond(ctx, w, calls, http.StatusCreated)
+	}
+	failed := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		calls++
+		return api.Respond(ctx, w, nil, http.StatusInternalServerError)
+	}
+
+	a := api.NewAPI(make(chan os.Signal, 1), middleware.Errors(log), middleware.Idempotency(log, store, cfg))
+	a.Handle(http.MethodPost, "/build", created)
+	a.Handle(http.MethodPost, "/fail", failed)
+	a.Handle(http.MethodGet, "/build", ok)
+
+	send := func(method string, path string, key string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.RemoteAddr = "10.0.0.1:1234"
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		return w
+	}
+
+	first := send(http.MethodPost, "/build", "key-1", `{"label":"a"}`)
+	if first.Code != http.StatusCreated || calls != 1 {
+		t.Fatalf("first: got %d after %d calls, want %d after 1", first.Code, calls, http.StatusCreated)
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		w := send(http.MethodPost, "/build", "key-1", `{"label":"a"}`)
+		if w.Code != http.StatusCreated || calls != 1 {
+			t.Fatalf("got %d after %d calls, want %d without calling the handler", w.Code, calls, http.StatusCreated)
+		}
+		if w.Body.String() != first.Body.String() {
+			t.Errorf("body: got %s, want %s", w.Body, first.Body)
+		}
+		if got := w.Header().Get("Idempotent-Replayed"); got != "true" {
+			t.Errorf("replayed header: got %q, want true", got)
+		}
+		for _, name := range []string{"ETag", "Location", "Content-Type"} {
+			if got, want := w.Header().Get(name), first.Header().Get(name); got != want {
+				t.Errorf("%s: got %q, want %q", name, got, want)
+			}
+		}
+	})
+
+	t.Run("key reused", func(t *testing.T) {
+		if w := send(http.MethodPost, "/build", "key-1", `{"label":"b"}`); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("got %d, want %d", w.Code, http.StatusUnprocessableEntity)
+		}
+	})
+
+	t.Run("in progress", func(t *testing.T) {
+		body := `{"label":"c"}`
+		rec := idempotency.Record{
+			Key:         idempotency.ScopedKey("ip:10.0.0.1||", "key-2"),
+			Fingerprint: idempotency.Fingerprint(http.MethodPost, "/build", []byte(body)),
+			ExpiresOn:   time.Now().Add(time.Minute),
+		}
+		if _, _, err := store.Reserve(context.Background(), rec, time.Now()); err != nil {
+			t.Fatalf("reserve: %s", err)
+		}
+		if w := send(http.MethodPost, "/build", "key-2", body); w.Code != http.StatusConflict {
+			t.Errorf("got %d, want %d", w.Code, http.StatusConflict)
+		}
+	})
+
+	t.Run("body too large", func(t *testing.T) {
+		if w := send(http.MethodPost, "/build", "key-3", strings.Repeat("a", 65)); w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("got %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+		}
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		if w := send(http.MethodPost, "/build", strings.Repeat("k", idempotency.MaxKeyLength+1), `{}`); w.Code != http.StatusBadRequest {
+			t.Errorf("got %d, want %d", w.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("failures are not stored", func(t *testing.T) {
+		before := calls
+		for i := 0; i < 2; i++ {
+			if w := send(http.MethodPost, "/fail", "key-4", `{}`); w.Code != http.StatusInternalServerError {
+				t.Fatalf("attempt %d: got %d, want %d", i+1, w.Code, http.StatusInternalServerError)
+			}
+		}
+		if calls != before+2 {
+			t.Errorf("got %d calls, want the failed request to run again", calls-before)
+		}
+	})
+
+	t.Run("reads are not tracked", func(t *testing.T) {
+		w := send(http.MethodGet, "/build", "key-1", "")
+		if w.Code != http.StatusOK || w.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("got %d replayed %q, want the handler to run", w.Code, w.Header().Get("Idempotent-Replayed"))
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/metrics"
+)
+
+// Metrics records the count and latency of the requests, along with the
+// ones in flight, that failed or panicked. It must run outside Errors so
+// the status code of failed requests is known.
+func Metrics(m *metrics.Metrics) api.Middleware {
+
+	// This is the actual middleware function to be executed.
+	mw := func(handler api.Handler) api.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			v, err := api.GetContextValues(ctx)
+			if err != nil {
+				return api.NewShutdownError("api value missing from context")
+			}
+
+			done := m.Begin()
+			defer done()
+
+			start := time.Now()
+
+			// Call the next handler.
+			err = handler(ctx, w, r)
+
+			m.Observe(v.Path, r.Method, v.StatusCode, time.Since(start), v.IsError, v.IsPanic)
+
+			return err
+		}
+		return h
+	}
+	return mw
+}
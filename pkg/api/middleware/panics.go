@@ -26,8 +26,6 @@ func Panics() api.Middleware {
 					err = fmt.Errorf("API PANIC [%v] TRACE:\n%s", rec, string(trace))
 
 					_ = api.SetIsPanic(ctx)
-
-					// metrics.AddPanics(ctx)
 				}
 			}(ctx)
 
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/ratelimit"
+	"go.uber.org/zap"
+)
+
+// RateLimit limits how often each caller can make requests. Callers are told
+// apart by their authenticated subject, the user_uid header or their remote
+// address, in that order. The limit of the caller tier applies to every
+// request, the limit of the route, if any, on top of it. The RateLimit
+// headers report the most restrictive of the two on every response, excess
+// requests are rejected with a 429 and Retry-After. The limiter failing lets
+// requests through. It must run after Authenticate.
+func RateLimit(log *zap.SugaredLogger, limiter ratelimit.Limiter, policy ratelimit.Policy) api.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler api.Handler) api.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			v, err := api.GetContextValues(ctx)
+			if err != nil {
+				return api.NewShutdownError("api value missing from context")
+			}
+
+			caller := callerKey(v, r)
+			tier, tierLimit, tierOK := policy.Tier(v.Subject != "", v.Roles)
+			routeLimit, routeOK := policy.Route(r.Method, v.Path)
+
+			var (
+				res   ratelimit.Result
+				limit ratelimit.Limit
+				found bool
+			)
+			check := func(key string, l ratelimit.Limit) error {
+				got, err := limiter.Allow(ctx, key, l, v.Now)
+				if err != nil {
+					return err
+				}
+				if !found || restricts(got, res) {
+					res, limit, found = got, l, true
+				}
+				return nil
+			}
+
+			if tierOK {
+				err = check("tier:"+tier+":"+caller, tierLimit)
+			}
+			if err == nil && routeOK && (!found || res.Allowed) {
+				err = check("route:"+r.Method+" "+v.Path+":"+caller, routeLimit)
+			}
+
+			switch {
+			case err != nil:
+				log.Errorw("rate limit unavailable", "component", "middleware:ratelimit",
+					"tracer_uid", v.TracerUID,
+					"ERROR", err,
+				)
+				return handler(ctx, w, r)
+
+			case !found:
+				return handler(ctx, w, r)
+			}
+
+			hdr := w.Header()
+			hdr.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			hdr.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			hdr.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+			hdr.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", limit.Requests, ceilSeconds(limit.Per), limit.Capacity()))
+
+			if !res.Allowed {
+				log.Infow("rate limit exceeded", "component", "middleware:ratelimit",
+					"tracer_uid", v.TracerUID,
+					"key", caller,
+					"tier", tier,
+					"method", r.Method,
+					"path", v.Path,
+				)
+
+				hdr.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				return api.NewRequestError(errors.New("rate limit exceeded"), http.StatusTooManyRequests)
+			}
+
+			// Call the next handler.
+			return handler(ctx, w, r)
+		}
+		return h
+	}
+	return m
+}
+
+// callerKey returns the key telling the caller apart from the others.
+func callerKey(v *api.ContextValues, r *http.Request) string {
+	if v.Subject != "" {
+		return "sub:" + v.Subject
+	}
+	if user := strings.TrimSpace(r.Header.Get("user_uid")); user != "" {
+		return "user:" + user
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// restricts reports whether a is more restrictive than b.
+func restricts(a ratelimit.Result, b ratelimit.Result) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	if !a.Allowed {
+		return a.RetryAfter > b.RetryAfter
+	}
+	return a.Remaining < b.Remaining
+}
+
+// ceilSeconds rounds a duration up to whole seconds.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
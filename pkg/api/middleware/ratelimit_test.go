@@ -0,0 +1,84 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/api/middleware"
+	"github.com/chaitanyamaili/go_rest/pkg/ratelimit"
+	"go.uber.org/zap"
+)
+
+// ok is the handler of the test routes.
+func ok(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return api.Respond(ctx, w, nil, http.StatusOK)
+}
+
+func TestRateLimit(t *testing.T) {
+	log := zap.NewNop().Sugar()
+
+	policy, err := ratelimit.NewPolicy(ratelimit.Config{
+		Tiers: map[string]ratelimit.Limit{
+			ratelimit.TierAnonymous: {Requests: 2, Per: time.Minute},
+		},
+		Routes: map[string]ratelimit.Limit{
+			"POST /build": {Requests: 1, Per: time.Minute},
+		},
+	})
+	if err != nil {
+		t.Fatalf("policy: %s", err)
+	}
+	limiter := ratelimit.NewMemory()
+	defer limiter.Close()
+
+	a := api.NewAPI(make(chan os.Signal, 1), middleware.Errors(log), middleware.RateLimit(log, limiter, policy))
+	a.Handle(http.MethodGet, "/build", ok)
+	a.Handle(http.MethodPost, "/build", ok)
+
+	send := func(method string, remote string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/build", nil)
+		r.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		return w
+	}
+
+	for i, want := range []string{"1", "0"} {
+		w := send(http.MethodGet, "10.0.0.1:1234")
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: got %d, want %d", i+1, w.Code, http.StatusOK)
+		}
+		if got := w.Header().Get("RateLimit-Remaining"); got != want {
+			t.Errorf("request %d: remaining got %s, want %s", i+1, got, want)
+		}
+		if got := w.Header().Get("RateLimit-Policy"); got != "2;w=60;burst=2" {
+			t.Errorf("request %d: policy got %s", i+1, got)
+		}
+	}
+
+	w := send(http.MethodGet, "10.0.0.1:4321")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("over the limit: got %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("retry after: got %s, want 30", got)
+	}
+
+	// Other callers have limits of their own, the route limit applies on
+	// top of the tier.
+	if w := send(http.MethodPost, "10.0.0.2:1234"); w.Code != http.StatusOK {
+		t.Fatalf("other caller: got %d, want %d", w.Code, http.StatusOK)
+	}
+	w = send(http.MethodPost, "10.0.0.2:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("over the route limit: got %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("RateLimit-Limit"); got != "1" {
+		t.Errorf("route limit: got %s, want 1", got)
+	}
+}
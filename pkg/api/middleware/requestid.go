@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+)
+
+// Set of headers a caller may identify its request with. The id is echoed
+// back in both HeaderRequestID and HeaderTracerUID.
+const (
+	HeaderRequestID   = "X-Request-ID"
+	HeaderTracerUID   = "tracer_uid"
+	HeaderTraceparent = "traceparent"
+)
+
+// maxRequestIDLength bounds the ids accepted from callers.
+const maxRequestIDLength = 128
+
+// RequestID sets the tracer uid of the request. It is taken from the
+// tracer_uid, X-Request-ID or traceparent headers of the caller, in that
+// order, then from the active trace, and generated when none is usable. The
+// id ends up in every log line, error envelope and response of the request
+// so a failed call can be matched to the logs. A caller sending its own
+// tracer_uid always gets it back.
+func RequestID() api.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler api.Handler) api.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			id := requestID(ctx, r)
+			if err := api.SetTracerUID(ctx, id); err != nil {
+				return api.NewShutdownError("api value missing from context")
+			}
+
+			trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", id))
+
+			w.Header().Set(HeaderRequestID, id)
+			w.Header().Set(HeaderTracerUID, id)
+
+			// Call the next handler.
+			return handler(ctx, w, r)
+		}
+		return h
+	}
+	return m
+}
+
+// requestID picks the first usable id of the request.
+func requestID(ctx context.Context, r *http.Request) string {
+	for _, h := range []string{HeaderTracerUID, HeaderRequestID} {
+		if id := strings.TrimSpace(r.Header.Get(h)); validRequestID(id) {
+			return id
+		}
+	}
+
+	if id, ok := traceparentID(r.Header.Get(HeaderTraceparent)); ok {
+		return id
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+
+	return uuid.NewString()
+}
+
+// validRequestID reports whether an id sent by a caller is safe to log and
+// echo back.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// traceparentID returns the trace id of a W3C traceparent header.
+func traceparentID(h string) (string, bool) {
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return "", false
+	}
+
+	tid, err := trace.TraceIDFromHex(parts[1])
+	if err != nil || len(parts[2]) != 16 {
+		return "", false
+	}
+
+	return tid.String(), true
+}
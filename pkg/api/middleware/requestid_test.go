@@ -0,0 +1,51 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/api/middleware"
+)
+
+func TestRequestID(t *testing.T) {
+	a := api.NewAPI(make(chan os.Signal, 1), middleware.RequestID())
+	a.Handle(http.MethodGet, "/build", ok)
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   string
+	}{
+		{"tracer uid", map[string]string{"tracer_uid": "trace-1"}, "trace-1"},
+		{"request id", map[string]string{"X-Request-ID": "req-1"}, "req-1"},
+		{"tracer uid first", map[string]string{"tracer_uid": "trace-1", "X-Request-ID": "req-1"}, "trace-1"},
+		{"invalid tracer uid", map[string]string{"tracer_uid": "trace 1", "X-Request-ID": "req-1"}, "req-1"},
+		{"traceparent", map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, "4bf92f3577b34da6a3ce929d0e0e4736"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/build", nil)
+			for name, val := range tt.header {
+				r.Header.Set(name, val)
+			}
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, r)
+
+			for _, name := range []string{middleware.HeaderTracerUID, middleware.HeaderRequestID} {
+				if got := w.Header().Get(name); got != tt.want {
+					t.Errorf("%s: got %q, want %q", name, got, tt.want)
+				}
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/build", nil))
+	if len(w.Header().Get(middleware.HeaderTracerUID)) != 36 {
+		t.Errorf("generated: got %q, want a uuid", w.Header().Get(middleware.HeaderTracerUID))
+	}
+}
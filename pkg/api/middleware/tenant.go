@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/auth"
+	"github.com/chaitanyamaili/go_rest/pkg/validate"
+	"go.uber.org/zap"
+)
+
+// errSharedTenant is returned for writes to the default tenant by callers
+// that are not admins.
+var errSharedTenant = errors.New("the shared tenant is read-only, an org_uid is required")
+
+// Tenant scopes the request to the org and site of the org_uid and site_uid
+// headers. Authenticated callers are bound to the org and site of their
+// credentials, only callers granted adminScope can pick another org or site
+// or, with the all_tenants=true query parameter, read across tenants. The
+// default tenant, where both are empty, holds the rows shared with every
+// tenant and is read-only to everyone else. The policy is nil when callers
+// are not authenticated, the headers are trusted then and requests without
+// them act on the default tenant as they always did, but nobody can read
+// across tenants. It must run after Authenticate.
+func Tenant(log *zap.SugaredLogger, policy *auth.Policy, adminScope string) api.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler api.Handler) api.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			v, err := api.GetContextValues(ctx)
+			if err != nil {
+				return api.NewShutdownError("api value missing from context")
+			}
+
+			org := strings.TrimSpace(r.Header.Get("org_uid"))
+			site := strings.TrimSpace(r.Header.Get("site_uid"))
+
+			var fe validate.FieldErrors
+			if org != "" && validate.CheckUUID(org) != nil {
+				fe.FieldError = append(fe.FieldError, validate.FieldError{Field: "org_uid", Error: "org_uid must be a valid UUID"})
+			}
+			if site != "" && validate.CheckUUID(site) != nil {
+				fe.FieldError = append(fe.FieldError, validate.FieldError{Field: "site_uid", Error: "site_uid must be a valid UUID"})
+			}
+
+			var all bool
+			if val := r.URL.Query().Get("all_tenants"); val != "" {
+				if all, err = strconv.ParseBool(val); err != nil {
+					fe.FieldError = append(fe.FieldError, validate.FieldError{Field: "all_tenants", Error: "all_tenants must be a boolean"})
+				}
+			}
+			if all && r.Method != http.MethodGet && r.Method != http.MethodHead {
+				fe.FieldError = append(fe.FieldError, validate.FieldError{Field: "all_tenants", Error: "all_tenants only applies to reads"})
+			}
+
+			if len(fe.FieldError) > 0 {
+				fe.CustomError = "invalid tenant"
+				return fe
+			}
+
+			// Without authentication there is nothing to check the headers
+			// against, nor anybody known to be an admin.
+			if policy == nil {
+				if all {
+					return api.NewRequestError(errors.New("all_tenants requires an authenticated admin"), http.StatusForbidden)
+				}
+				if err := api.SetTenant(ctx, org, site, false); err != nil {
+					return api.NewShutdownError("api value missing from context")
+				}
+				return handler(ctx, w, r)
+			}
+
+			isAdmin := len(policy.Missing(v.Roles, v.Scopes, []string{adminScope})) == 0
+			crossOrg := org != "" && org != v.OrgUID
+			crossSite := site != "" && site != v.SiteUID
+
+			lw := log.With("component", "middleware:tenant",
+				"tracer_uid", v.TracerUID,
+				"subject", v.Subject,
+				"org_uid", v.OrgUID,
+				"method", r.Method,
+				"path", v.Path,
+			)
+
+			switch {
+			case (all || crossOrg || crossSite) && !isAdmin:
+				lw.Infow("tenant denied", "requested_org_uid", org, "requested_site_uid", site, "all_tenants", all)
+
+				switch {
+				case all:
+					return api.NewRequestError(fmt.Errorf("missing required scope: %s", adminScope), http.StatusForbidden)
+				case crossOrg:
+					return api.NewRequestError(errors.New("org_uid does not match the credentials"), http.StatusForbidden)
+				default:
+					return api.NewRequestError(errors.New("site_uid does not match the credentials"), http.StatusForbidden)
+				}
+
+			case all || crossOrg || crossSite:
+				lw.Infow("tenant override granted", "requested_org_uid", org, "requested_site_uid", site, "all_tenants", all)
+
+				if !crossOrg {
+					org = v.OrgUID
+					if !crossSite {
+						site = v.SiteUID
+					}
+				}
+
+			default:
+				org = v.OrgUID
+				site = v.SiteUID
+			}
+
+			// Credentials without an org land in the default tenant, its
+			// shared rows are only changed by admins.
+			if org == "" && site == "" && mutating(r.Method) && !isAdmin {
+				lw.Infow("tenant denied", "reason", "shared tenant is read-only")
+				return api.NewRequestError(errSharedTenant, http.StatusForbidden)
+			}
+
+			if err := api.SetTenant(ctx, org, site, all); err != nil {
+				return api.NewShutdownError("api value missing from context")
+			}
+
+			// Call the next handler.
+			return handler(ctx, w, r)
+		}
+		return h
+	}
+	return m
+}
@@ -24,6 +24,9 @@ type SuccessResponse struct {
 	// Data
 	// in: body
 	Data interface{} `json:"data,omitempty"`
+	// Meta
+	// in: body
+	Meta interface{} `json:"meta,omitempty"`
 	// Errors
 	// in: body
 	Errors interface{} `json:"errors,omitempty"`
@@ -31,9 +34,16 @@ type SuccessResponse struct {
 
 // Respond returns json to client
 func Respond(ctx context.Context, w http.ResponseWriter, data interface{}, statusCode int) error {
+	return RespondWithMeta(ctx, w, data, nil, statusCode)
+}
+
+// RespondWithMeta returns json to client along with metadata about the
+// data, such as the cursors of a page of results.
+func RespondWithMeta(ctx context.Context, w http.ResponseWriter, data interface{}, meta interface{}, statusCode int) error {
 
 	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "pkg.api.respond")
 	span.SetAttributes(attribute.Int("statusCode", statusCode))
+	defer span.End()
 
 	// Set the status code for the request logger middleware
 	err := SetStatusCode(ctx, statusCode)
@@ -51,14 +61,31 @@ func Respond(ctx context.Context, w http.ResponseWriter, data interface{}, statu
 		Success:   true,
 		Timestamp: time.Now().UTC().Unix(),
 		Data:      data,
+		Meta:      meta,
 	}
 	// If it's an error, it does not need to re-marshal
 	if reflect.TypeOf(data) == reflect.TypeOf(ErrorResponse{}) {
 		r.Success = false
 		r.Data = nil
+		r.Meta = nil
 		r.Errors = data
 	}
 
+	// Answer reads of data the client already holds with a 304.
+	if statusCode == http.StatusOK && r.Success {
+		fresh, err := validate(ctx, w, data, meta)
+		if err != nil {
+			return err
+		}
+		if fresh {
+			if err := SetStatusCode(ctx, http.StatusNotModified); err != nil {
+				return err
+			}
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+	}
+
 	// Convert the response to json
 	jd, err := json.Marshal(r)
 	if err != nil {
@@ -0,0 +1,19 @@
+package auth
+
+import "context"
+
+// Identity is the verified identity of a caller.
+type Identity struct {
+	Subject string
+	OrgUID  string
+	SiteUID string
+	Scopes  []string
+	Roles   []string
+}
+
+// KeyVerifier verifies the API keys machine clients call the API with.
+// Keys that can't be verified return an error wrapping ErrUnauthenticated,
+// any other error is a failure to verify them.
+type KeyVerifier interface {
+	VerifyKey(ctx context.Context, key string) (Identity, error)
+}
@@ -0,0 +1,257 @@
+// Package auth verifies the signed JWTs the API is called with.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// Set of supported signing algorithms.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+)
+
+// ErrUnauthenticated is returned when a token can't be verified.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// reloadBackoff limits how often an unknown key id reloads the key set.
+const reloadBackoff = time.Minute
+
+// Claims are the verified claims of a token.
+type Claims struct {
+	jwt.RegisteredClaims
+	OrgUID string `json:"org_uid,omitempty"`
+	// SiteUID binds the token to a site of its org, tokens without one act
+	// on the org as a whole.
+	SiteUID string `json:"site_uid,omitempty"`
+	// Scope is the space separated list of scopes of RFC 8693.
+	Scope string `json:"scope,omitempty"`
+	// Scp is the list of scopes some identity providers use instead.
+	Scp []string `json:"scp,omitempty"`
+	// Roles are expanded into scopes by the authorization policy.
+	Roles []string `json:"roles,omitempty"`
+}
+
+// Scopes returns the scopes granted by the token.
+func (c Claims) Scopes() []string {
+	scopes := strings.Fields(c.Scope)
+	return append(scopes, c.Scp...)
+}
+
+// Config is the required properties to verify tokens.
+type Config struct {
+	Log *zap.SugaredLogger
+	// JWKSFile is the path of a JSON Web Key Set file.
+	JWKSFile string
+	// JWKS is a JSON Web Key Set given inline, used along with the file.
+	JWKS string
+	// Issuer is the required iss claim, not checked when empty.
+	Issuer string
+	// Audience is the required aud claim, not checked when empty.
+	Audience string
+	// Algorithms restricts the accepted algorithms, all of them by default.
+	Algorithms []string
+	// RefreshInterval reloads the key set file to pick up rotated keys.
+	RefreshInterval time.Duration
+	// Leeway allows for clock skew on exp and nbf.
+	Leeway time.Duration
+}
+
+// Auth verifies tokens against a set of keys.
+type Auth struct {
+	cfg    Config
+	parser *jwt.Parser
+
+	mu         sync.RWMutex
+	keys       map[string]key
+	lastReload time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New loads the keys and constructs an Auth. When a refresh interval is set
+// the key set file is reloaded in the background until Close is called.
+func New(cfg Config) (*Auth, error) {
+	algs := cfg.Algorithms
+	if len(algs) == 0 {
+		algs = []string{AlgHS256, AlgRS256, AlgES256}
+	}
+	for _, alg := range algs {
+		if alg != AlgHS256 && alg != AlgRS256 && alg != AlgES256 {
+			return nil, fmt.Errorf("unsupported algorithm %q", alg)
+		}
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(algs),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	a := Auth{
+		cfg:    cfg,
+		parser: jwt.NewParser(opts...),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if err := a.reload(); err != nil {
+		return nil, err
+	}
+
+	if cfg.RefreshInterval > 0 && cfg.JWKSFile != "" {
+		go a.refresh()
+	} else {
+		close(a.done)
+	}
+
+	return &a, nil
+}
+
+// Close stops refreshing the key set.
+func (a *Auth) Close() {
+	select {
+	case <-a.stop:
+	default:
+		close(a.stop)
+	}
+	<-a.done
+}
+
+// Authenticate verifies the token and returns its claims. Every failure
+// wraps ErrUnauthenticated.
+func (a *Auth) Authenticate(ctx context.Context, token string) (Claims, error) {
+	var claims Claims
+	if _, err := a.parser.ParseWithClaims(token, &claims, a.keyFunc); err != nil {
+		return Claims{}, fmt.Errorf("%w: %s", ErrUnauthenticated, err)
+	}
+
+	if claims.Subject == "" {
+		return Claims{}, fmt.Errorf("%w: token has no subject", ErrUnauthenticated)
+	}
+
+	return claims, nil
+}
+
+// keyFunc looks up the verification key of the token.
+func (a *Auth) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	k, ok := a.lookup(kid)
+	if !ok && kid != "" && a.reloadDue() {
+		// The key may have been rotated since the last refresh.
+		if err := a.reload(); err != nil {
+			a.cfg.Log.Errorw("auth", "status", "reloading keys", "ERROR", err)
+		}
+		k, ok = a.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	if k.alg != t.Method.Alg() {
+		return nil, fmt.Errorf("key %q can't verify %s", kid, t.Method.Alg())
+	}
+
+	return k.key, nil
+}
+
+// lookup returns the key by id. Tokens without a key id can only be
+// verified when there is a single key.
+func (a *Auth) lookup(kid string) (key, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if kid == "" && len(a.keys) == 1 {
+		for _, k := range a.keys {
+			return k, true
+		}
+	}
+
+	k, ok := a.keys[kid]
+	return k, ok
+}
+
+func (a *Auth) reloadDue() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.cfg.JWKSFile != "" && time.Since(a.lastReload) > reloadBackoff
+}
+
+// reload reads the key sets and swaps them in.
+func (a *Auth) reload() error {
+	keys := make(map[string]key)
+
+	if a.cfg.JWKS != "" {
+		ks, err := parseJWKS([]byte(a.cfg.JWKS))
+		if err != nil {
+			return fmt.Errorf("inline jwks: %w", err)
+		}
+		for kid, k := range ks {
+			keys[kid] = k
+		}
+	}
+
+	if a.cfg.JWKSFile != "" {
+		b, err := os.ReadFile(a.cfg.JWKSFile)
+		if err != nil {
+			return fmt.Errorf("reading jwks file: %w", err)
+		}
+		ks, err := parseJWKS(b)
+		if err != nil {
+			return fmt.Errorf("jwks file: %w", err)
+		}
+		for kid, k := range ks {
+			keys[kid] = k
+		}
+	}
+
+	if len(keys) == 0 {
+		return errors.New("no signing keys configured")
+	}
+
+	a.mu.Lock()
+	a.keys = keys
+	a.lastReload = time.Now()
+	a.mu.Unlock()
+
+	return nil
+}
+
+// refresh reloads the key set file on every interval. A failed reload keeps
+// the current keys.
+func (a *Auth) refresh() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(a.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := a.reload(); err != nil {
+				a.cfg.Log.Errorw("auth", "status", "refreshing keys", "ERROR", err)
+			}
+		case <-a.stop:
+			return
+		}
+	}
+}
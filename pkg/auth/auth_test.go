@@ -0,0 +1,264 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/pkg/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// Issuer and audience the tokens of the tests are verified against.
+const (
+	issuer   = "https://issuer.example.com"
+	audience = "go-rest"
+)
+
+// keys are the signing keys of the tests along with their key set.
+type keys struct {
+	rsa    *rsa.PrivateKey
+	ec     *ecdsa.PrivateKey
+	secret []byte
+}
+
+func newKeys(t *testing.T) keys {
+	t.Helper()
+
+	rk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key: %s", err)
+	}
+	ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating EC key: %s", err)
+	}
+
+	return keys{rsa: rk, ec: ek, secret: []byte("0123456789abcdef0123456789abcdef")}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// jwks returns the public key set, the RSA key is published as kid.
+func (k keys) jwks(t *testing.T, rsaKid string) string {
+	t.Helper()
+
+	set := map[string]interface{}{
+		"keys": []map[string]string{
+			{"kid": rsaKid, "kty": "RSA", "alg": "RS256", "use": "sig", "n": b64(k.rsa.N.Bytes()), "e": b64(big.NewInt(int64(k.rsa.E)).Bytes())},
+			{"kid": "ec-1", "kty": "EC", "crv": "P-256", "x": b64(k.ec.X.Bytes()), "y": b64(k.ec.Y.Bytes())},
+			{"kid": "hs-1", "kty": "oct", "k": b64(k.secret)},
+			{"kid": "enc-1", "kty": "RSA", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		},
+	}
+
+	b, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("encoding jwks: %s", err)
+	}
+	return string(b)
+}
+
+// claims returns valid claims, changed by fn.
+func claims(fn func(c *auth.Claims)) auth.Claims {
+	now := time.Now()
+	c := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user-1",
+			Issuer:    issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+		OrgUID:  "8d8ac610-566d-4ef0-9c22-186b2a5ed793",
+		SiteUID: "4b4d4a3e-8f2b-4f5c-9a0d-3a1b2c3d4e5f",
+		Scope:   "builds:read builds:write",
+		Scp:     []string{"audit:read"},
+		Roles:   []string{"viewer"},
+	}
+	if fn != nil {
+		fn(&c)
+	}
+	return c
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, c auth.Claims) string {
+	t.Helper()
+
+	tok := jwt.NewWithClaims(method, c)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	s, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatalf("signing token: %s", err)
+	}
+	return s
+}
+
+func newAuth(t *testing.T, cfg auth.Config) *auth.Auth {
+	t.Helper()
+
+	cfg.Log = zap.NewNop().Sugar()
+	a, err := auth.New(cfg)
+	if err != nil {
+		t.Fatalf("constructing auth: %s", err)
+	}
+	t.Cleanup(a.Close)
+
+	return a
+}
+
+func TestAuthenticate(t *testing.T) {
+	k := newKeys(t)
+	a := newAuth(t, auth.Config{JWKS: k.jwks(t, "rsa-1"), Issuer: issuer, Audience: audience})
+
+	tests := map[string]string{
+		"RS256": sign(t, jwt.SigningMethodRS256, "rsa-1", k.rsa, claims(nil)),
+		"ES256": sign(t, jwt.SigningMethodES256, "ec-1", k.ec, claims(nil)),
+		"HS256": sign(t, jwt.SigningMethodHS256, "hs-1", k.secret, claims(nil)),
+	}
+
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := a.Authenticate(context.Background(), token)
+			if err != nil {
+				t.Fatalf("got error %s", err)
+			}
+			if c.Subject != "user-1" || c.OrgUID == "" || c.SiteUID == "" {
+				t.Errorf("got subject %q org %q site %q", c.Subject, c.OrgUID, c.SiteUID)
+			}
+			if want := []string{"builds:read", "builds:write", "audit:read"}; !reflect.DeepEqual(c.Scopes(), want) {
+				t.Errorf("scopes: got %v, want %v", c.Scopes(), want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateRejected(t *testing.T) {
+	k := newKeys(t)
+	a := newAuth(t, auth.Config{JWKS: k.jwks(t, "rsa-1"), Issuer: issuer, Audience: audience, Leeway: time.Second})
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key: %s", err)
+	}
+
+	tests := map[string]string{
+		"expired": sign(t, jwt.SigningMethodRS256, "rsa-1", k.rsa, claims(func(c *auth.Claims) {
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		})),
+		"not yet valid": sign(t, jwt.SigningMethodRS256, "rsa-1", k.rsa, claims(func(c *auth.Claims) {
+			c.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Minute))
+		})),
+		"no expiry": sign(t, jwt.SigningMethodRS256, "rsa-1", k.rsa, claims(func(c *auth.Claims) {
+			c.ExpiresAt = nil
+		})),
+		"other issuer": sign(t, jwt.SigningMethodRS256, "rsa-1", k.rsa, claims(func(c *auth.Claims) {
+			c.Issuer = "https://evil.example.com"
+		})),
+		"other audience": sign(t, jwt.SigningMethodRS256, "rsa-1", k.rsa, claims(func(c *auth.Claims) {
+			c.Audience = jwt.ClaimStrings{"other"}
+		})),
+		"no subject": sign(t, jwt.SigningMethodRS256, "rsa-1", k.rsa, claims(func(c *auth.Claims) {
+			c.Subject = ""
+		})),
+		"unknown kid":      sign(t, jwt.SigningMethodRS256, "rsa-9", k.rsa, claims(nil)),
+		"no kid":           sign(t, jwt.SigningMethodRS256, "", k.rsa, claims(nil)),
+		"other key":        sign(t, jwt.SigningMethodRS256, "rsa-1", other, claims(nil)),
+		"algorithm of kid": sign(t, jwt.SigningMethodHS256, "rsa-1", k.secret, claims(nil)),
+		"encryption key":   sign(t, jwt.SigningMethodRS256, "enc-1", k.rsa, claims(nil)),
+		"none":             sign(t, jwt.SigningMethodNone, "rsa-1", jwt.UnsafeAllowNoneSignatureType, claims(nil)),
+		"garbage":          "not.a.token",
+	}
+
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := a.Authenticate(context.Background(), token); !errors.Is(err, auth.ErrUnauthenticated) {
+				t.Errorf("got %v, want %v", err, auth.ErrUnauthenticated)
+			}
+		})
+	}
+}
+
+func TestAuthenticateAlgorithms(t *testing.T) {
+	k := newKeys(t)
+	a := newAuth(t, auth.Config{JWKS: k.jwks(t, "rsa-1"), Algorithms: []string{auth.AlgRS256}})
+
+	if _, err := a.Authenticate(context.Background(), sign(t, jwt.SigningMethodRS256, "rsa-1", k.rsa, claims(nil))); err != nil {
+		t.Errorf("RS256: got error %s", err)
+	}
+	if _, err := a.Authenticate(context.Background(), sign(t, jwt.SigningMethodHS256, "hs-1", k.secret, claims(nil))); !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Errorf("HS256: got %v, want %v", err, auth.ErrUnauthenticated)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	k := newKeys(t)
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(file, []byte(k.jwks(t, "rsa-1")), 0o600); err != nil {
+		t.Fatalf("writing jwks: %s", err)
+	}
+
+	a := newAuth(t, auth.Config{JWKSFile: file, RefreshInterval: 10 * time.Millisecond})
+
+	// The RSA key is rotated to a new key id.
+	token := sign(t, jwt.SigningMethodRS256, "rsa-2", k.rsa, claims(nil))
+	if _, err := a.Authenticate(context.Background(), token); !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Fatalf("before the rotation: got %v, want %v", err, auth.ErrUnauthenticated)
+	}
+	if err := os.WriteFile(file, []byte(k.jwks(t, "rsa-2")), 0o600); err != nil {
+		t.Fatalf("rotating jwks: %s", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, err := a.Authenticate(context.Background(), token)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("after the rotation: got %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNewInvalidKeys(t *testing.T) {
+	tests := map[string]string{
+		"no keys":         `{"keys":[]}`,
+		"not json":        `keys`,
+		"alg mismatch":    `{"keys":[{"kid":"a","kty":"oct","alg":"RS256","k":"c2VjcmV0"}]}`,
+		"duplicated kid":  `{"keys":[{"kid":"a","kty":"oct","k":"c2VjcmV0"},{"kid":"a","kty":"oct","k":"b3RoZXI"}]}`,
+		"unknown kty":     `{"keys":[{"kid":"a","kty":"OKP","x":"AQAB"}]}`,
+		"unknown curve":   `{"keys":[{"kid":"a","kty":"EC","crv":"P-384","x":"AQAB","y":"AQAB"}]}`,
+		"point off curve": `{"keys":[{"kid":"a","kty":"EC","crv":"P-256","x":"AQAB","y":"AQAB"}]}`,
+		"empty secret":    `{"keys":[{"kid":"a","kty":"oct","k":""}]}`,
+	}
+
+	for name, set := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := auth.New(auth.Config{Log: zap.NewNop().Sugar(), JWKS: set}); err == nil {
+				t.Error("got no error, want one")
+			}
+		})
+	}
+
+	if _, err := auth.New(auth.Config{Log: zap.NewNop().Sugar(), JWKS: `{"keys":[{"kid":"a","kty":"oct","k":"c2VjcmV0"}]}`, Algorithms: []string{"none"}}); err == nil {
+		t.Error("unsupported algorithm: got no error, want one")
+	}
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// jwk is a single JSON Web Key as defined by RFC 7517.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+
+	// oct
+	K string `json:"k"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwks is a JSON Web Key Set.
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// key is a verification key of the key set.
+type key struct {
+	alg string
+	key interface{}
+}
+
+// parseJWKS parses a JSON Web Key Set into verification keys by key id.
+// Keys that are not meant for signatures are skipped.
+func parseJWKS(b []byte) (map[string]key, error) {
+	var set jwks
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("decoding jwks: %w", err)
+	}
+
+	keys := make(map[string]key, len(set.Keys))
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		pub, alg, err := k.parse()
+		if err != nil {
+			return nil, fmt.Errorf("key %d[%s]: %w", i, k.Kid, err)
+		}
+		if k.Alg != "" && k.Alg != alg {
+			return nil, fmt.Errorf("key %d[%s]: alg %s does not match key type %s", i, k.Kid, k.Alg, k.Kty)
+		}
+		if _, ok := keys[k.Kid]; ok {
+			return nil, fmt.Errorf("key %d: duplicated kid %q", i, k.Kid)
+		}
+
+		keys[k.Kid] = key{alg: alg, key: pub}
+	}
+
+	return keys, nil
+}
+
+// parse returns the verification key and the only algorithm it can be used
+// with.
+func (k jwk) parse() (interface{}, string, error) {
+	switch k.Kty {
+	case "oct":
+		secret, err := decodeSegment(k.K)
+		if err != nil || len(secret) == 0 {
+			return nil, "", errors.New("invalid oct key")
+		}
+		return secret, AlgHS256, nil
+
+	case "RSA":
+		n, err := decodeSegment(k.N)
+		if err != nil || len(n) == 0 {
+			return nil, "", errors.New("invalid RSA modulus")
+		}
+		e, err := decodeSegment(k.E)
+		if err != nil || len(e) == 0 {
+			return nil, "", errors.New("invalid RSA exponent")
+		}
+		pub := rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		return &pub, AlgRS256, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, "", fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeSegment(k.X)
+		if err != nil {
+			return nil, "", errors.New("invalid EC x coordinate")
+		}
+		y, err := decodeSegment(k.Y)
+		if err != nil {
+			return nil, "", errors.New("invalid EC y coordinate")
+		}
+		pub := ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, "", errors.New("EC point is not on the curve")
+		}
+		return &pub, AlgES256, nil
+	}
+
+	return nil, "", fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// decodeSegment decodes base64url with or without padding.
+func decodeSegment(s string) ([]byte, error) {
+	if b, err := base64.RawURLEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return base64.URLEncoding.DecodeString(s)
+}
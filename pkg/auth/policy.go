@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Policy maps roles to the scopes they grant. Callers are granted the
+// scopes their credentials carry, plus the scopes of every role they carry.
+// Role names are case insensitive as config keys are.
+type Policy struct {
+	roles map[string][]string
+}
+
+// NewPolicy constructs a policy from a role to scopes table.
+func NewPolicy(roles map[string][]string) (Policy, error) {
+	p := Policy{roles: make(map[string][]string, len(roles))}
+	for role, scopes := range roles {
+		role = strings.ToLower(strings.TrimSpace(role))
+		if role == "" {
+			return Policy{}, errors.New("role names cannot be blank")
+		}
+		for _, scope := range scopes {
+			if strings.TrimSpace(scope) == "" {
+				return Policy{}, fmt.Errorf("role %q has a blank scope", role)
+			}
+			p.roles[role] = append(p.roles[role], strings.TrimSpace(scope))
+		}
+	}
+	return p, nil
+}
+
+// Scopes expands the roles into their scopes, merged with the scopes
+// granted directly.
+func (p Policy) Scopes(roles []string, scopes []string) []string {
+	granted := make(map[string]bool)
+	for _, scope := range scopes {
+		granted[scope] = true
+	}
+	for _, role := range roles {
+		for _, scope := range p.roles[strings.ToLower(role)] {
+			granted[scope] = true
+		}
+	}
+
+	res := make([]string, 0, len(granted))
+	for scope := range granted {
+		res = append(res, scope)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Missing returns the required scopes the roles and scopes don't grant.
+func (p Policy) Missing(roles []string, scopes []string, required []string) []string {
+	granted := make(map[string]bool)
+	for _, scope := range p.Scopes(roles, scopes) {
+		granted[scope] = true
+	}
+
+	var missing []string
+	for _, scope := range required {
+		if !granted[scope] {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
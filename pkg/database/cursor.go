@@ -0,0 +1,86 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Keyed is implemented by the rows of paginated queries so cursors can be
+// issued from them.
+type Keyed interface {
+	// Keyset returns the id of the row and its value of the sort column.
+	Keyset(sort string) (id string, value time.Time)
+}
+
+// cursor is the position a cursor points at. It is encoded as base64 json
+// and is opaque to the clients.
+type cursor struct {
+	Sort      string    `json:"s"`
+	Direction string    `json:"d"`
+	Value     time.Time `json:"v"`
+	ID        int64     `json:"i"`
+	Backward  bool      `json:"b,omitempty"`
+}
+
+// sortColumns lists the columns PaginationParams can sort on.
+var sortColumns = map[string]bool{
+	"created_on": true,
+	"updated_on": true,
+	"id":         true,
+}
+
+func encodeCursor(pagi Pagination, row Keyed, backward bool) (string, error) {
+	id, value := row.Keyset(pagi.Sort)
+
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("cursor id[%s]: %w", id, err)
+	}
+
+	c := cursor{
+		Sort:      pagi.Sort,
+		Direction: pagi.Direction,
+		ID:        n,
+		Backward:  backward,
+	}
+	if pagi.Sort != "id" {
+		c.Value = value.UTC()
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(str string) (cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return cursor{}, err
+	}
+
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return cursor{}, err
+	}
+
+	// The sort column and direction end up in the query, only accept the
+	// values PaginationParams would set.
+	if !sortColumns[c.Sort] {
+		return cursor{}, errors.New("invalid sort")
+	}
+	if c.Direction != "asc" && c.Direction != "desc" {
+		return cursor{}, errors.New("invalid direction")
+	}
+	if c.ID < 1 {
+		return cursor{}, errors.New("invalid id")
+	}
+
+	return c, nil
+}
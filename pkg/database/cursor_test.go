@@ -0,0 +1,181 @@
+package database_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite"
+)
+
+// item is a row of the paginated test table.
+type item struct {
+	ID        string    `db:"id"`
+	CreatedOn time.Time `db:"created_on"`
+}
+
+// Keyset implements database.Keyed.
+func (i item) Keyset(sort string) (string, time.Time) {
+	return i.ID, i.CreatedOn
+}
+
+// openItems returns a SQLite database holding n items, created in pairs
+// sharing the same timestamp so the id has to break the ties.
+func openItems(t *testing.T, n int) *sqlx.DB {
+	t.Helper()
+
+	db, err := database.Open(database.Config{
+		Type: database.DialectSQLite,
+		Name: filepath.Join(t.TempDir(), "cursor.db"),
+	})
+	if err != nil {
+		t.Fatalf("opening database: %s", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	if _, err := db.Exec(`CREATE TABLE item (id integer primary key, created_on datetime not null)`); err != nil {
+		t.Fatalf("creating table: %s", err)
+	}
+	start := time.Date(2021, 5, 25, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= n; i++ {
+		if _, err := db.Exec(`INSERT INTO item (id, created_on) VALUES (?, ?)`, i, start.Add(time.Duration(i/2)*time.Minute)); err != nil {
+			t.Fatalf("inserting item %d: %s", i, err)
+		}
+	}
+
+	return db
+}
+
+// queryPage reads the page of items the query parameters ask for.
+func queryPage(t *testing.T, db *sqlx.DB, params url.Values) ([]string, database.Page) {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/items?"+params.Encode(), nil)
+	pagi, err := database.PaginationParams(r)
+	if err != nil {
+		t.Fatalf("pagination %v: %s", params, err)
+	}
+
+	const q = `
+	SELECT
+		id, created_on
+	FROM
+		item
+	WHERE
+		1 = 1:keyset
+	ORDER BY
+		:sort :direction, id :direction
+	LIMIT :per_page OFFSET :page`
+
+	pq := database.PageQuery{
+		Query: database.PaginationQuery(pagi, database.KeysetQuery(pagi, "", q)),
+	}
+
+	var items []item
+	page, err := database.NamedQueryPage(context.Background(), zap.NewNop().Sugar(), db, pq, pagi, pagi, &items)
+	if err != nil {
+		t.Fatalf("query page %v: %s", params, err)
+	}
+
+	ids := make([]string, len(items))
+	for i, it := range items {
+		ids[i] = it.ID
+	}
+	return ids, page
+}
+
+func TestCursorWalk(t *testing.T) {
+	db := openItems(t, 7)
+
+	tests := []struct {
+		name      string
+		sort      string
+		direction string
+		want      [][]string
+	}{
+		{"created desc", "created", "desc", [][]string{{"7", "6", "5"}, {"4", "3", "2"}, {"1"}}},
+		{"created asc", "created", "asc", [][]string{{"1", "2", "3"}, {"4", "5", "6"}, {"7"}}},
+		{"id desc", "id", "desc", [][]string{{"7", "6", "5"}, {"4", "3", "2"}, {"1"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := url.Values{"per_page": {"3"}, "sort": {tt.sort}, "direction": {tt.direction}}
+
+			// Walk forward from the first page.
+			var pages []database.Page
+			for i, want := range tt.want {
+				ids, page := queryPage(t, db, params)
+				if !reflect.DeepEqual(ids, want) {
+					t.Fatalf("page %d: got %v, want %v", i+1, ids, want)
+				}
+				if page.HasMore != (i < len(tt.want)-1) {
+					t.Errorf("page %d: has_more got %t", i+1, page.HasMore)
+				}
+				pages = append(pages, page)
+				params.Set("cursor", page.NextCursor)
+			}
+			if last := pages[len(pages)-1]; last.NextCursor != "" {
+				t.Errorf("last page: next cursor %q, want none", last.NextCursor)
+			}
+			if first := pages[0]; first.PrevCursor != "" {
+				t.Errorf("first page: prev cursor %q, want none", first.PrevCursor)
+			}
+
+			// Walk back from the last page.
+			params = url.Values{"per_page": {"3"}, "cursor": {pages[len(pages)-1].PrevCursor}}
+			for i := len(tt.want) - 2; i >= 0; i-- {
+				ids, page := queryPage(t, db, params)
+				if !reflect.DeepEqual(ids, tt.want[i]) {
+					t.Fatalf("back to page %d: got %v, want %v", i+1, ids, tt.want[i])
+				}
+				if !page.HasMore || page.NextCursor == "" {
+					t.Errorf("back to page %d: want a next page", i+1)
+				}
+				if (page.PrevCursor != "") != (i > 0) {
+					t.Errorf("back to page %d: prev cursor %q", i+1, page.PrevCursor)
+				}
+				params.Set("cursor", page.PrevCursor)
+			}
+		})
+	}
+}
+
+func TestCursorInvalid(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := map[string]string{
+		"not base64":    "%%%",
+		"not json":      encode("created_on"),
+		"unknown sort":  encode(`{"s":"label; DROP TABLE item","d":"asc","i":1}`),
+		"bad direction": encode(`{"s":"id","d":"sideways","i":1}`),
+		"missing id":    encode(`{"s":"id","d":"asc"}`),
+		"negative id":   encode(`{"s":"created_on","d":"desc","i":-4}`),
+	}
+
+	for name, c := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/items?cursor="+url.QueryEscape(c), nil)
+
+			_, err := database.PaginationParams(r)
+
+			var re *api.RequestError
+			if !errors.As(err, &re) || re.Status != http.StatusBadRequest {
+				t.Errorf("got %v, want a 400 request error", err)
+			}
+		})
+	}
+}
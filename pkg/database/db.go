@@ -2,18 +2,17 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net/url"
 	"reflect"
-	"regexp"
 	"strings"
 	"time"
 
-	"cloud.google.com/go/compute/metadata"
 	"github.com/chaitanyamaili/go_rest/pkg/api"
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
@@ -21,6 +20,7 @@ import (
 var (
 	ErrDBNotFound        = errors.New("data not found")
 	ErrDBDuplicatedEntry = errors.New("duplicated entry")
+	ErrDBInvalidRef      = errors.New("referenced entry does not exist")
 )
 
 // Config is the required properties for the db
@@ -49,9 +49,13 @@ type DBResults struct {
 // Open a connection to a db
 func Open(cfg Config) (*sqlx.DB, error) {
 	fmt.Println("database")
+	driver, err := driverName(cfg.Type)
+	if err != nil {
+		return nil, err
+	}
 	cs := connectionString(cfg)
 
-	db, err := sqlx.Open(cfg.Type, cs)
+	db, err := sqlx.Open(driver, cs)
 	if err != nil {
 		return nil, err
 	}
@@ -62,44 +66,6 @@ func Open(cfg Config) (*sqlx.DB, error) {
 	return db, nil
 }
 
-func connectionString(cfg Config) string {
-
-	switch strings.ToLower(cfg.Type) {
-	case "mysql", "maria", "mariadb":
-		return mysqlConnectionString(cfg)
-	}
-
-	return ""
-}
-
-func mysqlConnectionString(cfg Config) string {
-	// For Local
-	if !metadata.OnGCE() {
-		q := make(url.Values)
-		q.Set("parseTime", "true")
-
-		if cfg.Port > 0 {
-			cfg.Host = fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
-		}
-
-		//Weird mysql issue, host:port has to have parenthesis around it
-		cfg.Host = "(" + cfg.Host + ")"
-
-		u := url.URL{
-			User:     url.UserPassword(cfg.User, cfg.Password),
-			Host:     cfg.Host,
-			Path:     cfg.Name,
-			RawQuery: q.Encode(),
-		}
-		return strings.Trim(u.String(), "/")
-	}
-	// For CF in GCP
-	u := fmt.Sprintf("%s:%s@unix(/%s)/%s?parseTime=true",
-		cfg.User, cfg.Password, cfg.Host, cfg.Name)
-
-	return strings.Trim(u, "/")
-}
-
 // -----------------------------------------------------------------------
 // Debugging
 // -----------------------------------------------------------------------
@@ -139,16 +105,23 @@ func StatusCheck(ctx context.Context, db *sqlx.DB) error {
 
 // Transactor interface needed to begin transaction.
 type Transactor interface {
-	Beginx() (*sqlx.Tx, error)
+	BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error)
 }
 
 // WithinTran runs passed function and do commit/rollback at the end.
-func WithinTran(ctx context.Context, log *zap.SugaredLogger, db Transactor, fn func(sqlx.ExtContext) error) error {
+func WithinTran(ctx context.Context, log *zap.SugaredLogger, db Transactor, fn func(sqlx.ExtContext) error) (err error) {
 	traceID := api.GetTracerUID(ctx)
 
+	var dialect string
+	if ext, ok := db.(sqlx.ExtContext); ok {
+		dialect = DialectOf(ext)
+	}
+	ctx, span := startSpan(ctx, "pkg.database.tran", dialect, "")
+	defer func() { endSpan(span, err) }()
+
 	// Begin the transaction.
-	log.Infow("begin db transaction", "traceid", traceID)
-	tx, err := db.Beginx()
+	log.Infow("begin db transaction", "tracer_uid", traceID)
+	tx, err := db.BeginTxx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin db transaction: %w", err)
 	}
@@ -161,9 +134,9 @@ func WithinTran(ctx context.Context, log *zap.SugaredLogger, db Transactor, fn f
 	// need to roll back the transaction.
 	defer func() {
 		if mustRollback {
-			log.Infow("rollback db transaction", "traceid", traceID)
+			log.Infow("rollback db transaction", "tracer_uid", traceID)
 			if err := tx.Rollback(); err != nil {
-				log.Errorw("unable to rollback db transaction", "traceid", traceID, "ERROR", err)
+				log.Errorw("unable to rollback db transaction", "tracer_uid", traceID, "ERROR", err)
 			}
 		}
 	}()
@@ -178,7 +151,7 @@ func WithinTran(ctx context.Context, log *zap.SugaredLogger, db Transactor, fn f
 	mustRollback = false
 
 	// Commit the transaction.
-	log.Infow("commit db transaction", "traceid", traceID)
+	log.Infow("commit db transaction", "tracer_uid", traceID)
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("commit db transaction: %w", err)
 	}
@@ -192,22 +165,31 @@ func WithinTran(ctx context.Context, log *zap.SugaredLogger, db Transactor, fn f
 
 // NamedExecContext is a helper function to execute a CUD operation with
 // logging and tracing.
-func NamedExecContext(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}) (DBResults, error) {
+func NamedExecContext(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}) (dbres DBResults, err error) {
 	q := queryString(query, data)
 	traceID := api.GetTracerUID(ctx)
-	log.Debugw("database.NamedExecContext", "traceid", traceID, "query", q)
+	log.Debugw("database.NamedExecContext", "tracer_uid", traceID, "query", q)
+
+	ctx, span := startSpan(ctx, "pkg.database.exec", DialectOf(db), query)
+	defer func() {
+		span.SetAttributes(attribute.Int64("db.rows_affected", dbres.AffectedRows))
+		endSpan(span, err)
+	}()
 
-	var dbres DBResults
 	res, err := sqlx.NamedExecContext(ctx, db, query, data)
 	if err != nil {
 		return DBResults{}, err
 	}
 
-	lid, err := res.LastInsertId()
-	if err != nil {
-		return DBResults{}, err
+	// Postgres has no notion of a last insert id, inserts that need the
+	// id back must use NamedInsertContext instead.
+	if DialectOf(db) != DialectPostgres {
+		lid, err := res.LastInsertId()
+		if err != nil {
+			return DBResults{}, err
+		}
+		dbres.LastInsertID = lid
 	}
-	dbres.LastInsertID = lid
 
 	ra, err := res.RowsAffected()
 	if err != nil {
@@ -222,17 +204,58 @@ func NamedExecContext(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtCo
 	return dbres, err
 }
 
+// NamedInsertContext is a helper function to execute an INSERT that needs
+// the generated id back. Dialects without LastInsertId support get a
+// RETURNING clause appended to the query.
+func NamedInsertContext(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}) (dbres DBResults, err error) {
+	if DialectOf(db) != DialectPostgres {
+		return NamedExecContext(ctx, log, db, query, data)
+	}
+
+	query = strings.TrimRight(query, " \t\n;") + "\n\tRETURNING id"
+	q := queryString(query, data)
+	traceID := api.GetTracerUID(ctx)
+	log.Debugw("database.NamedInsertContext", "tracer_uid", traceID, "query", q)
+
+	ctx, span := startSpan(ctx, "pkg.database.insert", DialectOf(db), query)
+	defer func() {
+		span.SetAttributes(attribute.Int64("db.rows_affected", dbres.AffectedRows))
+		endSpan(span, err)
+	}()
+
+	rows, err := sqlx.NamedQueryContext(ctx, db, query, data)
+	if err != nil {
+		return DBResults{}, err
+	}
+	defer rows.Close() //nolint:all
+
+	for rows.Next() {
+		if err := rows.Scan(&dbres.LastInsertID); err != nil {
+			return DBResults{}, err
+		}
+		dbres.AffectedRows++
+	}
+
+	return dbres, rows.Err()
+}
+
 // NamedQuerySlice is a helper function for executing queries that return a
 // collection of data to be unmarshalled into a slice.
-func NamedQuerySlice(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}, dest interface{}) error {
+func NamedQuerySlice(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}, dest interface{}) (err error) {
 	q := queryString(query, data)
 	traceID := api.GetTracerUID(ctx)
-	log.Debugw("database.NamedQuerySlice", "traceid", traceID, "query", q)
+	log.Debugw("database.NamedQuerySlice", "tracer_uid", traceID, "query", q)
 	val := reflect.ValueOf(dest)
 	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Slice {
 		return errors.New("must provide a pointer to a slice")
 	}
 
+	ctx, span := startSpan(ctx, "pkg.database.queryslice", DialectOf(db), query)
+	defer func() {
+		span.SetAttributes(attribute.Int("db.rows_returned", val.Elem().Len()))
+		endSpan(span, err)
+	}()
+
 	rows, err := sqlx.NamedQueryContext(ctx, db, query, data)
 	if err != nil {
 		return err
@@ -253,10 +276,20 @@ func NamedQuerySlice(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtCon
 
 // NamedQueryStruct is a helper function for executing queries that return a
 // single value to be unmarshalled into a struct type.
-func NamedQueryStruct(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}, dest interface{}) error {
+func NamedQueryStruct(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data interface{}, dest interface{}) (err error) {
 	q := queryString(query, data)
 	traceID := api.GetTracerUID(ctx)
-	log.Debugw("database.NamedQuerySlice", "traceid", traceID, "query", q)
+	log.Debugw("database.NamedQuerySlice", "tracer_uid", traceID, "query", q)
+
+	ctx, span := startSpan(ctx, "pkg.database.querystruct", DialectOf(db), query)
+	defer func() {
+		rows := 1
+		if err != nil {
+			rows = 0
+		}
+		span.SetAttributes(attribute.Int("db.rows_returned", rows))
+		endSpan(span, err)
+	}()
 
 	rows, err := sqlx.NamedQueryContext(ctx, db, query, data)
 	if err != nil {
@@ -303,8 +336,5 @@ func queryString(query string, args ...interface{}) string {
 		query = strings.Replace(query, "?", value, 1)
 	}
 
-	singleSpacePattern := regexp.MustCompile(`\s\s+`)
-	query = singleSpacePattern.ReplaceAllString(query, " ")
-
-	return strings.Trim(query, " ")
+	return sanitize(query)
 }
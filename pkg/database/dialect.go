@@ -0,0 +1,141 @@
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"cloud.google.com/go/compute/metadata"
+	"github.com/jmoiron/sqlx"
+)
+
+// Set of supported database dialects.
+const (
+	DialectMySQL    = "mysql"
+	DialectPostgres = "postgres"
+	DialectSQLite   = "sqlite"
+)
+
+// driverNames maps every dialect to the database/sql driver registered for
+// it. The drivers themselves are imported by the services.
+var driverNames = map[string]string{
+	DialectMySQL:    "mysql",
+	DialectPostgres: "pgx",
+	DialectSQLite:   "sqlite",
+}
+
+func init() {
+	// sqlx only knows the cgo sqlite3 driver name, make sure named queries
+	// are bound with '?' placeholders for the pure go driver as well.
+	sqlx.BindDriver("sqlite", sqlx.QUESTION)
+}
+
+// Dialect normalizes the configured database type into one of the
+// supported dialects. An empty string is returned for unknown types.
+func Dialect(typ string) string {
+	switch strings.ToLower(typ) {
+	case "mysql", "maria", "mariadb":
+		return DialectMySQL
+	case "postgres", "postgresql", "pgx":
+		return DialectPostgres
+	case "sqlite", "sqlite3":
+		return DialectSQLite
+	}
+
+	return ""
+}
+
+// DialectOf returns the dialect of the connection or transaction based on
+// the name of the driver it was opened with.
+func DialectOf(db sqlx.ExtContext) string {
+	return Dialect(db.DriverName())
+}
+
+// driverName returns the database/sql driver for the configured type.
+func driverName(typ string) (string, error) {
+	name, ok := driverNames[Dialect(typ)]
+	if !ok {
+		return "", fmt.Errorf("unsupported database type %q", typ)
+	}
+	return name, nil
+}
+
+func connectionString(cfg Config) string {
+	switch Dialect(cfg.Type) {
+	case DialectMySQL:
+		return mysqlConnectionString(cfg)
+	case DialectPostgres:
+		return postgresConnectionString(cfg)
+	case DialectSQLite:
+		return sqliteConnectionString(cfg)
+	}
+
+	return ""
+}
+
+func mysqlConnectionString(cfg Config) string {
+	// For Local
+	if !metadata.OnGCE() {
+		q := make(url.Values)
+		q.Set("parseTime", "true")
+
+		if cfg.Port > 0 {
+			cfg.Host = fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+		}
+
+		//Weird mysql issue, host:port has to have parenthesis around it
+		cfg.Host = "(" + cfg.Host + ")"
+
+		u := url.URL{
+			User:     url.UserPassword(cfg.User, cfg.Password),
+			Host:     cfg.Host,
+			Path:     cfg.Name,
+			RawQuery: q.Encode(),
+		}
+		return strings.Trim(u.String(), "/")
+	}
+	// For CF in GCP
+	u := fmt.Sprintf("%s:%s@unix(/%s)/%s?parseTime=true",
+		cfg.User, cfg.Password, cfg.Host, cfg.Name)
+
+	return strings.Trim(u, "/")
+}
+
+func postgresConnectionString(cfg Config) string {
+	sslMode := "require"
+	if cfg.DisableTLS {
+		sslMode = "disable"
+	}
+
+	q := make(url.Values)
+	q.Set("sslmode", sslMode)
+	q.Set("timezone", "utc")
+
+	host := cfg.Host
+	// For CF in GCP the host is the cloud sql unix socket directory.
+	if metadata.OnGCE() {
+		q.Set("host", "/"+strings.Trim(cfg.Host, "/"))
+		host = ""
+	} else if cfg.Port > 0 {
+		host = fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     host,
+		Path:     cfg.Name,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
+
+func sqliteConnectionString(cfg Config) string {
+	// The db name is the path of the database file, or ":memory:".
+	q := make(url.Values)
+	q.Add("_pragma", "foreign_keys(1)")
+	q.Add("_pragma", "busy_timeout(5000)")
+	q.Set("_time_format", "sqlite")
+
+	return fmt.Sprintf("file:%s?%s", cfg.Name, q.Encode())
+}
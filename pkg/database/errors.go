@@ -1,6 +1,22 @@
 package database
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Driver specific codes reported for constraint violations.
+const (
+	mysqlDuplicateEntry         = 1062
+	mysqlNoReferencedRow        = 1452
+	mysqlRowIsReferenced        = 1451
+	postgresUniqueViolation     = "23505"
+	postgresForeignKeyViolation = "23503"
+	sqliteConstraintPK          = 1555
+	sqliteConstraintUnique      = 2067
+	sqliteConstraintForeignKey  = 787
+)
 
 // ErrorResponse is the form used for Database responses from failures in the DB
 type ErrorResponse struct {
@@ -44,3 +60,49 @@ func GetError(err error) *Error {
 	}
 	return re
 }
+
+// IsDuplicateEntry checks if the error was raised by the driver because a
+// unique or primary key constraint was violated.
+func IsDuplicateEntry(err error) bool {
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		return me.Number == mysqlDuplicateEntry
+	}
+
+	// pgconn.PgError
+	var pe interface{ SQLState() string }
+	if errors.As(err, &pe) {
+		return pe.SQLState() == postgresUniqueViolation
+	}
+
+	// modernc.org/sqlite.Error
+	var se interface{ Code() int }
+	if errors.As(err, &se) {
+		return se.Code() == sqliteConstraintUnique || se.Code() == sqliteConstraintPK
+	}
+
+	return false
+}
+
+// IsForeignKeyViolation checks if the error was raised by the driver because
+// a row references, or is referenced by, a row that doesn't exist.
+func IsForeignKeyViolation(err error) bool {
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		return me.Number == mysqlNoReferencedRow || me.Number == mysqlRowIsReferenced
+	}
+
+	// pgconn.PgError
+	var pe interface{ SQLState() string }
+	if errors.As(err, &pe) {
+		return pe.SQLState() == postgresForeignKeyViolation
+	}
+
+	// modernc.org/sqlite.Error
+	var se interface{ Code() int }
+	if errors.As(err, &se) {
+		return se.Code() == sqliteConstraintForeignKey
+	}
+
+	return false
+}
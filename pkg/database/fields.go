@@ -0,0 +1,216 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/chaitanyamaili/go_rest/pkg/validate"
+)
+
+// Fields describes the fields and the relations a read can select.
+type Fields struct {
+	// Columns maps every field name to the column selecting it.
+	Columns map[string]string
+	// Includes maps every relation name to the columns embedding it.
+	Includes map[string][]string
+}
+
+// Fieldset holds the fields and relations selected by the fields and the
+// include query parameters. The zero value selects everything.
+type Fieldset struct {
+	fields   []string
+	includes []string
+	partial  bool
+}
+
+// FieldsParams parses the fields and the include query parameters. Without
+// fields every field is selected, without include every relation is
+// embedded unless fields were given. Relations can be listed in fields too.
+// Unknown names are reported as field errors.
+func FieldsParams(r *http.Request, spec Fields) (Fieldset, error) {
+	qparams := r.URL.Query()
+	var fs Fieldset
+	var fe validate.FieldErrors
+
+	if val, ok := qparams["fields"]; ok {
+		fs.partial = true
+		names, unknown := splitNames(val[0], func(name string) bool {
+			_, isColumn := spec.Columns[name]
+			_, isRelation := spec.Includes[name]
+			return isColumn || isRelation
+		})
+		if len(unknown) > 0 {
+			fe.FieldError = append(fe.FieldError, validate.FieldError{
+				Field: "fields",
+				Error: fmt.Sprintf("unknown fields: %s", strings.Join(unknown, ", ")),
+			})
+		}
+		if len(names) == 0 && len(unknown) == 0 {
+			fe.FieldError = append(fe.FieldError, validate.FieldError{
+				Field: "fields",
+				Error: "fields cannot be blank",
+			})
+		}
+
+		// Relations listed as fields are embedded like with include.
+		for _, name := range names {
+			if _, ok := spec.Includes[name]; ok {
+				fs.includes = append(fs.includes, name)
+				continue
+			}
+			fs.fields = append(fs.fields, name)
+		}
+	}
+
+	if val, ok := qparams["include"]; ok {
+		fs.partial = true
+		if _, ok := qparams["fields"]; !ok {
+			fs.fields = spec.fieldNames()
+		}
+		names, unknown := splitNames(val[0], func(name string) bool {
+			_, ok := spec.Includes[name]
+			return ok
+		})
+		if len(unknown) > 0 {
+			fe.FieldError = append(fe.FieldError, validate.FieldError{
+				Field: "include",
+				Error: fmt.Sprintf("unknown relations: %s", strings.Join(unknown, ", ")),
+			})
+		}
+		fs.includes = append(fs.includes, names...)
+	}
+
+	if len(fe.FieldError) > 0 {
+		fe.CustomError = "invalid fields"
+		return Fieldset{}, fe
+	}
+
+	return fs, nil
+}
+
+// FieldsQuery replaces the :columns placeholder of the query with the
+// columns of the fieldset. The required fields, such as the keys cursors are
+// issued from, are always selected but left out of the response by Project.
+func FieldsQuery(spec Fields, fs Fieldset, q string, required ...string) string {
+	fields := fs.fields
+	includes := fs.includes
+	if !fs.partial {
+		fields = spec.fieldNames()
+		includes = spec.relationNames()
+	}
+
+	selected := make(map[string]bool)
+	var columns []string
+	for _, name := range append(append([]string{}, required...), fields...) {
+		col, ok := spec.Columns[name]
+		if !ok || selected[name] {
+			continue
+		}
+		selected[name] = true
+		columns = append(columns, col)
+	}
+	for _, name := range includes {
+		if selected["/"+name] {
+			continue
+		}
+		selected["/"+name] = true
+		columns = append(columns, spec.Includes[name]...)
+	}
+
+	return strings.ReplaceAll(q, ":columns", strings.Join(columns, ",\n\t\t"))
+}
+
+// Partial reports whether the fields or include parameters narrowed the
+// response down from the full representation.
+func (fs Fieldset) Partial() bool {
+	return fs.partial
+}
+
+// Project strips the fields that were not selected from the response data,
+// a value or a slice of values that marshal to json objects.
+func (fs Fieldset) Project(data interface{}) (interface{}, error) {
+	if !fs.partial {
+		return data, nil
+	}
+
+	keep := make(map[string]bool)
+	for _, name := range fs.fields {
+		keep[name] = true
+	}
+	for _, name := range fs.includes {
+		keep[name] = true
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("project fields: %w", err)
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("project fields: %w", err)
+	}
+
+	project := func(v interface{}) {
+		if m, ok := v.(map[string]interface{}); ok {
+			for k := range m {
+				if !keep[k] {
+					delete(m, k)
+				}
+			}
+		}
+	}
+
+	if s, ok := v.([]interface{}); ok {
+		for _, e := range s {
+			project(e)
+		}
+		return s, nil
+	}
+	project(v)
+
+	return v, nil
+}
+
+// splitNames splits a comma separated list of names, reporting the ones
+// that are not valid.
+func splitNames(list string, valid func(string) bool) ([]string, []string) {
+	var names, unknown []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if !valid(name) {
+			unknown = append(unknown, name)
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, unknown
+}
+
+// fieldNames returns the field names in order so queries are stable.
+func (f Fields) fieldNames() []string {
+	names := make([]string, 0, len(f.Columns))
+	for name := range f.Columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// relationNames returns the relation names in order so queries are stable.
+func (f Fields) relationNames() []string {
+	names := make([]string, 0, len(f.Includes))
+	for name := range f.Includes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,155 @@
+package database_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+	"github.com/chaitanyamaili/go_rest/pkg/validate"
+)
+
+// fields is a spec like the ones of the models.
+var fields = database.Fields{
+	Columns: map[string]string{
+		"id":    "b.id",
+		"label": "b.label",
+		"sha":   "b.commit_sha",
+	},
+	Includes: map[string][]string{
+		"status": {"bs.alias AS \"status.alias\"", "bs.name AS \"status.name\""},
+	},
+}
+
+// build is a response value the fieldsets project.
+type build struct {
+	ID     string            `json:"id"`
+	Label  string            `json:"label"`
+	Sha    string            `json:"sha"`
+	Status map[string]string `json:"status"`
+}
+
+func fieldset(t *testing.T, query string) database.Fieldset {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/build?"+query, nil)
+	fs, err := database.FieldsParams(r, fields)
+	if err != nil {
+		t.Fatalf("fields %q: %s", query, err)
+	}
+	return fs
+}
+
+func TestFieldsQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		required []string
+		columns  []string
+		partial  bool
+	}{
+		{
+			name:    "everything",
+			query:   "",
+			columns: []string{"b.id", "b.label", "b.commit_sha", `bs.alias AS "status.alias"`, `bs.name AS "status.name"`},
+		},
+		{
+			name:     "fields keep the required keys",
+			query:    "fields=label",
+			required: []string{"id"},
+			columns:  []string{"b.id", "b.label"},
+			partial:  true,
+		},
+		{
+			name:    "relation listed as a field",
+			query:   "fields=Label,status,label",
+			columns: []string{"b.label", `bs.alias AS "status.alias"`, `bs.name AS "status.name"`},
+			partial: true,
+		},
+		{
+			name:    "include alone selects every field",
+			query:   "include=status",
+			columns: []string{"b.id", "b.label", "b.commit_sha", `bs.alias AS "status.alias"`, `bs.name AS "status.name"`},
+			partial: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := fieldset(t, tt.query)
+
+			want := "SELECT " + strings.Join(tt.columns, ",\n\t\t")
+			if got := database.FieldsQuery(fields, fs, "SELECT :columns", tt.required...); got != want {
+				t.Errorf("query: got %q, want %q", got, want)
+			}
+			if fs.Partial() != tt.partial {
+				t.Errorf("partial: got %t, want %t", fs.Partial(), tt.partial)
+			}
+		})
+	}
+}
+
+func TestFieldsParamsInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		field string
+	}{
+		{"unknown field", "fields=id,secret", "fields"},
+		{"blank fields", "fields=,", "fields"},
+		{"field as relation", "include=label", "include"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/build?"+tt.query, nil)
+
+			_, err := database.FieldsParams(r, fields)
+
+			var fe validate.FieldErrors
+			if !errors.As(err, &fe) || len(fe.FieldError) != 1 || fe.FieldError[0].Field != tt.field {
+				t.Errorf("got %v, want a single error on %s", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestProject(t *testing.T) {
+	b := build{ID: "1", Label: "build-1", Sha: "1234567", Status: map[string]string{"alias": "success"}}
+
+	t.Run("full", func(t *testing.T) {
+		got, err := fieldset(t, "").Project([]build{b})
+		if err != nil {
+			t.Fatalf("project: %s", err)
+		}
+		if !reflect.DeepEqual(got, []build{b}) {
+			t.Errorf("got %v, want the data untouched", got)
+		}
+	})
+
+	t.Run("slice", func(t *testing.T) {
+		got, err := fieldset(t, "fields=id,status").Project([]build{b})
+		if err != nil {
+			t.Fatalf("project: %s", err)
+		}
+		want := []interface{}{
+			map[string]interface{}{"id": "1", "status": map[string]interface{}{"alias": "success"}},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("value", func(t *testing.T) {
+		got, err := fieldset(t, "fields=label").Project(b)
+		if err != nil {
+			t.Fatalf("project: %s", err)
+		}
+		if want := map[string]interface{}{"label": "build-1"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
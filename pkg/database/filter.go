@@ -0,0 +1,237 @@
+package database
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/pkg/validate"
+)
+
+// Set of operators a filter can apply to its column.
+const (
+	FilterEqual  = "eq"
+	FilterAfter  = "after"
+	FilterBefore = "before"
+)
+
+// Set of value types a filter can parse.
+const (
+	FilterString = "string"
+	FilterTime   = "time"
+)
+
+// filterPrefixWildcard at the end of a string value turns an equality
+// filter into a prefix match, e.g. commit_sha=abc123*
+const filterPrefixWildcard = "*"
+
+// filterLikeEscape is used to escape LIKE wildcards. A backslash would need
+// different quoting on every dialect.
+const filterLikeEscape = "!"
+
+// FilterField describes a whitelisted query parameter and how it maps to
+// a column of the query.
+type FilterField struct {
+	// Column is the qualified column the parameter filters, e.g. b.label
+	Column string
+	// Op is one of the filter operators, FilterEqual by default.
+	Op string
+	// Type is one of the filter value types, FilterString by default.
+	Type string
+	// Prefix allows a trailing '*' to match the start of the column.
+	Prefix bool
+	// Multi allows a comma separated list of values matched with IN.
+	Multi bool
+	// Check optionally validates every string value.
+	Check func(string) error
+}
+
+// Filter holds the parameterised conditions parsed from the query string.
+type Filter struct {
+	conds []string
+	args  map[string]interface{}
+}
+
+// NewFilter returns an empty filter.
+func NewFilter() Filter {
+	return Filter{
+		args: make(map[string]interface{}),
+	}
+}
+
+// FilterParams parses the whitelisted query parameters into a filter.
+// Parameters that are not whitelisted are ignored so they can be used by
+// other helpers such as PaginationParams. Invalid values are reported as
+// field errors.
+func FilterParams(r *http.Request, fields map[string]FilterField) (Filter, error) {
+	qparams := r.URL.Query()
+	f := NewFilter()
+
+	// Walk the parameters in order so the generated query is stable.
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var fe validate.FieldErrors
+	for _, name := range names {
+		val, ok := qparams[name]
+		if !ok {
+			continue
+		}
+
+		raw := strings.TrimSpace(val[0])
+		if raw == "" {
+			fe.FieldError = append(fe.FieldError, validate.FieldError{
+				Field: name,
+				Error: fmt.Sprintf("%s cannot be blank", name),
+			})
+			continue
+		}
+
+		if err := f.add(name, fields[name], raw); err != nil {
+			fe.FieldError = append(fe.FieldError, validate.FieldError{
+				Field: name,
+				Error: err.Error(),
+			})
+		}
+	}
+
+	if len(fe.FieldError) > 0 {
+		fe.CustomError = "invalid filter"
+		return Filter{}, fe
+	}
+
+	return f, nil
+}
+
+// add parses a single parameter value and stores its condition.
+func (f *Filter) add(name string, fld FilterField, raw string) error {
+	op := fld.Op
+	if op == "" {
+		op = FilterEqual
+	}
+	key := "f_" + name
+
+	switch fld.Type {
+	case FilterTime:
+		t, err := parseFilterTime(raw)
+		if err != nil {
+			return fmt.Errorf("%s must be an RFC3339 timestamp or a YYYY-MM-DD date", name)
+		}
+		return f.where(fld.Column, op, key, t)
+
+	case FilterString, "":
+		if fld.Prefix && strings.HasSuffix(raw, filterPrefixWildcard) {
+			raw = strings.TrimSuffix(raw, filterPrefixWildcard)
+			if err := checkFilterValue(name, fld, raw); err != nil {
+				return err
+			}
+			f.conds = append(f.conds, fmt.Sprintf("%s LIKE :%s ESCAPE '%s'", fld.Column, key, filterLikeEscape))
+			f.args[key] = escapeLike(raw) + "%"
+			return nil
+		}
+
+		if fld.Multi && op == FilterEqual && strings.Contains(raw, ",") {
+			var keys []string
+			for i, v := range strings.Split(raw, ",") {
+				v = strings.TrimSpace(v)
+				if err := checkFilterValue(name, fld, v); err != nil {
+					return err
+				}
+				k := fmt.Sprintf("%s_%d", key, i)
+				keys = append(keys, ":"+k)
+				f.args[k] = v
+			}
+			f.conds = append(f.conds, fmt.Sprintf("%s IN (%s)", fld.Column, strings.Join(keys, ", ")))
+			return nil
+		}
+
+		if err := checkFilterValue(name, fld, raw); err != nil {
+			return err
+		}
+		return f.where(fld.Column, op, key, raw)
+	}
+
+	return fmt.Errorf("%s has an unsupported filter type %q", name, fld.Type)
+}
+
+// where stores a single comparison condition.
+func (f *Filter) where(column string, op string, key string, val interface{}) error {
+	var cmp string
+	switch op {
+	case FilterEqual:
+		cmp = "="
+	case FilterAfter:
+		cmp = ">="
+	case FilterBefore:
+		cmp = "<"
+	default:
+		return fmt.Errorf("unsupported filter operator %q", op)
+	}
+
+	f.conds = append(f.conds, fmt.Sprintf("%s %s :%s", column, cmp, key))
+	f.args[key] = val
+	return nil
+}
+
+// Data merges the filter arguments with the db tagged fields of a struct,
+// such as Pagination, into the parameters of a named query.
+func (f Filter) Data(data interface{}) map[string]interface{} {
+	m := queryArgs(data)
+	for k, v := range f.args {
+		m[k] = v
+	}
+
+	return m
+}
+
+// Empty reports whether the filter has no conditions.
+func (f Filter) Empty() bool {
+	return len(f.conds) == 0
+}
+
+// FilterQuery replaces the :filters placeholder of the query with the
+// conditions, each of them prefixed with AND. Like PaginationQuery this must
+// run before the named parameters are bound.
+func FilterQuery(f Filter, q string) string {
+	var where string
+	for _, cond := range f.conds {
+		where += "\n\t\tAND " + cond
+	}
+	return strings.ReplaceAll(q, ":filters", where)
+}
+
+// parseFilterTime accepts RFC3339 timestamps and plain dates.
+func parseFilterTime(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
+
+// checkFilterValue runs the optional check of the field.
+func checkFilterValue(name string, fld FilterField, val string) error {
+	if val == "" {
+		return fmt.Errorf("%s cannot be blank", name)
+	}
+	if fld.Check != nil {
+		if err := fld.Check(val); err != nil {
+			return fmt.Errorf("%s is not in its proper form", name)
+		}
+	}
+	return nil
+}
+
+// escapeLike escapes the LIKE wildcards so values are matched literally.
+func escapeLike(s string) string {
+	r := strings.NewReplacer(filterLikeEscape, filterLikeEscape+filterLikeEscape, "%", filterLikeEscape+"%", "_", filterLikeEscape+"_")
+	return r.Replace(s)
+}
@@ -0,0 +1,136 @@
+package database_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+	"github.com/chaitanyamaili/go_rest/pkg/validate"
+)
+
+// filters is a whitelist like the ones of the models.
+var filters = map[string]database.FilterField{
+	"label":          {Column: "b.label", Prefix: true},
+	"status":         {Column: "bs.alias", Multi: true},
+	"id":             {Column: "b.id", Check: validate.CheckID},
+	"created_after":  {Column: "b.created_on", Op: database.FilterAfter, Type: database.FilterTime},
+	"created_before": {Column: "b.created_on", Op: database.FilterBefore, Type: database.FilterTime},
+}
+
+func TestFilterParams(t *testing.T) {
+	day := time.Date(2021, 5, 25, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query string
+		where string
+		args  map[string]interface{}
+	}{
+		{
+			name:  "none",
+			query: "page=2",
+			where: "",
+			args:  map[string]interface{}{},
+		},
+		{
+			name:  "equal",
+			query: "label=build-1&id=3",
+			where: "\n\t\tAND b.id = :f_id\n\t\tAND b.label = :f_label",
+			args:  map[string]interface{}{"f_id": "3", "f_label": "build-1"},
+		},
+		{
+			name:  "prefix escapes wildcards",
+			query: "label=50%25_off*",
+			where: "\n\t\tAND b.label LIKE :f_label ESCAPE '!'",
+			args:  map[string]interface{}{"f_label": "50!%!_off%"},
+		},
+		{
+			name:  "multi",
+			query: "status=failed,success",
+			where: "\n\t\tAND bs.alias IN (:f_status_0, :f_status_1)",
+			args:  map[string]interface{}{"f_status_0": "failed", "f_status_1": "success"},
+		},
+		{
+			name:  "time range",
+			query: "created_after=2021-05-25&created_before=2021-05-26T00:00:00Z",
+			where: "\n\t\tAND b.created_on >= :f_created_after\n\t\tAND b.created_on < :f_created_before",
+			args:  map[string]interface{}{"f_created_after": day, "f_created_before": day.AddDate(0, 0, 1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/build?"+tt.query, nil)
+
+			f, err := database.FilterParams(r, filters)
+			if err != nil {
+				t.Fatalf("got error %s", err)
+			}
+
+			if got := database.FilterQuery(f, "WHERE 1 = 1:filters"); got != "WHERE 1 = 1"+tt.where {
+				t.Errorf("query: got %q, want %q", got, "WHERE 1 = 1"+tt.where)
+			}
+			if f.Empty() != (tt.where == "") {
+				t.Errorf("empty: got %t", f.Empty())
+			}
+			if got := f.Data(struct{}{}); !reflect.DeepEqual(got, tt.args) {
+				t.Errorf("args: got %v, want %v", got, tt.args)
+			}
+		})
+	}
+}
+
+func TestFilterParamsInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		fields []string
+	}{
+		{"blank", "label=", []string{"label"}},
+		{"bad time", "created_after=yesterday", []string{"created_after"}},
+		{"failed check", "id=abc", []string{"id"}},
+		{"blank in list", "status=failed,", []string{"status"}},
+		{"several", "id=abc&created_before=soon", []string{"created_before", "id"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/build?"+tt.query, nil)
+
+			_, err := database.FilterParams(r, filters)
+
+			var fe validate.FieldErrors
+			if !errors.As(err, &fe) {
+				t.Fatalf("got %v, want field errors", err)
+			}
+			var got []string
+			for _, e := range fe.FieldError {
+				got = append(got, e.Field)
+			}
+			if !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("fields: got %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
+
+func TestFilterDataKeepsQueryArgs(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/build?label=build-1", nil)
+	f, err := database.FilterParams(r, filters)
+	if err != nil {
+		t.Fatalf("got error %s", err)
+	}
+
+	data := struct {
+		Limit int `db:"limit"`
+	}{Limit: 20}
+
+	want := map[string]interface{}{"limit": 20, "f_label": "build-1"}
+	if got := f.Data(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
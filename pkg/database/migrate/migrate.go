@@ -0,0 +1,566 @@
+// Package migrate applies versioned sql migrations that follow the Flyway
+// naming convention and records them in the Flyway schema history table, so
+// databases previously migrated by Flyway keep working.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of error variables for migrations.
+var (
+	ErrLockTimeout    = errors.New("timed out waiting for the migration lock")
+	ErrFailed         = errors.New("a previous migration failed and must be repaired manually")
+	ErrChecksum       = errors.New("applied migration checksum does not match its script")
+	ErrNoUndo         = errors.New("no undo migration found")
+	ErrNothingApplied = errors.New("no applied migration to undo")
+)
+
+// Set of migration states reported by Status.
+const (
+	StatePending = "pending"
+	StateSuccess = "success"
+	StateFailed  = "failed"
+	StateUndone  = "undone"
+	StateMissing = "missing"
+)
+
+// Set of migration types stored in the history table.
+const (
+	typeSQL     = "SQL"
+	typeUndoSQL = "UNDO_SQL"
+)
+
+// lockName identifies the migration lock shared by every instance.
+const lockName = "flyway_schema_history_lock"
+
+// lockPoll is how often a held SQLite lock is tried again.
+const lockPoll = 250 * time.Millisecond
+
+// Config is the required properties for the migrator.
+type Config struct {
+	Log         *zap.SugaredLogger
+	DB          *sqlx.DB
+	FS          fs.FS
+	InstalledBy string
+	LockTimeout time.Duration
+}
+
+// Migrator applies and undoes migrations against a database.
+type Migrator struct {
+	log         *zap.SugaredLogger
+	db          *sqlx.DB
+	dialect     string
+	migrations  []Migration
+	installedBy string
+	lockTimeout time.Duration
+}
+
+// Status describes the state of a single migration in the database.
+type Status struct {
+	Version     string     `json:"version"`
+	Description string     `json:"description"`
+	Script      string     `json:"script"`
+	State       string     `json:"state"`
+	InstalledOn *time.Time `json:"installed_on,omitempty"`
+}
+
+// history represents a row of the flyway schema history table.
+type history struct {
+	InstalledRank int            `db:"installed_rank"`
+	Version       sql.NullString `db:"version"`
+	Description   string         `db:"description"`
+	Type          string         `db:"type"`
+	Script        string         `db:"script"`
+	Checksum      sql.NullInt32  `db:"checksum"`
+	InstalledBy   string         `db:"installed_by"`
+	InstalledOn   time.Time      `db:"installed_on"`
+	ExecutionTime int            `db:"execution_time"`
+	Success       bool           `db:"success"`
+}
+
+// New constructs a migrator for the migration scripts found in cfg.FS.
+func New(cfg Config) (*Migrator, error) {
+	migrations, err := load(cfg.FS)
+	if err != nil {
+		return nil, err
+	}
+
+	dialect := database.Dialect(cfg.DB.DriverName())
+	if dialect == "" {
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.DB.DriverName())
+	}
+
+	if cfg.InstalledBy == "" {
+		cfg.InstalledBy = "migrate"
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = time.Minute
+	}
+
+	return &Migrator{
+		log:         cfg.Log,
+		db:          cfg.DB,
+		dialect:     dialect,
+		migrations:  migrations,
+		installedBy: cfg.InstalledBy,
+		lockTimeout: cfg.LockTimeout,
+	}, nil
+}
+
+// -----------------------------------------------------------------------
+// Commands
+// -----------------------------------------------------------------------
+
+// Up applies every pending migration in version order and returns how many
+// were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	var applied int
+
+	err := m.withLock(ctx, func(conn *sqlx.Conn) error {
+		hist, err := m.history(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		states, err := m.validate(hist)
+		if err != nil {
+			return err
+		}
+
+		rank := nextRank(hist)
+		for _, mig := range m.migrations {
+			if states[mig.Version] == StateSuccess {
+				continue
+			}
+
+			m.log.Infow("migrate.up", "version", mig.Version, "script", mig.Script)
+			if err := m.run(ctx, conn, rank, mig.Version, mig.Description, typeSQL, mig.Script, mig.Checksum, mig.sql); err != nil {
+				return fmt.Errorf("migrating to version %s: %w", mig.Version, err)
+			}
+			rank++
+			applied++
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down undoes the latest applied migrations, up to the number of steps,
+// using their U<version>__<name>.sql scripts.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	var undone int
+
+	err := m.withLock(ctx, func(conn *sqlx.Conn) error {
+		hist, err := m.history(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		states, err := m.validate(hist)
+		if err != nil {
+			return err
+		}
+
+		rank := nextRank(hist)
+		for i := len(m.migrations) - 1; i >= 0 && undone < steps; i-- {
+			mig := m.migrations[i]
+			if states[mig.Version] != StateSuccess {
+				continue
+			}
+			if mig.UndoScript == "" {
+				return fmt.Errorf("version %s: %w", mig.Version, ErrNoUndo)
+			}
+
+			m.log.Infow("migrate.down", "version", mig.Version, "script", mig.UndoScript)
+			if err := m.run(ctx, conn, rank, mig.Version, mig.Description, typeUndoSQL, mig.UndoScript, mig.UndoChecksum, mig.undoSQL); err != nil {
+				return fmt.Errorf("undoing version %s: %w", mig.Version, err)
+			}
+			rank++
+			undone++
+		}
+
+		if undone == 0 {
+			return ErrNothingApplied
+		}
+
+		return nil
+	})
+
+	return undone, err
+}
+
+// Status reports the state of every known migration, including the ones
+// recorded in the history table that no longer have a script.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var res []Status
+
+	err := m.withConn(ctx, func(conn *sqlx.Conn) error {
+		if err := m.createHistory(ctx, conn); err != nil {
+			return err
+		}
+		hist, err := m.history(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		latest := latestByVersion(hist)
+		known := make(map[string]bool)
+		for _, mig := range m.migrations {
+			known[mig.Version] = true
+
+			st := Status{
+				Version:     mig.Version,
+				Description: mig.Description,
+				Script:      mig.Script,
+				State:       StatePending,
+			}
+			if h, ok := latest[mig.Version]; ok {
+				st.State = state(h)
+				installedOn := h.InstalledOn
+				st.InstalledOn = &installedOn
+			}
+			res = append(res, st)
+		}
+
+		for _, h := range hist {
+			if !h.Version.Valid || known[h.Version.String] || latest[h.Version.String] != h {
+				continue
+			}
+			installedOn := h.InstalledOn
+			res = append(res, Status{
+				Version:     h.Version.String,
+				Description: h.Description,
+				Script:      h.Script,
+				State:       StateMissing,
+				InstalledOn: &installedOn,
+			})
+		}
+
+		return nil
+	})
+
+	return res, err
+}
+
+// -----------------------------------------------------------------------
+// History
+// -----------------------------------------------------------------------
+
+// createHistory creates the flyway history table if it doesn't exist yet.
+func (m *Migrator) createHistory(ctx context.Context, conn *sqlx.Conn) error {
+	const q = `
+	CREATE TABLE IF NOT EXISTS flyway_schema_history (
+		installed_rank INT NOT NULL PRIMARY KEY,
+		version VARCHAR(50),
+		description VARCHAR(200) NOT NULL,
+		type VARCHAR(20) NOT NULL,
+		script VARCHAR(1000) NOT NULL,
+		checksum INT,
+		installed_by VARCHAR(100) NOT NULL,
+		installed_on TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		execution_time INT NOT NULL,
+		success BOOLEAN NOT NULL
+	)`
+
+	if _, err := conn.ExecContext(ctx, q); err != nil {
+		return fmt.Errorf("creating history table: %w", err)
+	}
+
+	return nil
+}
+
+// history returns every row of the history table in installation order.
+func (m *Migrator) history(ctx context.Context, conn *sqlx.Conn) ([]*history, error) {
+	const q = `
+	SELECT
+		installed_rank,
+		version,
+		description,
+		type,
+		script,
+		checksum,
+		installed_by,
+		installed_on,
+		execution_time,
+		success
+	FROM
+		flyway_schema_history
+	ORDER BY
+		installed_rank`
+
+	var res []*history
+	if err := conn.SelectContext(ctx, &res, q); err != nil {
+		return nil, fmt.Errorf("selecting history: %w", err)
+	}
+
+	return res, nil
+}
+
+// validate makes sure no migration is left in a failed state and that the
+// applied scripts were not changed afterwards. It returns the state of every
+// version found in the history.
+func (m *Migrator) validate(hist []*history) (map[string]string, error) {
+	checksums := make(map[string]int32)
+	for _, mig := range m.migrations {
+		checksums[mig.Version] = mig.Checksum
+	}
+
+	states := make(map[string]string)
+	for version, h := range latestByVersion(hist) {
+		st := state(h)
+		states[version] = st
+
+		if st == StateFailed {
+			return nil, fmt.Errorf("version %s: %w", version, ErrFailed)
+		}
+		sum, ok := checksums[version]
+		if st == StateSuccess && ok && h.Checksum.Valid && h.Checksum.Int32 != sum {
+			return nil, fmt.Errorf("version %s: %w", version, ErrChecksum)
+		}
+	}
+
+	return states, nil
+}
+
+// run executes a script and records it in the history table. Dialects with
+// transactional DDL run both in a single transaction. MySQL commits DDL
+// implicitly, so a failure is recorded the same way Flyway does.
+func (m *Migrator) run(ctx context.Context, conn *sqlx.Conn, rank int, version, description, typ, script string, sum int32, body string) error {
+	start := time.Now()
+
+	h := history{
+		InstalledRank: rank,
+		Version:       sql.NullString{String: version, Valid: true},
+		Description:   description,
+		Type:          typ,
+		Script:        script,
+		Checksum:      sql.NullInt32{Int32: sum, Valid: true},
+		InstalledBy:   m.installedBy,
+		Success:       true,
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		for _, stmt := range splitStatements(body) {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("exec %q: %w", stmt, err)
+			}
+		}
+
+		h.InstalledOn = time.Now().UTC()
+		h.ExecutionTime = int(time.Since(start).Milliseconds())
+		return m.record(ctx, tx, h)
+	}
+
+	// The transactions run on the connection holding the migration lock.
+	err := database.WithinTran(ctx, m.log, conn, tran)
+	if err != nil && m.dialect == database.DialectMySQL {
+		h.Success = false
+		h.InstalledOn = time.Now().UTC()
+		h.ExecutionTime = int(time.Since(start).Milliseconds())
+		fail := func(tx sqlx.ExtContext) error {
+			return m.record(ctx, tx, h)
+		}
+		if ferr := database.WithinTran(ctx, m.log, conn, fail); ferr != nil {
+			m.log.Errorw("migrate.run", "status", "unable to record failed migration", "version", version, "ERROR", ferr)
+		}
+	}
+
+	return err
+}
+
+// record inserts a row into the history table.
+func (m *Migrator) record(ctx context.Context, db sqlx.ExtContext, h history) error {
+	const q = `
+	INSERT INTO flyway_schema_history
+		(installed_rank, version, description, type, script, checksum, installed_by, installed_on, execution_time, success)
+	VALUES
+		(:installed_rank, :version, :description, :type, :script, :checksum, :installed_by, :installed_on, :execution_time, :success)`
+
+	if _, err := database.NamedExecContext(ctx, m.log, db, q, h); err != nil {
+		return fmt.Errorf("recording history: %w", err)
+	}
+
+	return nil
+}
+
+// latestByVersion returns the most recent history row of every version.
+func latestByVersion(hist []*history) map[string]*history {
+	latest := make(map[string]*history)
+	for _, h := range hist {
+		if h.Version.Valid {
+			latest[h.Version.String] = h
+		}
+	}
+	return latest
+}
+
+// state derives the migration state from its latest history row.
+func state(h *history) string {
+	switch {
+	case !h.Success:
+		return StateFailed
+	case h.Type == typeUndoSQL:
+		return StateUndone
+	default:
+		return StateSuccess
+	}
+}
+
+// nextRank returns the installed rank of the next history row.
+func nextRank(hist []*history) int {
+	if len(hist) == 0 {
+		return 1
+	}
+	return hist[len(hist)-1].InstalledRank + 1
+}
+
+// -----------------------------------------------------------------------
+// Locking
+// -----------------------------------------------------------------------
+
+// withConn runs fn on a single connection from the pool. Session level
+// locks only hold for the connection that acquired them.
+func (m *Migrator) withConn(ctx context.Context, fn func(conn *sqlx.Conn) error) error {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring connection: %w", err)
+	}
+	defer conn.Close() //nolint:all
+
+	return fn(conn)
+}
+
+// withLock runs fn while holding the migration lock, so several instances
+// starting together apply the migrations only once.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sqlx.Conn) error) error {
+	return m.withConn(ctx, func(conn *sqlx.Conn) error {
+		unlock, err := m.lock(ctx, conn)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		if err := m.createHistory(ctx, conn); err != nil {
+			return err
+		}
+
+		return fn(conn)
+	})
+}
+
+// lock acquires the dialect specific advisory lock. SQLite has no advisory
+// locks, so the lock is a row of a lock table that only one instance can
+// insert.
+func (m *Migrator) lock(ctx context.Context, conn *sqlx.Conn) (func(), error) {
+	m.log.Infow("migrate.lock", "status", "waiting for lock", "dialect", m.dialect)
+
+	switch m.dialect {
+	case database.DialectMySQL:
+		var ok sql.NullInt64
+		q := `SELECT GET_LOCK(?, ?)`
+		if err := conn.QueryRowxContext(ctx, q, lockName, int(m.lockTimeout.Seconds())).Scan(&ok); err != nil {
+			return nil, fmt.Errorf("acquiring lock: %w", err)
+		}
+		if !ok.Valid || ok.Int64 != 1 {
+			return nil, ErrLockTimeout
+		}
+
+		return func() {
+			var released sql.NullInt64
+			_ = conn.QueryRowxContext(context.Background(), `SELECT RELEASE_LOCK(?)`, lockName).Scan(&released)
+		}, nil
+
+	case database.DialectPostgres:
+		lctx, cancel := context.WithTimeout(ctx, m.lockTimeout)
+		defer cancel()
+
+		key := lockKey()
+		if _, err := conn.ExecContext(lctx, `SELECT pg_advisory_lock($1)`, key); err != nil {
+			if lctx.Err() != nil {
+				return nil, ErrLockTimeout
+			}
+			return nil, fmt.Errorf("acquiring lock: %w", err)
+		}
+
+		return func() {
+			_, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, key)
+		}, nil
+
+	case database.DialectSQLite:
+		return m.lockRow(ctx, conn)
+	}
+
+	return nil, fmt.Errorf("no migration lock for dialect %q", m.dialect)
+}
+
+// lockRow acquires the SQLite lock by inserting the single row of the lock
+// table, waiting for the instance holding it to delete it. The row outlives
+// an instance that is killed while migrating, the error names it so the row
+// can be deleted once that instance is known to be gone.
+func (m *Migrator) lockRow(ctx context.Context, conn *sqlx.Conn) (func(), error) {
+	const create = `
+	CREATE TABLE IF NOT EXISTS ` + lockName + ` (
+		id INT NOT NULL PRIMARY KEY,
+		locked_by VARCHAR(100) NOT NULL,
+		locked_on TIMESTAMP NOT NULL
+	)`
+
+	if _, err := conn.ExecContext(ctx, create); err != nil {
+		return nil, fmt.Errorf("creating lock table: %w", err)
+	}
+
+	const q = `INSERT INTO ` + lockName + ` (id, locked_by, locked_on) VALUES (1, ?, ?)`
+
+	deadline := time.Now().Add(m.lockTimeout)
+	for {
+		_, err := conn.ExecContext(ctx, q, m.installedBy, time.Now().UTC())
+		if err == nil {
+			break
+		}
+		if !database.IsDuplicateEntry(err) {
+			return nil, fmt.Errorf("acquiring lock: %w", err)
+		}
+
+		if time.Now().After(deadline) {
+			var holder struct {
+				LockedBy string    `db:"locked_by"`
+				LockedOn time.Time `db:"locked_on"`
+			}
+			if err := conn.GetContext(ctx, &holder, `SELECT locked_by, locked_on FROM `+lockName+` WHERE id = 1`); err != nil {
+				return nil, ErrLockTimeout
+			}
+			return nil, fmt.Errorf("%w: held by %s since %s, delete the row of %s if it is gone",
+				ErrLockTimeout, holder.LockedBy, holder.LockedOn.Format(time.RFC3339), lockName)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPoll):
+		}
+	}
+
+	return func() {
+		_, _ = conn.ExecContext(context.Background(), `DELETE FROM `+lockName+` WHERE id = 1`)
+	}, nil
+}
+
+// lockKey derives the postgres advisory lock key from the lock name.
+func lockKey() int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(lockName))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,199 @@
+package migrate_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+	"github.com/chaitanyamaili/go_rest/pkg/database/migrate"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite"
+)
+
+// scripts is a small set of migrations with their undo scripts.
+func scripts() fstest.MapFS {
+	return fstest.MapFS{
+		"V1__create_build.sql": {Data: []byte(`
+			CREATE TABLE build (id integer primary key, label varchar(255) not null);
+			INSERT INTO build (label) VALUES ('a;b');`)},
+		"U1__create_build.sql": {Data: []byte(`DROP TABLE build;`)},
+		"V2__add_sha.sql":      {Data: []byte(`ALTER TABLE build ADD COLUMN commit_sha varchar(40) not null default '';`)},
+		"U2__add_sha.sql":      {Data: []byte(`ALTER TABLE build DROP COLUMN commit_sha;`)},
+	}
+}
+
+// open returns a SQLite database of the test's own.
+func open(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	db, err := database.Open(database.Config{
+		Type: database.DialectSQLite,
+		Name: filepath.Join(t.TempDir(), "migrate.db"),
+	})
+	if err != nil {
+		t.Fatalf("opening database: %s", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+func newMigrator(t *testing.T, db *sqlx.DB, fsys fstest.MapFS, lockTimeout time.Duration) *migrate.Migrator {
+	t.Helper()
+
+	m, err := migrate.New(migrate.Config{
+		Log:         zap.NewNop().Sugar(),
+		DB:          db,
+		FS:          fsys,
+		InstalledBy: "test",
+		LockTimeout: lockTimeout,
+	})
+	if err != nil {
+		t.Fatalf("constructing migrator: %s", err)
+	}
+
+	return m
+}
+
+// states maps every version to its state.
+func states(t *testing.T, m *migrate.Migrator) map[string]string {
+	t.Helper()
+
+	sts, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("status: %s", err)
+	}
+
+	res := make(map[string]string)
+	for _, st := range sts {
+		res[st.Version] = st.State
+	}
+	return res
+}
+
+func TestUpDown(t *testing.T) {
+	ctx := context.Background()
+	db := open(t)
+	m := newMigrator(t, db, scripts(), time.Second)
+
+	if got := states(t, m); got["1"] != migrate.StatePending || got["2"] != migrate.StatePending {
+		t.Fatalf("before up: got %v, want every version pending", got)
+	}
+
+	n, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("up: %s", err)
+	}
+	if n != 2 {
+		t.Errorf("up: got %d applied, want 2", n)
+	}
+
+	var label string
+	if err := db.Get(&label, `SELECT label FROM build`); err != nil {
+		t.Fatalf("reading seeded build: %s", err)
+	}
+	if label != "a;b" {
+		t.Errorf("seeded label: got %q, want %q", label, "a;b")
+	}
+
+	if n, err := m.Up(ctx); err != nil || n != 0 {
+		t.Errorf("up again: got %d applied and error %v, want 0 and none", n, err)
+	}
+
+	if n, err := m.Down(ctx, 1); err != nil || n != 1 {
+		t.Fatalf("down: got %d undone and error %v, want 1 and none", n, err)
+	}
+	if got := states(t, m); got["1"] != migrate.StateSuccess || got["2"] != migrate.StateUndone {
+		t.Errorf("after down: got %v, want 1 success and 2 undone", got)
+	}
+
+	if n, err := m.Up(ctx); err != nil || n != 1 {
+		t.Errorf("up after down: got %d applied and error %v, want 1 and none", n, err)
+	}
+	if got := states(t, m); got["2"] != migrate.StateSuccess {
+		t.Errorf("after up: got %v, want 2 success", got)
+	}
+}
+
+func TestDownNothingApplied(t *testing.T) {
+	m := newMigrator(t, open(t), scripts(), time.Second)
+
+	if _, err := m.Down(context.Background(), 1); !errors.Is(err, migrate.ErrNothingApplied) {
+		t.Errorf("got %v, want %v", err, migrate.ErrNothingApplied)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	ctx := context.Background()
+	db := open(t)
+
+	if _, err := newMigrator(t, db, scripts(), time.Second).Up(ctx); err != nil {
+		t.Fatalf("up: %s", err)
+	}
+
+	changed := scripts()
+	changed["V2__add_sha.sql"] = &fstest.MapFile{Data: []byte(`ALTER TABLE build ADD COLUMN commit_sha varchar(64) not null default '';`)}
+
+	if _, err := newMigrator(t, db, changed, time.Second).Up(ctx); !errors.Is(err, migrate.ErrChecksum) {
+		t.Errorf("got %v, want %v", err, migrate.ErrChecksum)
+	}
+}
+
+func TestFailed(t *testing.T) {
+	ctx := context.Background()
+	db := open(t)
+
+	broken := scripts()
+	broken["V3__broken.sql"] = &fstest.MapFile{Data: []byte(`ALTER TABLE missing ADD COLUMN x int;`)}
+
+	m := newMigrator(t, db, broken, time.Second)
+	if n, err := m.Up(ctx); err == nil || n != 2 {
+		t.Fatalf("got %d applied and error %v, want 2 and an error", n, err)
+	}
+
+	// The failed script ran in a transaction, nothing of it was kept.
+	if got := states(t, m); got["3"] != migrate.StatePending {
+		t.Errorf("got %v, want 3 pending", got)
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	db := open(t)
+	m := newMigrator(t, db, scripts(), 300*time.Millisecond)
+
+	// Another instance holds the lock.
+	if _, err := m.Status(context.Background()); err != nil {
+		t.Fatalf("status: %s", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE flyway_schema_history_lock (id INT NOT NULL PRIMARY KEY, locked_by VARCHAR(100) NOT NULL, locked_on TIMESTAMP NOT NULL)`); err != nil {
+		t.Fatalf("creating lock table: %s", err)
+	}
+	if _, err := db.Exec(`INSERT INTO flyway_schema_history_lock (id, locked_by, locked_on) VALUES (1, 'other', ?)`, time.Now().UTC()); err != nil {
+		t.Fatalf("taking the lock: %s", err)
+	}
+
+	if _, err := m.Up(context.Background()); !errors.Is(err, migrate.ErrLockTimeout) {
+		t.Fatalf("got %v, want %v", err, migrate.ErrLockTimeout)
+	}
+
+	// Once released the lock is acquired and released again.
+	if _, err := db.Exec(`DELETE FROM flyway_schema_history_lock`); err != nil {
+		t.Fatalf("releasing the lock: %s", err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("up: %s", err)
+	}
+
+	var held int
+	if err := db.Get(&held, `SELECT count(*) FROM flyway_schema_history_lock`); err != nil {
+		t.Fatalf("reading the lock: %s", err)
+	}
+	if held != 0 {
+		t.Errorf("lock rows after up: got %d, want 0", held)
+	}
+}
@@ -0,0 +1,251 @@
+package migrate
+
+import (
+	"bufio"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// scriptPattern matches the Flyway naming convention for versioned (V) and
+// undo (U) migrations, e.g. V1__initial_setup.sql or U1_1__add_index.sql.
+var scriptPattern = regexp.MustCompile(`^([VU])(\d+(?:[._]\d+)*)__(.+)\.sql$`)
+
+// Migration is a single versioned script and its optional undo script.
+type Migration struct {
+	Version      string
+	Description  string
+	Script       string
+	Checksum     int32
+	UndoScript   string
+	UndoChecksum int32
+
+	parts   []int
+	sql     string
+	undoSQL string
+}
+
+// load reads every migration script from the root of fsys and returns them
+// sorted by version.
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations: %w", err)
+	}
+
+	byVersion := make(map[string]*Migration)
+	undos := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		matches := scriptPattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("invalid migration name %q", entry.Name())
+		}
+		version := strings.ReplaceAll(matches[2], "_", ".")
+
+		if matches[1] == "U" {
+			undos[version] = entry.Name()
+			continue
+		}
+		if _, ok := byVersion[version]; ok {
+			return nil, fmt.Errorf("duplicated migration version %s", version)
+		}
+
+		b, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("reading migration %q: %w", entry.Name(), err)
+		}
+
+		parts, err := versionParts(version)
+		if err != nil {
+			return nil, fmt.Errorf("migration %q: %w", entry.Name(), err)
+		}
+
+		byVersion[version] = &Migration{
+			Version:     version,
+			Description: strings.ReplaceAll(matches[3], "_", " "),
+			Script:      entry.Name(),
+			Checksum:    checksum(string(b)),
+			parts:       parts,
+			sql:         string(b),
+		}
+	}
+
+	for version, name := range undos {
+		mig, ok := byVersion[version]
+		if !ok {
+			return nil, fmt.Errorf("undo migration %q has no matching versioned migration", name)
+		}
+
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("reading migration %q: %w", name, err)
+		}
+		mig.UndoScript = name
+		mig.UndoChecksum = checksum(string(b))
+		mig.undoSQL = string(b)
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return compareVersions(migrations[i].parts, migrations[j].parts) < 0
+	})
+
+	return migrations, nil
+}
+
+// versionParts splits a dotted version into its numeric parts.
+func versionParts(version string) ([]int, error) {
+	fields := strings.Split(version, ".")
+	parts := make([]int, len(fields))
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q", version)
+		}
+		parts[i] = n
+	}
+	return parts, nil
+}
+
+// compareVersions orders versions numerically so 1.10 comes after 1.9.
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// checksum calculates the same CRC32 Flyway stores in its history table:
+// every line without its terminator, with the BOM of the first line removed.
+func checksum(script string) int32 {
+	crc := crc32.NewIEEE()
+
+	scanner := bufio.NewScanner(strings.NewReader(script))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(script)+1)
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			first = false
+		}
+		_, _ = crc.Write([]byte(line))
+	}
+
+	return int32(crc.Sum32())
+}
+
+// splitStatements breaks a script into single statements on the semicolons
+// that are not part of a string, an identifier, a comment or a dollar
+// quoted block. Drivers are not required to support multiple statements per
+// call so every statement is executed on its own.
+func splitStatements(script string) []string {
+	var (
+		stmts []string
+		buf   strings.Builder
+	)
+
+	flush := func() {
+		if stmt := strings.TrimSpace(buf.String()); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		buf.Reset()
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+
+		switch {
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				i = len(script)
+				continue
+			}
+			i += end
+			buf.WriteByte('\n')
+			continue
+
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+				continue
+			}
+			i += end + 3
+			continue
+
+		case c == '\'' || c == '"' || c == '`':
+			end := i + 1
+			for end < len(script) {
+				if script[end] == '\\' && c != '`' {
+					end += 2
+					continue
+				}
+				if script[end] == c {
+					break
+				}
+				end++
+			}
+			if end >= len(script) {
+				end = len(script) - 1
+			}
+			buf.WriteString(script[i : end+1])
+			i = end
+			continue
+
+		case c == '$':
+			if tag := dollarTag(script[i:]); tag != "" {
+				end := strings.Index(script[i+len(tag):], tag)
+				if end < 0 {
+					buf.WriteString(script[i:])
+					i = len(script)
+					continue
+				}
+				end += i + 2*len(tag)
+				buf.WriteString(script[i:end])
+				i = end - 1
+				continue
+			}
+
+		case c == ';':
+			flush()
+			continue
+		}
+
+		buf.WriteByte(c)
+	}
+	flush()
+
+	return stmts
+}
+
+// dollarTagPattern matches the opening tag of a postgres dollar quoted string.
+var dollarTagPattern = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+
+func dollarTag(s string) string {
+	return dollarTagPattern.FindString(s)
+}
@@ -0,0 +1,100 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "statements",
+			script: "CREATE TABLE a (id int);\nCREATE TABLE b (id int);\n",
+			want:   []string{"CREATE TABLE a (id int)", "CREATE TABLE b (id int)"},
+		},
+		{
+			name:   "quoted semicolons",
+			script: `INSERT INTO a VALUES ('x;y', "z;w", ` + "`q;r`" + `); SELECT 1`,
+			want:   []string{`INSERT INTO a VALUES ('x;y', "z;w", ` + "`q;r`" + `)`, "SELECT 1"},
+		},
+		{
+			name:   "comments",
+			script: "-- drop; everything\nSELECT 1; /* a; b */ SELECT 2;",
+			want:   []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			name:   "dollar quoted",
+			script: "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql; SELECT 2",
+			want:   []string{"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql", "SELECT 2"},
+		},
+		{
+			name:   "empty",
+			script: " ;\n; ",
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"V1_10__later.sql":  {Data: []byte("SELECT 3;")},
+		"V1_9__earlier.sql": {Data: []byte("SELECT 2;")},
+		"V1__first.sql":     {Data: []byte("SELECT 1;")},
+		"U1__first.sql":     {Data: []byte("SELECT 0;")},
+	}
+
+	migs, err := load(fsys)
+	if err != nil {
+		t.Fatalf("load: %s", err)
+	}
+
+	var versions []string
+	for _, mig := range migs {
+		versions = append(versions, mig.Version)
+	}
+	if want := []string{"1", "1.9", "1.10"}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("versions: got %v, want %v", versions, want)
+	}
+	if migs[0].UndoScript != "U1__first.sql" || migs[0].Description != "first" {
+		t.Errorf("first migration: got undo %q and description %q", migs[0].UndoScript, migs[0].Description)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"bad name":           {"create.sql": {Data: []byte("SELECT 1;")}},
+		"orphan undo":        {"U2__gone.sql": {Data: []byte("SELECT 1;")}},
+		"duplicated version": {"V1__a.sql": {Data: []byte("SELECT 1;")}, "V1__b.sql": {Data: []byte("SELECT 1;")}},
+	}
+
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := load(fsys); err == nil {
+				t.Error("got no error, want one")
+			}
+		})
+	}
+}
+
+func TestChecksumIgnoresLineEndings(t *testing.T) {
+	unix := checksum("SELECT 1;\nSELECT 2;\n")
+	if got := checksum("\ufeffSELECT 1;\r\nSELECT 2;\r\n"); got != unix {
+		t.Errorf("got %d, want %d", got, unix)
+	}
+	if got := checksum("SELECT 1;\nSELECT 3;\n"); got == unix {
+		t.Error("changed script has the same checksum")
+	}
+}
@@ -0,0 +1,302 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of ways the total of a listing can be requested.
+const (
+	TotalExact    = "exact"
+	TotalEstimate = "estimate"
+)
+
+// Page holds the metadata of a page of results.
+type Page struct {
+	// The current page, omitted for cursor pages
+	//
+	// example: 1
+	Page int `json:"page,omitempty"`
+	// The per page limit
+	//
+	// example: 20
+	PerPage int `json:"per_page"`
+	// Number of records matching the filters, only set when requested
+	//
+	// example: 42
+	Total *int64 `json:"total,omitempty"`
+	// Set when the total is estimated from the table statistics
+	//
+	// example: false
+	Estimated bool `json:"estimated,omitempty"`
+	// Whether there is a page after this one
+	//
+	// example: true
+	HasMore bool `json:"has_more"`
+	// Cursor of the page after this one, empty on the last page
+	//
+	// example: eyJzIjoiY3JlYXRlZF9vbiIsImQiOiJkZXNjIiwiaSI6MjB9
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Cursor of the page before this one, empty on the first page
+	//
+	// example: eyJzIjoiY3JlYXRlZF9vbiIsImQiOiJkZXNjIiwiaSI6MjEsImIiOnRydWV9
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// PageQuery holds the queries of a paginated listing.
+type PageQuery struct {
+	// Query selects the rows of the page, limited to :per_page rows.
+	Query string
+	// Count counts every row the query pages through as total.
+	Count string
+	// Table is the table the total is estimated from.
+	Table string
+	// Filtered is set when the rows are filtered, the statistics of the
+	// table can't estimate the total then and it is counted instead.
+	Filtered bool
+}
+
+// NamedQueryPage is a helper function for executing the queries of a page
+// of results into a slice of rows implementing Keyed. One more row than the
+// page size is selected to tell whether there is another page. When a total
+// is requested the count query runs in the same read only transaction.
+func NamedQueryPage(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, pq PageQuery, pagi Pagination, data interface{}, dest interface{}) (Page, error) {
+	args := queryArgs(data)
+	args["per_page"] = pagi.PerPage + 1
+
+	var total *int64
+	var estimated bool
+	run := func(ext sqlx.ExtContext) error {
+		if err := NamedQuerySlice(ctx, log, ext, pq.Query, args, dest); err != nil {
+			return err
+		}
+		if pagi.Total == "" {
+			return nil
+		}
+
+		n, est, err := queryTotal(ctx, log, ext, pq, pagi, args)
+		if err != nil {
+			return fmt.Errorf("total: %w", err)
+		}
+		total, estimated = &n, est
+		return nil
+	}
+
+	beginner, ok := db.(interface {
+		BeginTxx(context.Context, *sql.TxOptions) (*sqlx.Tx, error)
+	})
+	if pagi.Total == "" || !ok {
+		if err := run(db); err != nil {
+			return Page{}, err
+		}
+	} else {
+		tx, err := beginner.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			return Page{}, fmt.Errorf("begin db transaction: %w", err)
+		}
+		if err := run(tx); err != nil {
+			_ = tx.Rollback()
+			return Page{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return Page{}, fmt.Errorf("commit db transaction: %w", err)
+		}
+	}
+
+	// Drop the extra row and put back in order the rows of a backward cursor.
+	slice := reflect.ValueOf(dest).Elem()
+	extra := slice.Len() > pagi.PerPage
+	if extra {
+		slice.Set(slice.Slice(0, pagi.PerPage))
+	}
+	if pagi.Backward {
+		reverseSlice(slice)
+	}
+
+	page := Page{
+		PerPage:   pagi.PerPage,
+		Total:     total,
+		Estimated: estimated,
+		HasMore:   extra || pagi.Backward,
+	}
+	if pagi.Cursor == "" {
+		page.Page = pagi.Page/pagi.PerPage + 1
+	}
+
+	if slice.Len() == 0 {
+		return page, nil
+	}
+
+	hasPrev := pagi.Cursor != "" || pagi.Page > 0
+	if pagi.Backward {
+		hasPrev = extra
+	}
+
+	if page.HasMore {
+		row, ok := slice.Index(slice.Len() - 1).Interface().(Keyed)
+		if !ok {
+			return Page{}, errors.New("rows must implement Keyed")
+		}
+		c, err := encodeCursor(pagi, row, false)
+		if err != nil {
+			return Page{}, err
+		}
+		page.NextCursor = c
+	}
+
+	if hasPrev {
+		row, ok := slice.Index(0).Interface().(Keyed)
+		if !ok {
+			return Page{}, errors.New("rows must implement Keyed")
+		}
+		c, err := encodeCursor(pagi, row, true)
+		if err != nil {
+			return Page{}, err
+		}
+		page.PrevCursor = c
+	}
+
+	return page, nil
+}
+
+// queryTotal counts the rows of the listing, or estimates them from the
+// statistics the database keeps about the table.
+func queryTotal(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, pq PageQuery, pagi Pagination, args map[string]interface{}) (int64, bool, error) {
+	var res struct {
+		Total int64 `db:"total"`
+	}
+
+	if pagi.Total == TotalEstimate && !pq.Filtered && pq.Table != "" {
+		var q string
+		switch DialectOf(db) {
+		case DialectPostgres:
+			q = `SELECT CAST(reltuples AS bigint) AS total FROM pg_class WHERE relname = :table`
+		case DialectMySQL:
+			q = `SELECT COALESCE(table_rows, 0) AS total FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = :table`
+		}
+
+		// Tables that were never analyzed have no estimate, count them.
+		if q != "" {
+			err := NamedQueryStruct(ctx, log, db, q, map[string]interface{}{"table": pq.Table}, &res)
+			if err != nil && !errors.Is(err, ErrDBNotFound) {
+				return 0, false, err
+			}
+			if err == nil && res.Total >= 0 {
+				return res.Total, true, nil
+			}
+		}
+	}
+
+	if err := NamedQueryStruct(ctx, log, db, pq.Count, args, &res); err != nil {
+		return 0, false, err
+	}
+
+	return res.Total, false, nil
+}
+
+// PaginationLinks returns the RFC 8288 Link header value of a page, with the
+// first, prev, next and last relations. The last page is only known when the
+// total was requested.
+func PaginationLinks(r *http.Request, pagi Pagination, page Page) string {
+	link := func(rel string, set func(q url.Values)) string {
+		q := r.URL.Query()
+		delete(q, "cursor")
+		delete(q, "page")
+		// The cursor pins the order, keep it for the page links.
+		q.Set("sort", strings.TrimSuffix(pagi.Sort, "_on"))
+		q.Set("direction", pagi.Direction)
+		q.Set("per_page", strconv.Itoa(pagi.PerPage))
+		set(q)
+
+		u := *r.URL
+		u.RawQuery = q.Encode()
+		return fmt.Sprintf("<%s>; rel=\"%s\"", u.RequestURI(), rel)
+	}
+	setPage := func(n int) func(q url.Values) {
+		return func(q url.Values) { q.Set("page", strconv.Itoa(n)) }
+	}
+	setCursor := func(c string) func(q url.Values) {
+		return func(q url.Values) { q.Set("cursor", c) }
+	}
+
+	links := []string{link("first", setPage(1))}
+
+	switch {
+	case pagi.Cursor != "" && page.PrevCursor != "":
+		links = append(links, link("prev", setCursor(page.PrevCursor)))
+	case pagi.Cursor == "" && page.Page > 1:
+		links = append(links, link("prev", setPage(page.Page-1)))
+	}
+
+	switch {
+	case pagi.Cursor != "" && page.NextCursor != "":
+		links = append(links, link("next", setCursor(page.NextCursor)))
+	case pagi.Cursor == "" && page.HasMore:
+		links = append(links, link("next", setPage(page.Page+1)))
+	}
+
+	if page.Total != nil {
+		last := int((*page.Total + int64(pagi.PerPage) - 1) / int64(pagi.PerPage))
+		if last < 1 {
+			last = 1
+		}
+		links = append(links, link("last", setPage(last)))
+	}
+
+	return strings.Join(links, ", ")
+}
+
+// RespondPage returns a page of results to the client, with its metadata in
+// the meta block and its links in the Link header.
+func RespondPage(ctx context.Context, w http.ResponseWriter, r *http.Request, pagi Pagination, data interface{}, page Page) error {
+	w.Header().Set("Link", PaginationLinks(r, pagi, page))
+	return api.RespondWithMeta(ctx, w, data, page, http.StatusOK)
+}
+
+// queryArgs returns the named parameters of a query as a map, the db tagged
+// fields of a struct are used as keys.
+func queryArgs(data interface{}) map[string]interface{} {
+	if m, ok := data.(map[string]interface{}); ok {
+		args := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			args[k] = v
+		}
+		return args
+	}
+
+	args := make(map[string]interface{})
+	val := reflect.Indirect(reflect.ValueOf(data))
+	if val.Kind() != reflect.Struct {
+		return args
+	}
+
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		tag := strings.Split(typ.Field(i).Tag.Get("db"), ",")[0]
+		if tag == "" || tag == "-" || !typ.Field(i).IsExported() {
+			continue
+		}
+		args[tag] = val.Field(i).Interface()
+	}
+
+	return args
+}
+
+// reverseSlice reverses the order of the elements of a slice value.
+func reverseSlice(slice reflect.Value) {
+	swap := reflect.Swapper(slice.Interface())
+	for i, j := 0, slice.Len()-1; i < j; i, j = i+1, j-1 {
+		swap(i, j)
+	}
+}
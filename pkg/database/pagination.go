@@ -6,12 +6,13 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/chaitanyamaili/go_rest/pkg/api"
 )
 
 // Pagination details
-// swagger:parameters StatusQuery RequestingTechQuery RequestingSourceQuery VCSQuery
+// swagger:parameters StatusQuery RequestingTechQuery RequestingSourceQuery VCSQuery BuildQuery BuildStatusQuery
 type Pagination struct {
 	// The current page
 	//
@@ -52,6 +53,34 @@ type Pagination struct {
 	//   - `asc` - Ascending, from A to Z
 	//   - `desc` - Descending, from Z to A
 	Direction string `db:"direction" json:"direction"`
+	// An opaque cursor taken from the next_cursor or prev_cursor of a
+	// previous page. It is used instead of page and keeps the sort and the
+	// direction of the page it was issued for.
+	//
+	// in: query
+	// required: false
+	// type: string
+	Cursor string `db:"-" json:"cursor"`
+	// Include the total number of matching records in the meta block. The
+	// estimate is read from the table statistics when nothing is filtered.
+	//
+	// in: query
+	// required: false
+	// type: string
+	// enum: exact,estimate
+	Total string `db:"-" json:"total"`
+	// Backward is set when the cursor walks to the previous page.
+	//
+	// swagger:ignore
+	Backward bool `db:"-" json:"-"`
+	// KeysetValue is the sort value of the row the cursor points at.
+	//
+	// swagger:ignore
+	KeysetValue time.Time `db:"keyset_value" json:"-"`
+	// KeysetID is the id of the row the cursor points at.
+	//
+	// swagger:ignore
+	KeysetID int64 `db:"keyset_id" json:"-"`
 }
 
 // PaginationResults Pagination details
@@ -120,7 +149,7 @@ func PaginationParams(r *http.Request) (Pagination, error) {
 		if err != nil {
 			return Pagination{}, api.NewRequestError(fmt.Errorf("invalid perPage format: %s", val[0]), http.StatusBadRequest)
 		}
-		if perPage < 0 {
+		if perPage < 1 {
 			perPage = 20
 		}
 		if perPage > 100 {
@@ -162,6 +191,32 @@ func PaginationParams(r *http.Request) (Pagination, error) {
 		}
 	}
 
+	if val, ok := qparams["total"]; ok {
+		switch total := strings.ToLower(strings.TrimSpace(val[0])); total {
+		case TotalExact, TotalEstimate:
+			pagi.Total = total
+		case "true", "1":
+			pagi.Total = TotalExact
+		default:
+			return Pagination{}, api.NewRequestError(fmt.Errorf("invalid total format: %s", val[0]), http.StatusBadRequest)
+		}
+	}
+
+	if val, ok := qparams["cursor"]; ok {
+		c, err := decodeCursor(val[0])
+		if err != nil {
+			return Pagination{}, api.NewRequestError(fmt.Errorf("invalid cursor: %s", val[0]), http.StatusBadRequest)
+		}
+		// The cursor replaces the offset and pins the order it was issued for.
+		pagi.Page = 0
+		pagi.Sort = c.Sort
+		pagi.Direction = c.Direction
+		pagi.Cursor = val[0]
+		pagi.Backward = c.Backward
+		pagi.KeysetValue = c.Value
+		pagi.KeysetID = c.ID
+	}
+
 	return pagi, nil
 }
 
@@ -172,8 +227,52 @@ func PaginationParams(r *http.Request) (Pagination, error) {
 // data values should appear, not for SQL keywords, identifiers etc. You
 // cannot use it to dynamically specify the ORDER BY OR GROUP BY values.
 // https://stackoverflow.com/questions/30867337/golang-order-by-issue-with-mysql
+//
+// When paging backward with a cursor the direction is reversed, the rows
+// are put back in order by NamedQueryPage.
 func PaginationQuery(pagi Pagination, q string) string {
+	direction := pagi.Direction
+	if pagi.Backward {
+		direction = reverseDirection(direction)
+	}
+
 	q = strings.ReplaceAll(q, ":sort", pagi.Sort)
-	q = strings.ReplaceAll(q, ":direction", pagi.Direction)
+	q = strings.ReplaceAll(q, ":direction", direction)
 	return q
 }
+
+// KeysetQuery replaces the :keyset placeholder of the query with the
+// condition that starts the page after the row of the cursor. The alias is
+// the table alias of the sort columns, it can be empty. It must run before
+// PaginationQuery as the condition refers to the :sort column.
+func KeysetQuery(pagi Pagination, alias string, q string) string {
+	if pagi.Cursor == "" {
+		return strings.ReplaceAll(q, ":keyset", "")
+	}
+
+	if alias != "" {
+		alias += "."
+	}
+
+	cmp := "<"
+	if pagi.Direction == "asc" {
+		cmp = ">"
+	}
+	if pagi.Backward {
+		cmp = map[string]string{"<": ">", ">": "<"}[cmp]
+	}
+
+	cond := fmt.Sprintf("%sid %s :keyset_id", alias, cmp)
+	if pagi.Sort != "id" {
+		cond = fmt.Sprintf("(%[1]s:sort %[2]s :keyset_value OR (%[1]s:sort = :keyset_value AND %[1]sid %[2]s :keyset_id))", alias, cmp)
+	}
+
+	return strings.ReplaceAll(q, ":keyset", "\n\t\tAND "+cond)
+}
+
+func reverseDirection(direction string) string {
+	if direction == "asc" {
+		return "desc"
+	}
+	return "asc"
+}
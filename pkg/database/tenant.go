@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"strings"
+
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+)
+
+// Tenant is the org and site the rows of a request belong to. Contexts
+// without request values, such as the command line, use the default tenant
+// where both are empty.
+type Tenant struct {
+	OrgUID  string `db:"org_uid"`
+	SiteUID string `db:"site_uid"`
+	// All is set when a platform admin opted in to read across tenants.
+	All bool `db:"-"`
+}
+
+// TenantFrom returns the tenant of the request context.
+func TenantFrom(ctx context.Context) Tenant {
+	v, err := api.GetContextValues(ctx)
+	if err != nil {
+		return Tenant{}
+	}
+
+	return Tenant{
+		OrgUID:  v.OrgUID,
+		SiteUID: v.SiteUID,
+		All:     v.AllTenants,
+	}
+}
+
+// Owns reports whether a row of the org and site belongs to the tenant.
+func (t Tenant) Owns(orgUID string, siteUID string) bool {
+	return t.OrgUID == orgUID && t.SiteUID == siteUID
+}
+
+// Data merges the tenant with the db tagged fields of a struct, or a map
+// such as the one returned by Filter.Data, into the parameters of a named
+// query.
+func (t Tenant) Data(data interface{}) map[string]interface{} {
+	m := queryArgs(data)
+	m["org_uid"] = t.OrgUID
+	m["site_uid"] = t.SiteUID
+
+	return m
+}
+
+// TenantQuery replaces the :tenant placeholder of the query with the
+// conditions keeping the rows of the tenant, prefixed with AND. The alias
+// qualifies the columns when the query joins tables. Nothing is kept out
+// when all tenants are read.
+func TenantQuery(t Tenant, alias string, q string) string {
+	if t.All {
+		return strings.ReplaceAll(q, ":tenant", "")
+	}
+
+	a := tenantAlias(alias)
+	cond := "\n\t\tAND " + a + "org_uid = :org_uid\n\t\tAND " + a + "site_uid = :site_uid"
+	return strings.ReplaceAll(q, ":tenant", cond)
+}
+
+// SharedTenantQuery is TenantQuery for tables whose rows of the default
+// tenant are shared with every tenant, such as the seeded build statuses.
+func SharedTenantQuery(t Tenant, alias string, q string) string {
+	if t.All {
+		return strings.ReplaceAll(q, ":tenant", "")
+	}
+
+	a := tenantAlias(alias)
+	cond := "\n\t\tAND ((" + a + "org_uid = :org_uid AND " + a + "site_uid = :site_uid)" +
+		" OR (" + a + "org_uid = '' AND " + a + "site_uid = ''))"
+	return strings.ReplaceAll(q, ":tenant", cond)
+}
+
+func tenantAlias(alias string) string {
+	if alias == "" {
+		return ""
+	}
+	return alias + "."
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// singleSpacePattern matches the runs of whitespace collapsed when a query
+// is printed.
+var singleSpacePattern = regexp.MustCompile(`\s\s+`)
+
+// startSpan starts a child span for a database call. Only the query as
+// written is recorded, the named parameters are never bound into it so no
+// values end up in the trace.
+func startSpan(ctx context.Context, name string, dialect string, query string) (context.Context, trace.Span) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+
+	var attrs []attribute.KeyValue
+	if query != "" {
+		attrs = append(attrs, attribute.String("db.statement", sanitize(query)))
+	}
+	if dialect != "" {
+		attrs = append(attrs, attribute.String("db.system", dialect))
+	}
+	span.SetAttributes(attrs...)
+
+	return ctx, span
+}
+
+// endSpan records the outcome of a database call and ends the span. Not
+// found is an expected answer and is not marked as a failure.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, ErrDBNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// sanitize collapses the whitespace of a query so it reads on one line.
+func sanitize(query string) string {
+	return strings.TrimSpace(singleSpacePattern.ReplaceAllString(query, " "))
+}
@@ -0,0 +1,117 @@
+// Package idempotency keeps the responses of the requests sent with an
+// Idempotency-Key header so retries get the same response instead of
+// repeating the request.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// MaxKeyLength is the longest Idempotency-Key accepted.
+const MaxKeyLength = 255
+
+// Config holds how long the records are kept and how large the requests
+// can be.
+type Config struct {
+	// TTL is how long a response is replayed after it was stored.
+	TTL time.Duration `mapstructure:"ttl"`
+	// LockTimeout is how long a request holds its key before a retry can
+	// run it again, in case it never completed. It should outlive the write
+	// timeout of the server.
+	LockTimeout time.Duration `mapstructure:"lockTimeout"`
+	// MaxBody is the largest request body, in bytes, read to fingerprint a
+	// request. Larger requests are rejected with a 413.
+	MaxBody int64 `mapstructure:"maxBody"`
+	// SweepInterval is how often the expired records are removed.
+	SweepInterval time.Duration `mapstructure:"sweepInterval"`
+}
+
+// Validate makes sure the records are kept for some time and the bodies
+// read are bounded.
+func (c Config) Validate() error {
+	if c.TTL <= 0 || c.LockTimeout <= 0 || c.SweepInterval <= 0 {
+		return fmt.Errorf("ttl, lock timeout and sweep interval must be positive, got %s, %s and %s", c.TTL, c.LockTimeout, c.SweepInterval)
+	}
+	if c.MaxBody <= 0 {
+		return fmt.Errorf("max body must be positive, got %d", c.MaxBody)
+	}
+	return nil
+}
+
+// Record is a request sent with an Idempotency-Key and, once it completed,
+// the response it produced.
+type Record struct {
+	// Key is the idempotency key scoped to the caller, see ScopedKey.
+	Key string
+	// Fingerprint identifies the request, see Fingerprint.
+	Fingerprint string
+	// StatusCode is zero while the request is in progress.
+	StatusCode  int
+	ContentType string
+	// Header holds the other response headers replayed with the body.
+	Header    http.Header
+	Body      []byte
+	CreatedOn time.Time
+	ExpiresOn time.Time
+}
+
+// Completed reports whether the response of the request was stored.
+func (r Record) Completed() bool {
+	return r.StatusCode != 0
+}
+
+// Store keeps the records until they expire. A record that expired is the
+// same as no record.
+type Store interface {
+	// Reserve stores the record, still in progress, unless the key already
+	// has a record. It then returns that record and false.
+	Reserve(ctx context.Context, rec Record, now time.Time) (Record, bool, error)
+	// Complete stores the response of a reserved record.
+	Complete(ctx context.Context, rec Record) error
+	// Release removes a reserved record so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// ErrInvalidKey is returned for keys that are blank or too long.
+var ErrInvalidKey = fmt.Errorf("idempotency key must be between 1 and %d characters long", MaxKeyLength)
+
+// CheckKey validates an Idempotency-Key header.
+func CheckKey(key string) error {
+	if key == "" || len(key) > MaxKeyLength {
+		return ErrInvalidKey
+	}
+	for _, c := range key {
+		if c < 0x20 || c > 0x7e {
+			return errors.New("idempotency key must only contain printable ascii characters")
+		}
+	}
+	return nil
+}
+
+// ScopedKey returns the key a record is stored under. Keys are only unique
+// per caller so the caller is part of it.
+func ScopedKey(caller string, key string) string {
+	return hash(caller, key)
+}
+
+// Fingerprint identifies a request by its method, path and body. A key sent
+// again with another fingerprint is an error on the client side.
+func Fingerprint(method string, path string, body []byte) string {
+	return hash(method, path, string(body))
+}
+
+// hash returns the hex sha256 of the parts, each prefixed with its length
+// so the parts can't run into each other.
+func hash(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		fmt.Fprintf(h, "%d:%s", len(p), p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
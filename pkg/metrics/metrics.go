@@ -0,0 +1,117 @@
+// Package metrics collects the metrics of the service and exposes them in
+// the Prometheus text format.
+package metrics
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the collectors of the service, on a registry of its own so
+// nothing registered globally by a dependency leaks in.
+type Metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+	panics   *prometheus.CounterVec
+	inFlight prometheus.Gauge
+}
+
+// New constructs the metrics of the service along with the Go runtime and
+// process metrics.
+func New() (*Metrics, error) {
+	labels := []string{"route", "method", "status"}
+
+	m := Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of requests handled, by route template, method and status class.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time taken to handle requests, by route template, method and status class.",
+			Buckets: prometheus.DefBuckets,
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_request_errors_total",
+			Help: "Number of requests that failed, by route template and method.",
+		}, []string{"route", "method"}),
+		panics: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_request_panics_total",
+			Help: "Number of requests that panicked, by route template and method.",
+		}, []string{"route", "method"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of requests being handled.",
+		}),
+	}
+
+	for _, c := range []prometheus.Collector{
+		m.requests,
+		m.duration,
+		m.errors,
+		m.panics,
+		m.inFlight,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	} {
+		if err := m.registry.Register(c); err != nil {
+			return nil, fmt.Errorf("registering collector: %w", err)
+		}
+	}
+
+	return &m, nil
+}
+
+// RegisterDB adds the connection pool stats of a database, read when the
+// metrics are scraped.
+func (m *Metrics) RegisterDB(name string, db *sql.DB) error {
+	if err := m.registry.Register(collectors.NewDBStatsCollector(db, name)); err != nil {
+		return fmt.Errorf("registering db stats: %w", err)
+	}
+	return nil
+}
+
+// Begin counts a request in flight until the returned func is called.
+func (m *Metrics) Begin() func() {
+	m.inFlight.Inc()
+	return m.inFlight.Dec
+}
+
+// Observe records a handled request. The route is the template the request
+// matched, such as /v1/build/:id, to keep the number of series bounded.
+func (m *Metrics) Observe(route string, method string, status int, took time.Duration, isError bool, isPanic bool) {
+	class := statusClass(status)
+
+	m.requests.WithLabelValues(route, method, class).Inc()
+	m.duration.WithLabelValues(route, method, class).Observe(took.Seconds())
+
+	if isError {
+		m.errors.WithLabelValues(route, method).Inc()
+	}
+	if isPanic {
+		m.panics.WithLabelValues(route, method).Inc()
+	}
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// statusClass returns the class of a status code, such as 2xx.
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the buckets that filled up are dropped.
+const sweepInterval = time.Minute
+
+// bucket is the state of a single token bucket.
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time
+}
+
+// Memory keeps the buckets in memory, the limits only hold for a single
+// instance.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewMemory constructs an in memory limiter. Buckets are dropped in the
+// background once full, until Close is called.
+func NewMemory() *Memory {
+	m := Memory{
+		buckets: make(map[string]*bucket),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go m.sweep()
+
+	return &m
+}
+
+// Allow takes a token from the bucket of the key.
+func (m *Memory) Allow(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Capacity()), last: now}
+		m.buckets[key] = b
+	}
+
+	tokens, res := take(b.tokens, b.last, limit, now)
+	b.tokens = tokens
+	b.last = now
+	b.full = now.Add(res.Reset)
+
+	return res, nil
+}
+
+// Close stops dropping the buckets.
+func (m *Memory) Close() {
+	select {
+	case <-m.stop:
+	default:
+		close(m.stop)
+	}
+	<-m.done
+}
+
+// sweep drops the buckets that filled up, they are the same as new ones.
+func (m *Memory) sweep() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			m.mu.Lock()
+			for key, b := range m.buckets {
+				if !now.Before(b.full) {
+					delete(m.buckets, key)
+				}
+			}
+			m.mu.Unlock()
+		case <-m.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Package ratelimit limits how often callers can make requests with token
+// buckets kept by a pluggable backend.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Set of tiers every policy has a limit for.
+const (
+	// TierAnonymous applies to callers that were not authenticated.
+	TierAnonymous = "anonymous"
+	// TierDefault applies to authenticated callers without a tier of their
+	// own.
+	TierDefault = "default"
+)
+
+// Limit is a token bucket refilled with Requests tokens every Per, holding
+// at most Burst tokens. Burst defaults to Requests.
+type Limit struct {
+	Requests int           `mapstructure:"requests"`
+	Per      time.Duration `mapstructure:"per"`
+	Burst    int           `mapstructure:"burst"`
+}
+
+// Capacity returns the number of tokens a full bucket holds.
+func (l Limit) Capacity() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// rate returns the number of tokens refilled every second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// validate makes sure the bucket can ever be refilled.
+func (l Limit) validate() error {
+	if l.Requests < 1 || l.Per <= 0 || l.Burst < 0 {
+		return fmt.Errorf("limit needs a positive number of requests per period, got %d per %s", l.Requests, l.Per)
+	}
+	return nil
+}
+
+// Result is the state of a bucket after taking a token from it.
+type Result struct {
+	// Allowed is set when a token was taken.
+	Allowed bool
+	// Limit is the capacity of the bucket.
+	Limit int
+	// Remaining is the number of whole tokens left.
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until a token is available, when not allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter keeps the token buckets. A shared store can implement it so the
+// limits hold across instances.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// Config holds the limits of every tier and route as they are configured.
+type Config struct {
+	// Tiers maps a role name, or one of TierAnonymous and TierDefault, to the
+	// limit of every caller in the tier.
+	Tiers map[string]Limit `mapstructure:"tiers"`
+	// Routes maps "METHOD /path", with the path as the route is registered,
+	// to a limit applied on top of the tier.
+	Routes map[string]Limit `mapstructure:"routes"`
+}
+
+// Policy picks the limits of a request. Tier and route names are case
+// insensitive.
+type Policy struct {
+	tiers  map[string]Limit
+	routes map[string]Limit
+}
+
+// NewPolicy constructs a policy from the configured limits.
+func NewPolicy(cfg Config) (Policy, error) {
+	p := Policy{
+		tiers:  make(map[string]Limit, len(cfg.Tiers)),
+		routes: make(map[string]Limit, len(cfg.Routes)),
+	}
+
+	for name, l := range cfg.Tiers {
+		if err := l.validate(); err != nil {
+			return Policy{}, fmt.Errorf("tier %q: %w", name, err)
+		}
+		p.tiers[strings.ToLower(strings.TrimSpace(name))] = l
+	}
+	for route, l := range cfg.Routes {
+		if err := l.validate(); err != nil {
+			return Policy{}, fmt.Errorf("route %q: %w", route, err)
+		}
+		p.routes[routeKey(strings.Fields(route))] = l
+	}
+
+	if len(p.tiers) == 0 && len(p.routes) == 0 {
+		return Policy{}, errors.New("no limits configured")
+	}
+
+	return p, nil
+}
+
+// Tier returns the name and the limit of the tier of a caller. Callers with
+// several tiered roles get the most generous one.
+func (p Policy) Tier(authenticated bool, roles []string) (string, Limit, bool) {
+	if !authenticated {
+		l, ok := p.tiers[TierAnonymous]
+		return TierAnonymous, l, ok
+	}
+
+	var (
+		name string
+		best Limit
+	)
+	for _, role := range roles {
+		role = strings.ToLower(role)
+		l, ok := p.tiers[role]
+		if ok && (name == "" || l.rate() > best.rate()) {
+			name, best = role, l
+		}
+	}
+	if name != "" {
+		return name, best, true
+	}
+
+	l, ok := p.tiers[TierDefault]
+	return TierDefault, l, ok
+}
+
+// Route returns the limit of a route.
+func (p Policy) Route(method string, path string) (Limit, bool) {
+	l, ok := p.routes[routeKey([]string{method, path})]
+	return l, ok
+}
+
+func routeKey(fields []string) string {
+	return strings.ToLower(strings.Join(fields, " "))
+}
+
+// take refills a bucket holding tokens since last and takes a token from it.
+func take(tokens float64, last time.Time, l Limit, now time.Time) (float64, Result) {
+	capacity := float64(l.Capacity())
+	rate := l.rate()
+
+	if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens = math.Min(capacity, tokens+elapsed*rate)
+	}
+
+	res := Result{Limit: l.Capacity()}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	res.Remaining = int(math.Floor(tokens))
+	res.Reset = seconds((capacity - tokens) / rate)
+
+	return tokens, res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
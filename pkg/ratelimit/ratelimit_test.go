@@ -0,0 +1,113 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/pkg/ratelimit"
+)
+
+func TestMemoryAllow(t *testing.T) {
+	m := ratelimit.NewMemory()
+	defer m.Close()
+
+	ctx := context.Background()
+	limit := ratelimit.Limit{Requests: 2, Per: time.Second, Burst: 3}
+	now := time.Date(2021, 5, 25, 0, 0, 0, 0, time.UTC)
+
+	// A new bucket is full, the burst is taken at once.
+	for i := 2; i >= 0; i-- {
+		res, err := m.Allow(ctx, "a", limit, now)
+		if err != nil {
+			t.Fatalf("allow: %s", err)
+		}
+		if !res.Allowed || res.Remaining != i || res.Limit != 3 {
+			t.Fatalf("burst: got %+v, want allowed with %d remaining", res, i)
+		}
+	}
+
+	res, err := m.Allow(ctx, "a", limit, now)
+	if err != nil {
+		t.Fatalf("allow: %s", err)
+	}
+	if res.Allowed || res.RetryAfter != 500*time.Millisecond || res.Reset != 1500*time.Millisecond {
+		t.Errorf("empty: got %+v, want denied, retry after 500ms and full after 1.5s", res)
+	}
+
+	// Other keys have buckets of their own.
+	if res, _ := m.Allow(ctx, "b", limit, now); !res.Allowed {
+		t.Errorf("other key: got %+v, want allowed", res)
+	}
+
+	// Tokens come back at the rate of the limit.
+	if res, _ := m.Allow(ctx, "a", limit, now.Add(500*time.Millisecond)); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("refilled: got %+v, want allowed with 0 remaining", res)
+	}
+	if res, _ := m.Allow(ctx, "a", limit, now.Add(time.Hour)); !res.Allowed || res.Remaining != 2 {
+		t.Errorf("full again: got %+v, want allowed with 2 remaining, never above the burst", res)
+	}
+}
+
+func TestNewPolicy(t *testing.T) {
+	tests := map[string]ratelimit.Config{
+		"empty":       {},
+		"no requests": {Tiers: map[string]ratelimit.Limit{"default": {Per: time.Minute}}},
+		"no period":   {Tiers: map[string]ratelimit.Limit{"default": {Requests: 10}}},
+		"bad route":   {Routes: map[string]ratelimit.Limit{"POST /v1/build": {Requests: 1, Per: time.Minute, Burst: -1}}},
+	}
+
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ratelimit.NewPolicy(cfg); err == nil {
+				t.Error("got no error, want one")
+			}
+		})
+	}
+}
+
+func TestPolicyTier(t *testing.T) {
+	p, err := ratelimit.NewPolicy(ratelimit.Config{
+		Tiers: map[string]ratelimit.Limit{
+			"anonymous": {Requests: 10, Per: time.Minute},
+			"Default":   {Requests: 100, Per: time.Minute},
+			"ci":        {Requests: 1000, Per: time.Minute},
+			"viewer":    {Requests: 50, Per: time.Minute},
+		},
+		Routes: map[string]ratelimit.Limit{
+			"POST /v1/build": {Requests: 5, Per: time.Minute},
+		},
+	})
+	if err != nil {
+		t.Fatalf("policy: %s", err)
+	}
+
+	tests := []struct {
+		name          string
+		authenticated bool
+		roles         []string
+		tier          string
+		requests      int
+	}{
+		{"anonymous", false, []string{"ci"}, "anonymous", 10},
+		{"no tiered role", true, []string{"admin"}, "default", 100},
+		{"tiered role", true, []string{"Viewer"}, "viewer", 50},
+		{"most generous role", true, []string{"viewer", "CI"}, "ci", 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tier, l, ok := p.Tier(tt.authenticated, tt.roles)
+			if !ok || tier != tt.tier || l.Requests != tt.requests {
+				t.Errorf("got %s %+v %t, want %s with %d requests", tier, l, ok, tt.tier, tt.requests)
+			}
+		})
+	}
+
+	if l, ok := p.Route("post", "/v1/build"); !ok || l.Requests != 5 {
+		t.Errorf("route: got %+v %t, want 5 requests", l, ok)
+	}
+	if _, ok := p.Route("GET", "/v1/build"); ok {
+		t.Error("route without a limit: got one")
+	}
+}
@@ -0,0 +1,170 @@
+// Package tracing configures the OpenTelemetry tracer provider used by the
+// api and database packages to export their spans.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// Set of supported span exporters.
+const (
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterOTLP   = "otlp"
+)
+
+// Config holds the tracing settings read from the service configuration.
+type Config struct {
+	// Exporter is one of stdout, file or otlp.
+	Exporter string `mapstructure:"exporter"`
+	// File receives the spans of the file exporter, one JSON document per
+	// span. It is appended to when it already exists.
+	File string `mapstructure:"file"`
+	// Endpoint is the host:port of the collector receiving OTLP over HTTP.
+	Endpoint string `mapstructure:"endpoint"`
+	// Insecure sends OTLP over plain HTTP, which is what a collector
+	// running next to the service usually expects.
+	Insecure bool `mapstructure:"insecure"`
+	// Probability is the ratio of new traces that are sampled. Requests
+	// carrying a sampled parent are always traced.
+	Probability float64 `mapstructure:"probability"`
+	// Attributes are extra resource attributes given as key=value.
+	Attributes []string `mapstructure:"attributes"`
+}
+
+// Validate checks the configuration is usable.
+func (c Config) Validate() error {
+	switch c.Exporter {
+	case ExporterStdout:
+	case ExporterFile:
+		if c.File == "" {
+			return errors.New("file is required by the file exporter")
+		}
+	case ExporterOTLP:
+		if c.Endpoint == "" {
+			return errors.New("endpoint is required by the otlp exporter")
+		}
+	default:
+		return fmt.Errorf("unsupported exporter %q", c.Exporter)
+	}
+
+	if c.Probability < 0 || c.Probability > 1 {
+		return fmt.Errorf("probability must be between 0 and 1, got %v", c.Probability)
+	}
+
+	if _, err := attributes(c.Attributes); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Provider is the tracer provider installed as the global one.
+type Provider struct {
+	tp *sdktrace.TracerProvider
+	w  io.Closer
+}
+
+// Start builds the exporter and tracer provider described by the config and
+// installs them, with the W3C trace context propagator, as the globals.
+func Start(ctx context.Context, cfg Config, service string, version string) (*Provider, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	attrs, err := attributes(cfg.Attributes)
+	if err != nil {
+		return nil, err
+	}
+	attrs = append(attrs, semconv.ServiceName(service))
+	if version != "" {
+		attrs = append(attrs, semconv.ServiceVersion(version))
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, attrs...))
+	if err != nil {
+		return nil, fmt.Errorf("building resource: %w", err)
+	}
+
+	var (
+		exp sdktrace.SpanExporter
+		w   io.Closer
+	)
+	switch cfg.Exporter {
+	case ExporterStdout:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+
+	case ExporterFile:
+		f, ferr := os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if ferr != nil {
+			return nil, fmt.Errorf("opening %s: %w", cfg.File, ferr)
+		}
+		w = f
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(f))
+
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exp, err = otlptracehttp.New(ctx, opts...)
+	}
+	if err != nil {
+		if w != nil {
+			_ = w.Close()
+		}
+		return nil, fmt.Errorf("constructing %s exporter: %w", cfg.Exporter, err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Probability))),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return &Provider{tp: tp, w: w}, nil
+}
+
+// Shutdown flushes the spans still buffered and stops the exporter.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	err := p.tp.Shutdown(ctx)
+	if p.w != nil {
+		if cerr := p.w.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// attributes parses key=value pairs into resource attributes.
+func attributes(kvs []string) ([]attribute.KeyValue, error) {
+	attrs := make([]attribute.KeyValue, 0, len(kvs)+2)
+	for _, kv := range kvs {
+		i := strings.IndexByte(kv, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("attribute %q is not in key=value form", kv)
+		}
+		attrs = append(attrs, attribute.String(strings.TrimSpace(kv[:i]), strings.TrimSpace(kv[i+1:])))
+	}
+	return attrs, nil
+}
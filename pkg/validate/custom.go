@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"unicode"
 
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
@@ -35,6 +36,11 @@ func RegisterCustomValidators(validate *validator.Validate, translator ut.Transl
 		return fmt.Errorf("header: %w", err)
 	}
 
+	// Required without
+	if err := requiredWithoutCustomError(translator); err != nil {
+		return fmt.Errorf("requiredWithout: %w", err)
+	}
+
 	return nil
 }
 
@@ -104,3 +110,33 @@ func headersRequiredCustomError(trans ut.Translator) error {
 		return t
 	})
 }
+
+// requiredWithoutCustomError names the alternative field in snake case so
+// it matches the json name the client sent.
+func requiredWithoutCustomError(trans ut.Translator) error {
+	return validate.RegisterTranslation("required_without", trans, func(ut ut.Translator) error {
+		return ut.Add("required_without", "{0} is required when {1} is not provided", true)
+	}, func(ut ut.Translator, fe validator.FieldError) string {
+		t, _ := ut.T("required_without", fe.Field(), toSnakeCase(fe.Param()))
+		return t
+	})
+}
+
+// toSnakeCase converts a go field name such as BuildStatusID into
+// build_status_id.
+func toSnakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			prevLower := i > 0 && unicode.IsLower(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if i > 0 && (prevLower || (nextLower && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,254 @@
+// Package webhook signs and sends the events delivered to the urls callers
+// subscribed with, and decides when a failed delivery is retried.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned when a delivery would reach a loopback,
+// link-local, private or unspecified address that is not trusted.
+var ErrForbiddenAddress = errors.New("address is not allowed for deliveries")
+
+// Set of headers sent along every delivery. The id of an event is the same
+// for every subscriber and attempt so receivers can drop duplicates.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderID        = "X-Webhook-ID"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// signaturePrefix names the algorithm of the signature header.
+const signaturePrefix = "sha256="
+
+// maxResponseBytes bounds how much of a response is read before the
+// connection is reused.
+const maxResponseBytes = 64 << 10
+
+// Config holds how deliveries are sent and retried.
+type Config struct {
+	// PollInterval is how often due deliveries are looked up.
+	PollInterval time.Duration `mapstructure:"pollInterval"`
+	// Timeout bounds a single attempt, a slower receiver fails it.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// MaxAttempts is how many times a delivery is tried before it is
+	// marked as failed.
+	MaxAttempts int `mapstructure:"maxAttempts"`
+	// MinBackoff is the wait after the first failed attempt, it doubles
+	// after every failure up to MaxBackoff.
+	MinBackoff time.Duration `mapstructure:"minBackoff"`
+	MaxBackoff time.Duration `mapstructure:"maxBackoff"`
+	// BatchSize is how many due deliveries are picked up per poll.
+	BatchSize int `mapstructure:"batchSize"`
+	// Workers is how many deliveries are sent at the same time.
+	Workers int `mapstructure:"workers"`
+	// TrustedNetworks lists the addresses or CIDRs deliveries may reach
+	// even though they are loopback, link-local or private, every other
+	// such address is refused when dialing.
+	TrustedNetworks []string `mapstructure:"trustedNetworks"`
+}
+
+// Validate checks the configuration is usable.
+func (c Config) Validate() error {
+	if c.PollInterval <= 0 || c.Timeout <= 0 {
+		return fmt.Errorf("poll interval and timeout must be positive, got %s and %s", c.PollInterval, c.Timeout)
+	}
+	if c.MinBackoff <= 0 || c.MaxBackoff < c.MinBackoff {
+		return fmt.Errorf("backoff must be positive with max at least min, got %s and %s", c.MinBackoff, c.MaxBackoff)
+	}
+	if c.MaxAttempts < 1 || c.BatchSize < 1 || c.Workers < 1 {
+		return errors.New("max attempts, batch size and workers must be at least 1")
+	}
+	if _, err := c.trusted(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// trusted parses the trusted networks, a single address is a network of
+// its own.
+func (c Config) trusted() ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(c.TrustedNetworks))
+	for _, s := range c.TrustedNetworks {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("trusted network %q is not an address or CIDR", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("trusted network %q is not an address or CIDR", s)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// Backoff returns how long to wait before trying again after the given
+// number of failed attempts.
+func (c Config) Backoff(attempts int) time.Duration {
+	d := c.MinBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= c.MaxBackoff {
+			return c.MaxBackoff
+		}
+	}
+	return d
+}
+
+// Lease is how long a delivery being sent is hidden from the other pollers.
+// It outlives an attempt so a delivery is only picked up again when the
+// process sending it went away.
+func (c Config) Lease() time.Duration {
+	return 2 * c.Timeout
+}
+
+// Sign returns the signature header of a delivery, the hex HMAC-SHA256 of
+// the timestamp, a dot and the body keyed with the secret of the
+// subscription. Signing the timestamp lets receivers reject replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether a signature header matches the delivery, it is
+// what receivers written in Go check.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Message is a delivery of an event to a subscriber.
+type Message struct {
+	URL        string
+	Secret     string
+	Event      string
+	EventID    string
+	DeliveryID string
+	Body       []byte
+}
+
+// Client sends deliveries over HTTP.
+type Client struct {
+	http      *http.Client
+	userAgent string
+}
+
+// NewClient constructs a client failing attempts slower than the timeout.
+// Redirects are not followed, the subscription has to name the final url.
+// The address a delivery resolves to is checked when dialing so a url can't
+// reach the internal network, unless the address is trusted. Deliveries
+// don't go through a proxy, it would hide the address.
+func NewClient(cfg Config, userAgent string) (*Client, error) {
+	trusted, err := cfg.trusted()
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout: cfg.Timeout,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			return checkAddress(address, trusted)
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Client{
+		http: &http.Client{
+			Transport: transport,
+			Timeout:   cfg.Timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		userAgent: userAgent,
+	}, nil
+}
+
+// checkAddress refuses the loopback, link-local, private and unspecified
+// addresses outside of the trusted networks.
+func checkAddress(address string, trusted []*net.IPNet) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return nil
+		}
+	}
+
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsPrivate() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+	return nil
+}
+
+// Send posts the message, signed as of now. The status code is returned
+// whenever the receiver answered, and an error unless it was a 2xx.
+func (c *Client) Send(ctx context.Context, m Message, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.URL, bytes.NewReader(m.Body))
+	if err != nil {
+		return 0, fmt.Errorf("building request: %w", err)
+	}
+
+	ts := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set(HeaderEvent, m.Event)
+	req.Header.Set(HeaderID, m.EventID)
+	req.Header.Set(HeaderDelivery, m.DeliveryID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(m.Secret, ts, m.Body))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
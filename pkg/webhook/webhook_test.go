@@ -0,0 +1,175 @@
+package webhook_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/pkg/webhook"
+)
+
+// config is a valid configuration the tests change.
+func config(fn func(c *webhook.Config)) webhook.Config {
+	c := webhook.Config{
+		PollInterval: time.Second,
+		Timeout:      time.Second,
+		MaxAttempts:  5,
+		MinBackoff:   time.Second,
+		MaxBackoff:   10 * time.Second,
+		BatchSize:    10,
+		Workers:      2,
+	}
+	if fn != nil {
+		fn(&c)
+	}
+	return c
+}
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	sig := webhook.Sign("secret", 1621900800, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      []byte
+		signature string
+		valid     bool
+	}{
+		{"signed", "secret", 1621900800, body, sig, true},
+		{"other body", "secret", 1621900800, []byte(`{"id":"2"}`), sig, false},
+		{"other timestamp", "secret", 1621900801, body, sig, false},
+		{"other secret", "other", 1621900800, body, sig, false},
+		{"no prefix", "secret", 1621900800, body, sig[len("sha256="):], false},
+		{"empty", "secret", 1621900800, body, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := webhook.Verify(tt.secret, tt.timestamp, tt.body, tt.signature); got != tt.valid {
+				t.Errorf("got %t, want %t", got, tt.valid)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := config(nil)
+
+	for attempts, want := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 8 * time.Second,
+		5: 10 * time.Second,
+		9: 10 * time.Second,
+	} {
+		if got := c.Backoff(attempts); got != want {
+			t.Errorf("after %d attempts: got %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := config(func(c *webhook.Config) { c.TrustedNetworks = []string{"127.0.0.1", "10.0.0.0/8", "::1"} }).Validate(); err != nil {
+		t.Errorf("valid: got error %s", err)
+	}
+
+	tests := map[string]webhook.Config{
+		"no timeout":       config(func(c *webhook.Config) { c.Timeout = 0 }),
+		"backoff inverted": config(func(c *webhook.Config) { c.MaxBackoff = c.MinBackoff / 2 }),
+		"no workers":       config(func(c *webhook.Config) { c.Workers = 0 }),
+		"bad address":      config(func(c *webhook.Config) { c.TrustedNetworks = []string{"localhost"} }),
+		"bad network":      config(func(c *webhook.Config) { c.TrustedNetworks = []string{"10.0.0.0/33"} }),
+	}
+
+	for name, c := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := c.Validate(); err == nil {
+				t.Error("got no error, want one")
+			}
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	now := time.Date(2021, 5, 25, 0, 0, 0, 0, time.UTC)
+	msg := webhook.Message{
+		Secret:     "secret",
+		Event:      "build.created",
+		EventID:    "event-1",
+		DeliveryID: "delivery-1",
+		Body:       []byte(`{"id":"1"}`),
+	}
+
+	received := make(chan *http.Request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(webhook.HeaderTimestamp), 10, 64)
+		if !webhook.Verify(msg.Secret, ts, body, r.Header.Get(webhook.HeaderSignature)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received <- r
+	}))
+	defer srv.Close()
+
+	t.Run("internal address", func(t *testing.T) {
+		c, err := webhook.NewClient(config(nil), "test")
+		if err != nil {
+			t.Fatalf("client: %s", err)
+		}
+
+		m := msg
+		m.URL = srv.URL
+		if _, err := c.Send(context.Background(), m, now); !errors.Is(err, webhook.ErrForbiddenAddress) {
+			t.Errorf("got %v, want %v", err, webhook.ErrForbiddenAddress)
+		}
+	})
+
+	c, err := webhook.NewClient(config(func(c *webhook.Config) { c.TrustedNetworks = []string{"127.0.0.1"} }), "test")
+	if err != nil {
+		t.Fatalf("client: %s", err)
+	}
+
+	t.Run("trusted address", func(t *testing.T) {
+		m := msg
+		m.URL = srv.URL
+		code, err := c.Send(context.Background(), m, now)
+		if err != nil || code != http.StatusOK {
+			t.Fatalf("got %d %v, want %d", code, err, http.StatusOK)
+		}
+
+		r := <-received
+		for name, want := range map[string]string{
+			webhook.HeaderEvent:     msg.Event,
+			webhook.HeaderID:        msg.EventID,
+			webhook.HeaderDelivery:  msg.DeliveryID,
+			webhook.HeaderTimestamp: strconv.FormatInt(now.Unix(), 10),
+			"User-Agent":            "test",
+		} {
+			if got := r.Header.Get(name); got != want {
+				t.Errorf("%s: got %q, want %q", name, got, want)
+			}
+		}
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		m := msg
+		m.URL = srv.URL + "/moved"
+		if code, err := c.Send(context.Background(), m, now); err == nil || code != http.StatusFound {
+			t.Errorf("got %d %v, want %d not followed", code, err, http.StatusFound)
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/models/apikey"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// runAPIKey executes the apikey sub command, used to issue the first key
+// when the API only accepts api keys:
+//
+//	rest apikey create <name> <owner> [scope,...]
+func runAPIKey(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, args []string) error {
+	if len(args) < 3 || args[0] != "create" {
+		return fmt.Errorf("usage: %s apikey create <name> <owner> [scope,...]", appName)
+	}
+
+	nk := apikey.NewAPIKey{
+		Name:  args[1],
+		Owner: args[2],
+	}
+	if len(args) > 3 {
+		nk.Scopes = strings.Split(args[3], ",")
+	}
+
+	k, err := apikey.NewCore(log, db, &sync.RWMutex{}).Create(ctx, nk, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("creating api key: %w", err)
+	}
+
+	log.Infow("apikey", "status", "created", "id", k.ID, "prefix", k.Prefix)
+	fmt.Println(k.Key)
+
+	return nil
+}
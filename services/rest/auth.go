@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/chaitanyamaili/go_rest/pkg/auth"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// newAuth constructs the token verification from the auth config. The
+// inline key set can be given as a json string or object.
+func newAuth(log *zap.SugaredLogger) (*auth.Auth, error) {
+	var jwks string
+	switch v := viper.Get("auth.jwks").(type) {
+	case nil:
+	case string:
+		jwks = v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("encoding inline jwks: %w", err)
+		}
+		jwks = string(b)
+	}
+
+	return auth.New(auth.Config{
+		Log:             log,
+		JWKSFile:        viper.GetString("auth.jwksFile"),
+		JWKS:            jwks,
+		Issuer:          viper.GetString("auth.issuer"),
+		Audience:        viper.GetString("auth.audience"),
+		Algorithms:      viper.GetStringSlice("auth.algorithms"),
+		RefreshInterval: viper.GetDuration("auth.refreshInterval"),
+		Leeway:          viper.GetDuration("auth.leeway"),
+	})
+}
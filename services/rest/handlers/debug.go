@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"sync/atomic"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	"github.com/chaitanyamaili/go_rest/services/rest/handlers/debug/checkgrp"
+)
+
+// DebugMuxConfig contains all the mandatory systems required by the debug
+// handlers.
+type DebugMuxConfig struct {
+	Build string
+	Log   *zap.SugaredLogger
+	DB    *sqlx.DB
+	// Draining is set by main once shutdown begins.
+	Draining *atomic.Bool
+}
+
+// DebugStandardLibraryMux registers all the debug routes from the standard
+// library into a new mux bypassing the use of the DefaultServerMux. Using
+// the DefaultServerMux would be a security risk since a dependency could
+// inject a handler into our service without us knowing it.
+func DebugStandardLibraryMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// Register all the standard library debug endpoints.
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	return mux
+}
+
+// DebugMux registers all the debug standard library routes and then custom
+// debug application routes for the service. This bypasses the use of the
+// DefaultServerMux. Using the DefaultServerMux would be a security risk since
+// a dependency could inject a handler into our service without us knowing it.
+func DebugMux(cfg DebugMuxConfig) http.Handler {
+	mux := DebugStandardLibraryMux()
+
+	// Register debug check endpoints.
+	cgh := checkgrp.Handlers{
+		Build:    cfg.Build,
+		Log:      cfg.Log,
+		DB:       cfg.DB,
+		Draining: cfg.Draining,
+	}
+	mux.HandleFunc("/debug/readiness", cgh.Readiness)
+	mux.HandleFunc("/debug/liveness", cgh.Liveness)
+
+	return mux
+}
@@ -0,0 +1,113 @@
+// Package checkgrp maintains the group of handlers for health checking.
+package checkgrp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+)
+
+// readinessTimeout bounds how long the database may take to answer a
+// readiness probe before the service is reported as not ready.
+const readinessTimeout = time.Second
+
+// Handlers manages the set of check endpoints.
+type Handlers struct {
+	Build string
+	Log   *zap.SugaredLogger
+	DB    *sqlx.DB
+	// Draining is set once shutdown begins, from then on the service
+	// reports itself as not ready so load balancers stop routing to it.
+	Draining *atomic.Bool
+}
+
+// Readiness checks if the database is ready and if not will return a 503
+// status. It also fails once the service is draining. Do not respond by
+// just returning an error because further up in the call stack it will
+// interpret that as a non-trusted error.
+func (h Handlers) Readiness(w http.ResponseWriter, r *http.Request) {
+	status := "ok"
+	statusCode := http.StatusOK
+
+	if h.Draining != nil && h.Draining.Load() {
+		status = "draining"
+		statusCode = http.StatusServiceUnavailable
+	} else {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		if err := database.StatusCheck(ctx, h.DB); err != nil {
+			status = "db not ready"
+			statusCode = http.StatusServiceUnavailable
+			h.Log.Errorw("readiness failure", "component", "checkgrp", "ERROR", err)
+		}
+	}
+
+	data := struct {
+		Status string `json:"status"`
+	}{
+		Status: status,
+	}
+
+	if err := response(w, statusCode, data); err != nil {
+		h.Log.Errorw("readiness", "component", "checkgrp", "ERROR", err)
+	}
+}
+
+// Liveness returns simple status info if the service is alive. It answers
+// for as long as the process is up, even while draining, so the
+// orchestrator does not restart a service that is shutting down.
+func (h Handlers) Liveness(w http.ResponseWriter, r *http.Request) {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unavailable"
+	}
+
+	data := struct {
+		Status     string `json:"status,omitempty"`
+		Build      string `json:"build,omitempty"`
+		Host       string `json:"host,omitempty"`
+		GOMAXPROCS int    `json:"GOMAXPROCS,omitempty"`
+	}{
+		Status:     "up",
+		Build:      h.Build,
+		Host:       host,
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+	}
+
+	if err := response(w, http.StatusOK, data); err != nil {
+		h.Log.Errorw("liveness", "component", "checkgrp", "ERROR", err)
+	}
+}
+
+func response(w http.ResponseWriter, statusCode int, data interface{}) error {
+
+	// Convert the response value to JSON.
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	// Set the content type and headers once we know marshaling has succeeded.
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	// Write the status code to the response.
+	w.WriteHeader(statusCode)
+
+	// Send the result back to the client.
+	if _, err := w.Write(jsonData); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -10,8 +10,14 @@ import (
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 
+	"github.com/chaitanyamaili/go_rest/models/apikey"
+	idempotencydb "github.com/chaitanyamaili/go_rest/models/idempotency"
 	"github.com/chaitanyamaili/go_rest/pkg/api"
 	"github.com/chaitanyamaili/go_rest/pkg/api/middleware"
+	"github.com/chaitanyamaili/go_rest/pkg/auth"
+	"github.com/chaitanyamaili/go_rest/pkg/idempotency"
+	"github.com/chaitanyamaili/go_rest/pkg/metrics"
+	"github.com/chaitanyamaili/go_rest/pkg/ratelimit"
 	v1 "github.com/chaitanyamaili/go_rest/services/rest/handlers/v1"
 )
 
@@ -27,6 +33,18 @@ type APIMuxConfig struct {
 	DB       *sqlx.DB
 	RWMux    *sync.RWMutex
 	Headers  bool
+	Auth     *auth.Auth
+	APIKeys  bool
+	Policy   auth.Policy
+	// RateLimiter is nil when requests are not rate limited.
+	RateLimiter ratelimit.Limiter
+	RateLimits  ratelimit.Policy
+	// Idempotency is nil when Idempotency-Key headers are ignored.
+	Idempotency *idempotency.Config
+	// Compress is nil when responses are sent uncompressed.
+	Compress *middleware.CompressConfig
+	// Metrics is nil when requests are not measured.
+	Metrics *metrics.Metrics
 }
 
 // APIMux constructs a http.Handler with all application routes defined.
@@ -37,13 +55,33 @@ func APIMux(cfg APIMuxConfig, options ...func(opts *Options)) http.Handler {
 	}
 
 	// Construct the web.App which holds all routes as well as common Middleware.
-	mw := make([]api.Middleware, 0, 6)
+	mw := make([]api.Middleware, 0, 12)
+	mw = append(mw, middleware.RequestID())
 	mw = append(mw, middleware.Logger(cfg.Log))
-	// mw = append(mw, middleware.Metrics())
+	if cfg.Compress != nil {
+		mw = append(mw, middleware.Compress(*cfg.Compress))
+	}
+	if cfg.Metrics != nil {
+		mw = append(mw, middleware.Metrics(cfg.Metrics))
+	}
 	mw = append(mw, middleware.Errors(cfg.Log))
+	var policy *auth.Policy
+	if cfg.Auth != nil || cfg.APIKeys {
+		policy = &cfg.Policy
+
+		var keys auth.KeyVerifier
+		if cfg.APIKeys {
+			keys = apikey.NewCore(cfg.Log, cfg.DB, cfg.RWMux)
+		}
+		mw = append(mw, middleware.Authenticate(cfg.Auth, keys))
+	}
 	if cfg.Headers {
 		mw = append(mw, middleware.Headers())
 	}
+	mw = append(mw, middleware.Tenant(cfg.Log, policy, v1.ScopeTenantsAdmin))
+	if cfg.RateLimiter != nil {
+		mw = append(mw, middleware.RateLimit(cfg.Log, cfg.RateLimiter, cfg.RateLimits))
+	}
 	mw = append(mw, middleware.Panics())
 	a := api.NewAPI(
 		cfg.Shutdown,
@@ -63,11 +101,21 @@ func APIMux(cfg APIMuxConfig, options ...func(opts *Options)) http.Handler {
 		a.Handle(http.MethodOptions, "", h, middleware.Cors(opts.corsOrigin))
 	}
 
+	// The mutating routes handle the Idempotency-Key header after they
+	// authorized the caller.
+	var idem api.Middleware
+	if cfg.Idempotency != nil {
+		store := idempotencydb.NewCore(cfg.Log, cfg.DB, cfg.RWMux)
+		idem = middleware.Idempotency(cfg.Log, store, *cfg.Idempotency)
+	}
+
 	// Load the v1 routes.
 	v1.Routes(a, v1.Config{
-		Log:   cfg.Log,
-		DB:    cfg.DB,
-		RWMux: cfg.RWMux,
+		Log:         cfg.Log,
+		DB:          cfg.DB,
+		RWMux:       cfg.RWMux,
+		Policy:      policy,
+		Idempotency: idem,
 	})
 
 	return a
@@ -0,0 +1,228 @@
+package apikeygrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/chaitanyamaili/go_rest/models/apikey"
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/auth"
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+)
+
+// Handlers manages the set of api key endpoints.
+type Handlers struct {
+	APIKey apikey.Core
+	// Policy expands the roles of the caller into scopes, nil when callers
+	// are not authenticated.
+	Policy *auth.Policy
+}
+
+// Create generates an api key, its secret is only returned here. A key is
+// only granted scopes the caller holds, so nobody mints a key more powerful
+// than themselves. Callers that are not authenticated hold no scope, keys
+// with scopes are then created with the apikey command.
+//
+// swagger:operation POST /apikey APIKey APIKeyCreate
+//
+// # Creates a new API key
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "201":
+//		   "$ref": "#/responses/CreatedAPIKeyRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+//	  "403":
+//		   "$ref": "#/responses/errorResponse403"
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := api.GetContextValues(ctx)
+	if err != nil {
+		return api.NewShutdownError("api value missing from context")
+	}
+
+	var nk apikey.NewAPIKey
+	if err := api.Decode(r, &nk); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	var missing []string
+	if h.Policy == nil {
+		missing = nk.Scopes
+	} else {
+		missing = h.Policy.Missing(v.Roles, v.Scopes, nk.Scopes)
+	}
+	if len(missing) > 0 {
+		return api.NewRequestError(fmt.Errorf("cannot grant scopes the caller does not hold: %s", strings.Join(missing, ", ")), http.StatusForbidden)
+	}
+
+	k, err := h.APIKey.Create(ctx, nk, v.Now)
+	if err != nil {
+		return err
+	}
+
+	return api.Respond(ctx, w, []apikey.CreatedAPIKey{k}, http.StatusCreated)
+}
+
+// Rotate replaces the secret of an api key, the new secret is only returned
+// here.
+//
+// swagger:operation POST /apikey/{id}/rotate APIKey APIKeyRotate
+//
+// # Rotates the secret of an API key
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/CreatedAPIKeyRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+//	  "404":
+//		   "$ref": "#/responses/errorResponse404"
+func (h Handlers) Rotate(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := api.GetContextValues(ctx)
+	if err != nil {
+		return api.NewShutdownError("api value missing from context")
+	}
+
+	id := api.Param(r, "id")
+
+	k, err := h.APIKey.Rotate(ctx, id, v.Now)
+	if err != nil {
+		return keyError(err, id)
+	}
+
+	return api.Respond(ctx, w, []apikey.CreatedAPIKey{k}, http.StatusOK)
+}
+
+// Revoke disables an api key for good. Nothing but the envelope is returned.
+//
+// swagger:operation DELETE /apikey/{id} APIKey APIKeyRevoke
+//
+// # Revokes an API key
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/APIKeyDeletedRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+//	  "404":
+//		   "$ref": "#/responses/errorResponse404"
+func (h Handlers) Revoke(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := api.GetContextValues(ctx)
+	if err != nil {
+		return api.NewShutdownError("api value missing from context")
+	}
+
+	id := api.Param(r, "id")
+
+	if err := h.APIKey.Revoke(ctx, id, v.Now); err != nil {
+		return keyError(err, id)
+	}
+
+	return api.Respond(ctx, w, nil, http.StatusOK)
+}
+
+// Query all the api keys that were not revoked
+//
+// swagger:operation GET /apikey APIKey APIKeyQuery
+//
+// # Lists the API keys
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/APIKeyListRes"
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	pagi, err := database.PaginationParams(r)
+	if err != nil {
+		return err
+	}
+
+	fs, err := database.FieldsParams(r, apikey.Fields)
+	if err != nil {
+		return err
+	}
+
+	ks, page, err := h.APIKey.Query(ctx, fs, pagi)
+	if err != nil {
+		return fmt.Errorf("unable to query for api keys: %w", err)
+	}
+
+	data, err := fs.Project(ks)
+	if err != nil {
+		return err
+	}
+
+	return database.RespondPage(ctx, w, r, pagi, data, page)
+}
+
+// QueryByID from an individual id
+//
+// swagger:operation GET /apikey/{id} APIKey APIKeyQueryById
+//
+// # Getting a single API key by ID
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/APIKeyRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+//	  "404":
+//		   "$ref": "#/responses/errorResponse404"
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := api.Param(r, "id")
+
+	fs, err := database.FieldsParams(r, apikey.Fields)
+	if err != nil {
+		return err
+	}
+
+	k, err := h.APIKey.QueryByID(ctx, id, fs)
+	if err != nil {
+		return keyError(err, id)
+	}
+
+	data, err := fs.Project([]apikey.APIKey{k})
+	if err != nil {
+		return err
+	}
+
+	return api.Respond(ctx, w, data, http.StatusOK)
+}
+
+// keyError maps the core errors for a single api key to the matching
+// request errors. Validation and database errors are passed through
+// untouched so the errors middleware can handle them.
+func keyError(err error, id string) error {
+	switch {
+	case errors.Is(err, apikey.ErrInvalidID):
+		return api.NewRequestError(err, http.StatusBadRequest)
+	case errors.Is(err, apikey.ErrNotFound):
+		return api.NewRequestError(err, http.StatusNotFound)
+	case database.IsError(err):
+		return err
+	default:
+		return fmt.Errorf("api key id[%s]: %w", id, err)
+	}
+}
@@ -0,0 +1,150 @@
+package apikeygrp_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chaitanyamaili/go_rest/models/migrations"
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/api/middleware"
+	"github.com/chaitanyamaili/go_rest/pkg/auth"
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+	"github.com/chaitanyamaili/go_rest/pkg/database/migrate"
+	v1 "github.com/chaitanyamaili/go_rest/services/rest/handlers/v1"
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite"
+)
+
+// orgUID is the org of the caller of the tests.
+const orgUID = "8d8ac610-566d-4ef0-9c22-186b2a5ed793"
+
+// roles is the role table of the policy, as in the local config.
+var roles = map[string][]string{
+	"admin":    {"builds:read", "builds:write", "apikeys:admin"},
+	"platform": {"builds:read", "tenants:admin"},
+}
+
+// identify stands in for Authenticate, every caller is the identity.
+func identify(id auth.Identity) api.Middleware {
+	return func(handler api.Handler) api.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if err := api.SetClaims(ctx, id.Subject, id.OrgUID, id.SiteUID, id.Scopes, id.Roles); err != nil {
+				return err
+			}
+			return handler(ctx, w, r)
+		}
+	}
+}
+
+// newAPI serves the version 1 routes over a migrated SQLite database of
+// its own to callers authenticated as the identity.
+func newAPI(t *testing.T, id auth.Identity) http.Handler {
+	t.Helper()
+
+	log := zap.NewNop().Sugar()
+
+	db, err := database.Open(database.Config{
+		Type: database.DialectSQLite,
+		Name: filepath.Join(t.TempDir(), "gorest.db"),
+	})
+	if err != nil {
+		t.Fatalf("opening database: %s", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	fsys, err := migrations.FS(database.DialectSQLite)
+	if err != nil {
+		t.Fatalf("loading migrations: %s", err)
+	}
+	m, err := migrate.New(migrate.Config{Log: log, DB: db, FS: fsys})
+	if err != nil {
+		t.Fatalf("constructing migrator: %s", err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("applying migrations: %s", err)
+	}
+
+	policy, err := auth.NewPolicy(roles)
+	if err != nil {
+		t.Fatalf("constructing policy: %s", err)
+	}
+
+	a := api.NewAPI(make(chan os.Signal, 1), middleware.Errors(log), identify(id), middleware.Tenant(log, &policy, v1.ScopeTenantsAdmin))
+	v1.Routes(a, v1.Config{Log: log, DB: db, RWMux: &sync.RWMutex{}, Policy: &policy})
+
+	return a
+}
+
+func TestCreateScopes(t *testing.T) {
+	admin := newAPI(t, auth.Identity{Subject: "admin-1", OrgUID: orgUID, Roles: []string{"admin"}})
+
+	tests := []struct {
+		name   string
+		scopes string
+		code   int
+	}{
+		{"no scopes", `[]`, http.StatusCreated},
+		{"held through the role", `["builds:write","apikeys:admin"]`, http.StatusCreated},
+		{"not held", `["builds:read","tenants:admin"]`, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/apikey", strings.NewReader(`{"name":"ci","scopes":`+tt.scopes+`}`))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			admin.ServeHTTP(w, r)
+
+			if w.Code != tt.code {
+				t.Fatalf("got %d, want %d: %s", w.Code, tt.code, w.Body)
+			}
+			if tt.code == http.StatusForbidden && !strings.Contains(w.Body.String(), "tenants:admin") {
+				t.Errorf("body: got %s, want the scope named", w.Body)
+			}
+		})
+	}
+}
+
+func TestCreateOwner(t *testing.T) {
+	admin := newAPI(t, auth.Identity{Subject: "admin-1", OrgUID: orgUID, Roles: []string{"admin"}})
+
+	tests := []struct {
+		name  string
+		body  string
+		owner string
+	}{
+		{"caller by default", `{"name":"ci"}`, "admin-1"},
+		{"given", `{"name":"ci","owner":"ci-runner"}`, "ci-runner"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/apikey", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			admin.ServeHTTP(w, r)
+
+			if w.Code != http.StatusCreated {
+				t.Fatalf("got %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+			}
+			var env struct {
+				Data []struct {
+					Owner string `json:"owner"`
+				} `json:"data"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&env); err != nil || len(env.Data) != 1 {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if env.Data[0].Owner != tt.owner {
+				t.Errorf("owner: got %q, want %q", env.Data[0].Owner, tt.owner)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package apikeygrp
+
+import (
+	"github.com/chaitanyamaili/go_rest/models/apikey"
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+)
+
+// swagger:response APIKeyRes
+type _ struct {
+	// in:body
+	Body struct {
+		// Success
+		//
+		Success bool `json:"success"`
+		// Timestamp
+		//
+		// example: 1639237536
+		Timestamp int64 `json:"timestamp"`
+		// Data
+		// in: body
+		Data []apikey.APIKey `json:"data"`
+	}
+}
+
+// swagger:response CreatedAPIKeyRes
+type _ struct {
+	// in:body
+	Body struct {
+		// Success
+		//
+		Success bool `json:"success"`
+		// Timestamp
+		//
+		// example: 1639237536
+		Timestamp int64 `json:"timestamp"`
+		// Data
+		// in: body
+		Data []apikey.CreatedAPIKey `json:"data"`
+	}
+}
+
+// swagger:response APIKeyDeletedRes
+type _ struct {
+	// in:body
+	Body struct {
+		// Success
+		//
+		Success bool `json:"success"`
+		// Timestamp
+		//
+		// example: 1639237536
+		Timestamp int64 `json:"timestamp"`
+	}
+}
+
+// swagger:response APIKeyListRes
+type _ struct {
+	// in:body
+	Body struct {
+		// Success
+		//
+		Success bool `json:"success"`
+		// Timestamp
+		//
+		// example: 1639237536
+		Timestamp int64 `json:"timestamp"`
+		// Data
+		// in: body
+		Data []apikey.APIKey `json:"data"`
+		// Meta
+		// in: body
+		Meta database.Page `json:"meta"`
+	}
+}
+
+// swagger:parameters APIKeyQueryById APIKeyRotate APIKeyRevoke
+type _ struct {
+	// API key ID
+	//
+	// in: path
+	// required: true
+	// enum: 1
+	// type: integer
+	ID string `json:"id"`
+}
+
+// swagger:parameters APIKeyCreate
+type _ struct {
+	// API key input Json Object
+	//
+	// in: body
+	// required: true
+	Body apikey.NewAPIKey
+}
+
+// swagger:parameters APIKeyQuery APIKeyQueryById
+type _ struct {
+	// Comma separated list of the fields to return, any of id, prefix, name,
+	// owner, scopes, expires_on, last_used_on, created_on or updated_on.
+	// Every field is returned by default.
+	//
+	// in: query
+	// required: false
+	// example: id,name,last_used_on
+	Fields string `json:"fields"`
+}
+
+// swagger:parameters APIKeyQuery APIKeyQueryById
+type _ struct {
+	// Read across every tenant instead of the org and site of the request,
+	// requires the tenants:admin scope
+	//
+	// in: query
+	// required: false
+	// example: true
+	AllTenants bool `json:"all_tenants"`
+}
+
+// swagger:parameters APIKeyCreate APIKeyRotate APIKeyRevoke
+type _ struct {
+	// Key making the request safe to retry, the response of the first
+	// request is replayed for the configured time
+	//
+	// in: header
+	// required: false
+	// example: 5f0c7c2e-9d8b-4b8e-8a57-2f1e2d2b6c11
+	IdempotencyKey string `json:"Idempotency-Key"`
+}
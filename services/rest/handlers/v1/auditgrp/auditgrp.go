@@ -0,0 +1,59 @@
+package auditgrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/chaitanyamaili/go_rest/models/audit"
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+)
+
+// Handlers manages the set of audit endpoints.
+type Handlers struct {
+	Audit audit.Core
+}
+
+// Query all the audit records
+//
+// swagger:operation GET /audit Audit AuditQuery
+//
+// # Lists who changed which build or build status and how
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/AuditListRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	pagi, err := database.PaginationParams(r)
+	if err != nil {
+		return err
+	}
+
+	filter, err := database.FilterParams(r, audit.Filters)
+	if err != nil {
+		return err
+	}
+
+	fs, err := database.FieldsParams(r, audit.Fields)
+	if err != nil {
+		return err
+	}
+
+	recs, page, err := h.Audit.Query(ctx, filter, fs, pagi)
+	if err != nil {
+		return fmt.Errorf("unable to query for audit records: %w", err)
+	}
+
+	data, err := fs.Project(recs)
+	if err != nil {
+		return err
+	}
+
+	return database.RespondPage(ctx, w, r, pagi, data, page)
+}
@@ -0,0 +1,94 @@
+package auditgrp
+
+import (
+	"github.com/chaitanyamaili/go_rest/models/audit"
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+)
+
+// swagger:response AuditListRes
+type _ struct {
+	// in:body
+	Body struct {
+		// Success
+		//
+		Success bool `json:"success"`
+		// Timestamp
+		//
+		// example: 1639237536
+		Timestamp int64 `json:"timestamp"`
+		// Data
+		// in: body
+		Data []audit.Audit `json:"data"`
+		// Meta
+		// in: body
+		Meta database.Page `json:"meta"`
+	}
+}
+
+// swagger:parameters AuditQuery
+type _ struct {
+	// Only changes to this entity or comma separated entities, build or
+	// build_status
+	//
+	// in: query
+	// required: false
+	// example: build
+	Entity string `json:"entity"`
+	// Only changes to the entity with this id or comma separated ids
+	//
+	// in: query
+	// required: false
+	// example: 4
+	EntityID string `json:"entity_id"`
+	// Only changes of this action or comma separated actions, any of
+	// create, update, delete or undelete
+	//
+	// in: query
+	// required: false
+	// example: update,delete
+	Action string `json:"action"`
+	// Only changes made by this actor, or by actors starting with a prefix
+	// ending in *
+	//
+	// in: query
+	// required: false
+	// example: user-1
+	Actor string `json:"actor"`
+	// Only changes made by the request with this tracer uid
+	//
+	// in: query
+	// required: false
+	// example: 0f8fad5b-d9cb-469f-a165-70867728950e
+	TracerUID string `json:"tracer_uid"`
+	// Only changes made at or after this RFC3339 timestamp or date
+	//
+	// in: query
+	// required: false
+	// format: date-time
+	CreatedAfter string `json:"created_after"`
+	// Only changes made before this RFC3339 timestamp or date
+	//
+	// in: query
+	// required: false
+	// format: date-time
+	CreatedBefore string `json:"created_before"`
+}
+
+// swagger:parameters AuditQuery
+type _ struct {
+	// Comma separated list of the fields to return, any of id, entity,
+	// entity_id, action, actor, tracer_uid, org_uid, site_uid, changes or
+	// created_on. Every field is returned by default.
+	//
+	// in: query
+	// required: false
+	// example: id,action,actor,changes
+	Fields string `json:"fields"`
+	// Read across every tenant instead of the org and site of the request,
+	// requires the tenants:admin scope
+	//
+	// in: query
+	// required: false
+	// example: true
+	AllTenants bool `json:"all_tenants"`
+}
@@ -44,12 +44,131 @@ func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Req
 
 	rs, err := h.Build.Create(ctx, nrs, v.Now)
 	if err != nil {
+		if errors.Is(err, build.ErrInvalidStatus) {
+			return api.NewRequestError(err, http.StatusBadRequest)
+		}
 		return err
 	}
 
+	w.Header().Set("ETag", rs.ETag())
 	return api.Respond(ctx, w, rs, http.StatusCreated)
 }
 
+// Update modifies an existing build. Only the provided fields are changed.
+//
+// swagger:operation PATCH /build/{id} Build BuildUpdate
+//
+// # Updates a Build
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/BuildRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+//	  "404":
+//		   "$ref": "#/responses/errorResponse404"
+//	  "412":
+//		   "$ref": "#/responses/errorResponse412"
+//	  "409":
+//		   "$ref": "#/responses/errorResponse409"
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := api.GetContextValues(ctx)
+	if err != nil {
+		return api.NewShutdownError("api value missing from context")
+	}
+
+	id := api.Param(r, "id")
+
+	var ub build.UpdateBuild
+	if err := api.Decode(r, &ub); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	if err := h.Build.Update(ctx, id, ub, api.IfMatch(r), v.Now); err != nil {
+		return buildError(err, id)
+	}
+
+	rs, err := h.Build.QueryByID(ctx, id, database.Fieldset{})
+	if err != nil {
+		return buildError(err, id)
+	}
+
+	w.Header().Set("ETag", rs.ETag())
+	return api.Respond(ctx, w, []build.Build{rs}, http.StatusOK)
+}
+
+// Delete soft deletes a build. Nothing but the envelope is returned.
+//
+// swagger:operation DELETE /build/{id} Build BuildDelete
+//
+// # Deletes a Build
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/BuildDeletedRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+//	  "404":
+//		   "$ref": "#/responses/errorResponse404"
+//	  "412":
+//		   "$ref": "#/responses/errorResponse412"
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := api.GetContextValues(ctx)
+	if err != nil {
+		return api.NewShutdownError("api value missing from context")
+	}
+
+	id := api.Param(r, "id")
+
+	if err := h.Build.Delete(ctx, id, api.IfMatch(r), v.Now); err != nil {
+		return buildError(err, id)
+	}
+
+	return api.Respond(ctx, w, nil, http.StatusOK)
+}
+
+// UnDelete restores a soft deleted build.
+//
+// swagger:operation POST /build/{id}/undelete Build BuildUnDelete
+//
+// # Restores a deleted Build
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/BuildRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+//	  "404":
+//		   "$ref": "#/responses/errorResponse404"
+func (h Handlers) UnDelete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := api.GetContextValues(ctx)
+	if err != nil {
+		return api.NewShutdownError("api value missing from context")
+	}
+
+	id := api.Param(r, "id")
+
+	rs, err := h.Build.UnDelete(ctx, id, v.Now)
+	if err != nil {
+		return buildError(err, id)
+	}
+
+	w.Header().Set("ETag", rs.ETag())
+	return api.Respond(ctx, w, []build.Build{rs}, http.StatusOK)
+}
+
 // Query all the build records
 //
 // swagger:operation GET /build Build BuildQuery
@@ -62,14 +181,24 @@ func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Req
 // responses:
 //
 //	  "200":
-//		   "$ref": "#/responses/BuildRes"
+//		   "$ref": "#/responses/BuildListRes"
 func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	pagi, err := database.PaginationParams(r)
 	if err != nil {
 		return err
 	}
 
-	rs, err := h.Build.Query(ctx, pagi)
+	filter, err := database.FilterParams(r, build.Filters)
+	if err != nil {
+		return err
+	}
+
+	fs, err := database.FieldsParams(r, build.Fields)
+	if err != nil {
+		return err
+	}
+
+	rs, page, err := h.Build.Query(ctx, filter, fs, pagi)
 	if err != nil {
 		switch {
 		case errors.Is(err, build.ErrNotFound):
@@ -79,7 +208,12 @@ func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		}
 	}
 
-	return api.Respond(ctx, w, rs, http.StatusOK)
+	data, err := fs.Project(rs)
+	if err != nil {
+		return err
+	}
+
+	return database.RespondPage(ctx, w, r, pagi, data, page)
 }
 
 // QueryByID from an individual id
@@ -102,7 +236,12 @@ func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Requ
 func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	id := api.Param(r, "id")
 
-	rs, err := h.Build.QueryByID(ctx, id)
+	fs, err := database.FieldsParams(r, build.Fields)
+	if err != nil {
+		return err
+	}
+
+	rs, err := h.Build.QueryByID(ctx, id, fs)
 	if err != nil {
 		switch {
 		case errors.Is(err, build.ErrInvalidID):
@@ -114,5 +253,37 @@ func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.
 		}
 	}
 
-	return api.Respond(ctx, w, []build.Build{rs}, http.StatusOK)
+	data, err := fs.Project([]build.Build{rs})
+	if err != nil {
+		return err
+	}
+
+	// The strong etag only describes the full representation, projections
+	// get a weak one derived from their data.
+	if !fs.Partial() {
+		w.Header().Set("ETag", rs.ETag())
+	}
+	return api.Respond(ctx, w, data, http.StatusOK)
+}
+
+// buildError maps the core errors for a single build to the matching
+// request errors. Validation and duplicate entry errors are passed through
+// untouched so the errors middleware can handle them.
+func buildError(err error, id string) error {
+	switch {
+	case errors.Is(err, build.ErrInvalidID), errors.Is(err, build.ErrInvalidStatus):
+		return api.NewRequestError(err, http.StatusBadRequest)
+	case errors.Is(err, build.ErrNotFound):
+		return api.NewRequestError(err, http.StatusNotFound)
+	case errors.Is(err, build.ErrPreconditionFailed):
+		return api.NewRequestError(err, http.StatusPreconditionFailed)
+	case errors.Is(err, build.ErrConflict):
+		return api.NewRequestError(err, http.StatusConflict)
+	case errors.Is(err, build.ErrInvalidTransition):
+		return api.NewRequestError(err, http.StatusConflict)
+	case database.IsError(err):
+		return err
+	default:
+		return fmt.Errorf("build id[%s]: %w", id, err)
+	}
 }
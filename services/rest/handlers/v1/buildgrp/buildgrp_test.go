@@ -0,0 +1,480 @@
+package buildgrp_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chaitanyamaili/go_rest/models/migrations"
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/api/middleware"
+	"github.com/chaitanyamaili/go_rest/pkg/auth"
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+	"github.com/chaitanyamaili/go_rest/pkg/database/migrate"
+	v1 "github.com/chaitanyamaili/go_rest/services/rest/handlers/v1"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite"
+)
+
+// Tenant the builds of the tests are written to.
+const (
+	orgUID  = "8d8ac610-566d-4ef0-9c22-186b2a5ed793"
+	siteUID = "4b4d4a3e-8f2b-4f5c-9a0d-3a1b2c3d4e5f"
+)
+
+// row is a build as stored in the database.
+type row struct {
+	Label         string     `db:"label"`
+	BuildStatusID string     `db:"build_status_id"`
+	Version       int        `db:"version"`
+	DeletedOn     *time.Time `db:"deleted_on"`
+}
+
+// openDB returns a migrated SQLite database of its own.
+func openDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	log := zap.NewNop().Sugar()
+
+	db, err := database.Open(database.Config{
+		Type: database.DialectSQLite,
+		Name: filepath.Join(t.TempDir(), "gorest.db"),
+	})
+	if err != nil {
+		t.Fatalf("opening database: %s", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	fsys, err := migrations.FS(database.DialectSQLite)
+	if err != nil {
+		t.Fatalf("loading migrations: %s", err)
+	}
+	m, err := migrate.New(migrate.Config{Log: log, DB: db, FS: fsys})
+	if err != nil {
+		t.Fatalf("constructing migrator: %s", err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("applying migrations: %s", err)
+	}
+
+	return db
+}
+
+// newAPI serves the version 1 routes over a migrated SQLite database of
+// its own, callers are not authenticated.
+func newAPI(t *testing.T) (http.Handler, *sqlx.DB) {
+	t.Helper()
+
+	log := zap.NewNop().Sugar()
+	db := openDB(t)
+
+	a := api.NewAPI(make(chan os.Signal, 1), middleware.Errors(log), middleware.Tenant(log, nil, v1.ScopeTenantsAdmin))
+	v1.Routes(a, v1.Config{Log: log, DB: db, RWMux: &sync.RWMutex{}})
+
+	return a, db
+}
+
+// roles is the role table of the policy of the authenticated routes.
+var roles = map[string][]string{
+	"ci":       {"builds:read", "builds:write", "statuses:read", "statuses:admin"},
+	"platform": {"builds:read", "statuses:read", "tenants:admin"},
+}
+
+// identify stands in for Authenticate, every caller is the identity.
+func identify(id auth.Identity) api.Middleware {
+	return func(handler api.Handler) api.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if err := api.SetClaims(ctx, id.Subject, id.OrgUID, id.SiteUID, id.Scopes, id.Roles); err != nil {
+				return err
+			}
+			return handler(ctx, w, r)
+		}
+	}
+}
+
+// newAuthAPI serves the version 1 routes over the database to callers
+// authenticated as the identity.
+func newAuthAPI(t *testing.T, db *sqlx.DB, id auth.Identity) http.Handler {
+	t.Helper()
+
+	log := zap.NewNop().Sugar()
+
+	policy, err := auth.NewPolicy(roles)
+	if err != nil {
+		t.Fatalf("constructing policy: %s", err)
+	}
+
+	a := api.NewAPI(make(chan os.Signal, 1), middleware.Errors(log), identify(id), middleware.Tenant(log, &policy, v1.ScopeTenantsAdmin))
+	v1.Routes(a, v1.Config{Log: log, DB: db, RWMux: &sync.RWMutex{}, Policy: &policy})
+
+	return a
+}
+
+// send sends a request as the tenant of the tests with the extra headers.
+func send(h http.Handler, method string, target string, body string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("org_uid", orgUID)
+	r.Header.Set("site_uid", siteUID)
+	for name, vals := range header {
+		r.Header[name] = vals
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+// sendShared sends a request without tenant headers, to the default tenant
+// holding the shared rows.
+func sendShared(h http.Handler, method string, target string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+// do sends a request as the tenant of the tests and decodes the data of
+// the response into data, when given.
+func do(t *testing.T, h http.Handler, method string, target string, body string, data interface{}) int {
+	t.Helper()
+
+	w := send(h, method, target, body, nil)
+
+	if data != nil && w.Code < http.StatusBadRequest {
+		env := struct {
+			Data interface{} `json:"data"`
+		}{Data: data}
+		if err := json.NewDecoder(w.Body).Decode(&env); err != nil {
+			t.Fatalf("%s %s: decoding response: %s", method, target, err)
+		}
+	}
+
+	return w.Code
+}
+
+// create inserts a processing build and returns its id.
+func create(t *testing.T, h http.Handler) string {
+	t.Helper()
+
+	var b struct {
+		ID string `json:"id"`
+	}
+	body := `{"uuid":"build-1","label":"build-1","commit_sha":"1234567","build_status_alias":"processing"}`
+	if code := do(t, h, http.MethodPost, "/v1/build", body, &b); code != http.StatusCreated {
+		t.Fatalf("creating build: got %d, want %d", code, http.StatusCreated)
+	}
+
+	return b.ID
+}
+
+// read loads a build straight from the database.
+func read(t *testing.T, db *sqlx.DB, id string) row {
+	t.Helper()
+
+	var r row
+	if err := db.Get(&r, `SELECT label, build_status_id, version, deleted_on FROM build WHERE id = ?`, id); err != nil {
+		t.Fatalf("reading build id[%s]: %s", id, err)
+	}
+
+	return r
+}
+
+// statusID returns the id of the build status with the alias.
+func statusID(t *testing.T, db *sqlx.DB, alias string) string {
+	t.Helper()
+
+	var id string
+	if err := db.Get(&id, `SELECT id FROM build_status WHERE alias = ?`, alias); err != nil {
+		t.Fatalf("reading build status %s: %s", alias, err)
+	}
+
+	return id
+}
+
+func TestUpdate(t *testing.T) {
+	h, db := newAPI(t)
+	id := create(t, h)
+	before := read(t, db, id)
+
+	body := `{"label":"build-1-renamed","build_status_alias":"success"}`
+	if code := do(t, h, http.MethodPatch, "/v1/build/"+id, body, nil); code != http.StatusOK {
+		t.Fatalf("got %d, want %d", code, http.StatusOK)
+	}
+
+	after := read(t, db, id)
+	if after.Label != "build-1-renamed" {
+		t.Errorf("label: got %q, want %q", after.Label, "build-1-renamed")
+	}
+	if want := statusID(t, db, "success"); after.BuildStatusID != want {
+		t.Errorf("build_status_id: got %s, want %s", after.BuildStatusID, want)
+	}
+	if after.Version != before.Version+1 {
+		t.Errorf("version: got %d, want %d", after.Version, before.Version+1)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	h, db := newAPI(t)
+	id := create(t, h)
+	before := read(t, db, id)
+
+	if code := do(t, h, http.MethodDelete, "/v1/build/"+id, "", nil); code != http.StatusOK {
+		t.Fatalf("got %d, want %d", code, http.StatusOK)
+	}
+
+	after := read(t, db, id)
+	if after.DeletedOn == nil {
+		t.Error("deleted_on: got null, want the time of the delete")
+	}
+	if after.Version != before.Version+1 {
+		t.Errorf("version: got %d, want %d", after.Version, before.Version+1)
+	}
+	if code := do(t, h, http.MethodGet, "/v1/build/"+id, "", nil); code != http.StatusNotFound {
+		t.Errorf("reading the deleted build: got %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestUnDelete(t *testing.T) {
+	h, db := newAPI(t)
+	id := create(t, h)
+
+	if code := do(t, h, http.MethodDelete, "/v1/build/"+id, "", nil); code != http.StatusOK {
+		t.Fatalf("deleting: got %d, want %d", code, http.StatusOK)
+	}
+	before := read(t, db, id)
+
+	if code := do(t, h, http.MethodPost, "/v1/build/"+id+"/undelete", "", nil); code != http.StatusOK {
+		t.Fatalf("got %d, want %d", code, http.StatusOK)
+	}
+
+	after := read(t, db, id)
+	if after.DeletedOn != nil {
+		t.Errorf("deleted_on: got %s, want null", after.DeletedOn)
+	}
+	if after.Version != before.Version+1 {
+		t.Errorf("version: got %d, want %d", after.Version, before.Version+1)
+	}
+}
+
+func TestUnknownID(t *testing.T) {
+	h, db := newAPI(t)
+
+	var n int
+	if err := db.Get(&n, `SELECT count(*) FROM build`); err != nil {
+		t.Fatalf("counting builds: %s", err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+	}{
+		{"update", http.MethodPatch, "/v1/build/9999", `{"label":"missing"}`},
+		{"delete", http.MethodDelete, "/v1/build/9999", ""},
+		{"undelete", http.MethodPost, "/v1/build/9999/undelete", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := do(t, h, tt.method, tt.target, tt.body, nil); code != http.StatusNotFound {
+				t.Errorf("got %d, want %d", code, http.StatusNotFound)
+			}
+		})
+	}
+
+	var after int
+	if err := db.Get(&after, `SELECT count(*) FROM build`); err != nil {
+		t.Fatalf("counting builds: %s", err)
+	}
+	if after != n {
+		t.Errorf("builds: got %d, want %d", after, n)
+	}
+}
+
+func TestQueryByIDETag(t *testing.T) {
+	h, _ := newAPI(t)
+	id := create(t, h)
+
+	etag := func(query string) string {
+		w := send(h, http.MethodGet, "/v1/build/"+id+query, "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: got %d, want %d", query, w.Code, http.StatusOK)
+		}
+		return w.Header().Get("ETag")
+	}
+
+	// The full representation is tagged with its version, projections get
+	// a weak tag of their own.
+	if got, want := etag(""), api.ETag(id, 1); got != want {
+		t.Errorf("full: got %q, want %q", got, want)
+	}
+	for _, query := range []string{"?fields=id,label", "?include=status"} {
+		if got := etag(query); !strings.HasPrefix(got, "W/") {
+			t.Errorf("%s: got %q, want a weak tag", query, got)
+		}
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	h, db := newAPI(t)
+	id := create(t, h)
+
+	// The build is at its first version, the tags of a compressed read of
+	// it hold as well.
+	tests := []struct {
+		name    string
+		ifMatch string
+		code    int
+	}{
+		{"stale", api.ETag(id, 0), http.StatusPreconditionFailed},
+		{"weak", "W/" + api.ETag(id, 1), http.StatusPreconditionFailed},
+		{"current", api.ETag(id, 1), http.StatusOK},
+		{"compressed", api.EncodedETag(api.ETag(id, 2), "gzip"), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := read(t, db, id)
+
+			w := send(h, http.MethodPatch, "/v1/build/"+id, `{"label":"`+tt.name+`"}`, http.Header{"If-Match": {tt.ifMatch}})
+			if w.Code != tt.code {
+				t.Fatalf("got %d, want %d", w.Code, tt.code)
+			}
+
+			after := read(t, db, id)
+			if changed := after.Version != before.Version; changed != (tt.code == http.StatusOK) {
+				t.Errorf("version: got %d, was %d", after.Version, before.Version)
+			}
+		})
+	}
+
+	if w := send(h, http.MethodDelete, "/v1/build/"+id, "", http.Header{"If-Match": {api.ETag(id, 1)}}); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("stale delete: got %d, want %d", w.Code, http.StatusPreconditionFailed)
+	}
+	if r := read(t, db, id); r.DeletedOn != nil {
+		t.Error("stale delete: the build was deleted")
+	}
+}
+
+func TestUnauthenticatedTenant(t *testing.T) {
+	h, db := newAPI(t)
+
+	// Without authentication, requests without tenant headers act on the
+	// default tenant as they did before tenants existed.
+	body := `{"uuid":"build-shared","label":"build-shared","commit_sha":"1234567","build_status_alias":"processing"}`
+	if w := sendShared(h, http.MethodPost, "/v1/build", body); w.Code != http.StatusCreated {
+		t.Fatalf("create without headers: got %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+
+	var org string
+	if err := db.Get(&org, `SELECT org_uid FROM build WHERE label = ?`, "build-shared"); err != nil {
+		t.Fatalf("reading build: %s", err)
+	}
+	if org != "" {
+		t.Errorf("org_uid: got %q, want the default tenant", org)
+	}
+
+	// Nobody is known to be an admin, reads stay within a tenant.
+	if w := send(h, http.MethodGet, "/v1/build?all_tenants=true", "", nil); w.Code != http.StatusForbidden {
+		t.Errorf("all tenants: got %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestDeleteReferencedStatus(t *testing.T) {
+	h, db := newAPI(t)
+	id := create(t, h)
+
+	// Processing builds move on to success, it can't go away.
+	success := statusID(t, db, "success")
+	if w := sendShared(h, http.MethodDelete, "/v1/buildstatus/"+success, ""); w.Code != http.StatusConflict {
+		t.Fatalf("got %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+	}
+
+	var deleted int
+	if err := db.Get(&deleted, `SELECT count(*) FROM build_status WHERE id = ? AND deleted_on IS NOT NULL`, success); err != nil {
+		t.Fatalf("reading build status: %s", err)
+	}
+	if deleted != 0 {
+		t.Error("the build status was deleted")
+	}
+	if code := do(t, h, http.MethodPatch, "/v1/build/"+id, `{"build_status_alias":"success"}`, nil); code != http.StatusOK {
+		t.Errorf("moving the build to success: got %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestTenantScoping(t *testing.T) {
+	db := openDB(t)
+
+	const otherOrgUID = "1c9e4a52-7b3f-4d8e-a6f1-2b3c4d5e6f70"
+
+	owner := newAuthAPI(t, db, auth.Identity{Subject: "ci-1", OrgUID: orgUID, SiteUID: siteUID, Roles: []string{"ci"}})
+	other := newAuthAPI(t, db, auth.Identity{Subject: "ci-2", OrgUID: otherOrgUID, SiteUID: siteUID, Roles: []string{"ci"}})
+	shared := newAuthAPI(t, db, auth.Identity{Subject: "ci-3", Roles: []string{"ci"}})
+	platform := newAuthAPI(t, db, auth.Identity{Subject: "platform-1", Roles: []string{"platform"}})
+
+	// The tenant headers sent by do match the credentials of the owner.
+	id := create(t, owner)
+	if code := do(t, owner, http.MethodGet, "/v1/build/"+id, "", nil); code != http.StatusOK {
+		t.Fatalf("owner read: got %d, want %d", code, http.StatusOK)
+	}
+	success := statusID(t, db, "success")
+
+	tests := []struct {
+		name   string
+		h      http.Handler
+		method string
+		target string
+		body   string
+		header http.Header
+		code   int
+	}{
+		{"read of another tenant", other, http.MethodGet, "/v1/build/" + id, "", nil, http.StatusNotFound},
+		{"update of another tenant", other, http.MethodPatch, "/v1/build/" + id, `{"label":"taken"}`, nil, http.StatusNotFound},
+		{"delete of another tenant", other, http.MethodDelete, "/v1/build/" + id, "", nil, http.StatusNotFound},
+		{"org header of another tenant", other, http.MethodGet, "/v1/build/" + id, "", http.Header{"Org_uid": {orgUID}}, http.StatusForbidden},
+		{"all tenants without the scope", other, http.MethodGet, "/v1/build?all_tenants=true", "", nil, http.StatusForbidden},
+		{"create in the shared tenant", shared, http.MethodPost, "/v1/build", `{"uuid":"b","label":"b","commit_sha":"1234567","build_status_alias":"processing"}`, nil, http.StatusForbidden},
+		{"update of a shared status", shared, http.MethodPatch, "/v1/buildstatus/" + success, `{"name":"Done"}`, nil, http.StatusForbidden},
+		{"update of a shared status from a tenant", owner, http.MethodPatch, "/v1/buildstatus/" + success, `{"name":"Done"}`, nil, http.StatusNotFound},
+		{"read across tenants as an admin", platform, http.MethodGet, "/v1/build/" + id + "?all_tenants=true", "", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			for name, vals := range tt.header {
+				r.Header[name] = vals
+			}
+			w := httptest.NewRecorder()
+			tt.h.ServeHTTP(w, r)
+
+			if w.Code != tt.code {
+				t.Errorf("got %d, want %d: %s", w.Code, tt.code, w.Body)
+			}
+		})
+	}
+
+	// Nothing was changed by the refused writes.
+	if r := read(t, db, id); r.Label != "build-1" || r.DeletedOn != nil || r.Version != 1 {
+		t.Errorf("build: got %+v, want it untouched", r)
+	}
+	var name string
+	if err := db.Get(&name, `SELECT name FROM build_status WHERE id = ?`, success); err != nil {
+		t.Fatalf("reading build status: %s", err)
+	}
+	if name == "Done" {
+		t.Error("shared build status: got renamed")
+	}
+}
@@ -1,6 +1,9 @@
 package buildgrp
 
-import "github.com/chaitanyamaili/go_rest/models/build"
+import (
+	"github.com/chaitanyamaili/go_rest/models/build"
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+)
 
 // swagger:response BuildRes
 type _ struct {
@@ -19,7 +22,41 @@ type _ struct {
 	}
 }
 
-// swagger:parameters BuildQueryById
+// swagger:response BuildDeletedRes
+type _ struct {
+	// in:body
+	Body struct {
+		// Success
+		//
+		Success bool `json:"success"`
+		// Timestamp
+		//
+		// example: 1639237536
+		Timestamp int64 `json:"timestamp"`
+	}
+}
+
+// swagger:response BuildListRes
+type _ struct {
+	// in:body
+	Body struct {
+		// Success
+		//
+		Success bool `json:"success"`
+		// Timestamp
+		//
+		// example: 1639237536
+		Timestamp int64 `json:"timestamp"`
+		// Data
+		// in: body
+		Data []build.Build `json:"data"`
+		// Meta
+		// in: body
+		Meta database.Page `json:"meta"`
+	}
+}
+
+// swagger:parameters BuildQueryById BuildUpdate BuildDelete BuildUnDelete
 type _ struct {
 	// Build ID
 	//
@@ -38,3 +75,121 @@ type _ struct {
 	// required: true
 	Body build.NewBuild
 }
+
+// swagger:parameters BuildUpdate
+type _ struct {
+	// Build update Json Object
+	//
+	// in: body
+	// required: true
+	Body build.UpdateBuild
+}
+
+// swagger:parameters BuildQuery
+type _ struct {
+	// Only builds with this label, a comma separated list of labels or
+	// a label prefix ending in *
+	//
+	// in: query
+	// required: false
+	// example: release-*
+	Label string `json:"label"`
+	// Only builds of this commit sha, or of a sha prefix ending in *
+	//
+	// in: query
+	// required: false
+	// example: 3f2a9c*
+	CommitSha string `json:"commit_sha"`
+	// Only builds in this build status alias or comma separated aliases
+	//
+	// in: query
+	// required: false
+	// example: success,failed
+	Status string `json:"status"`
+	// Only builds created at or after this RFC3339 timestamp or date
+	//
+	// in: query
+	// required: false
+	// format: date-time
+	CreatedAfter string `json:"created_after"`
+	// Only builds created before this RFC3339 timestamp or date
+	//
+	// in: query
+	// required: false
+	// format: date-time
+	CreatedBefore string `json:"created_before"`
+	// Only builds updated at or after this RFC3339 timestamp or date
+	//
+	// in: query
+	// required: false
+	// format: date-time
+	UpdatedAfter string `json:"updated_after"`
+}
+
+// swagger:parameters BuildQuery BuildQueryById
+type _ struct {
+	// Comma separated list of the fields to return, any of id, uuid, label,
+	// commit_sha, build_status_id, created_on, updated_on or the status
+	// relation. Every field is returned by default.
+	//
+	// in: query
+	// required: false
+	// example: id,label,status
+	Fields string `json:"fields"`
+	// Comma separated list of the relations to embed. The status is
+	// embedded by default unless fields are requested.
+	//
+	// in: query
+	// required: false
+	// enum: status
+	Include string `json:"include"`
+}
+
+// swagger:parameters BuildQuery BuildQueryById
+type _ struct {
+	// Read across every tenant instead of the org and site of the request,
+	// requires the tenants:admin scope
+	//
+	// in: query
+	// required: false
+	// example: true
+	AllTenants bool `json:"all_tenants"`
+}
+
+// swagger:parameters BuildCreate BuildUpdate BuildDelete BuildUnDelete
+type _ struct {
+	// Key making the request safe to retry, the response of the first
+	// request is replayed for the configured time
+	//
+	// in: header
+	// required: false
+	// example: 5f0c7c2e-9d8b-4b8e-8a57-2f1e2d2b6c11
+	IdempotencyKey string `json:"Idempotency-Key"`
+}
+
+// swagger:parameters BuildUpdate BuildDelete
+type _ struct {
+	// ETag the change only applies to, the request fails with a 412 when
+	// it no longer matches
+	//
+	// in: header
+	// required: false
+	// example: "1-3"
+	IfMatch string `json:"If-Match"`
+}
+
+// swagger:parameters BuildQuery BuildQueryById
+type _ struct {
+	// ETag of the copy the client holds, a 304 without a body is sent back
+	// when it is still current
+	//
+	// in: header
+	// required: false
+	IfNoneMatch string `json:"If-None-Match"`
+	// Last-Modified time of the copy the client holds, only used without
+	// If-None-Match
+	//
+	// in: header
+	// required: false
+	IfModifiedSince string `json:"If-Modified-Since"`
+}
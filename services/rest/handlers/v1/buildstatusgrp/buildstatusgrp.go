@@ -0,0 +1,328 @@
+package buildstatusgrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/chaitanyamaili/go_rest/models/buildstatus"
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+)
+
+// Handlers manages the set of build status endpoints.
+type Handlers struct {
+	BuildStatus buildstatus.Core
+}
+
+// Create adds a build status to the system.
+//
+// swagger:operation POST /buildstatus BuildStatus BuildStatusCreate
+//
+// # Creates a new Build Status
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "201":
+//		   "$ref": "#/responses/BuildStatusRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+//	  "409":
+//		   "$ref": "#/responses/errorResponse409"
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := api.GetContextValues(ctx)
+	if err != nil {
+		return api.NewShutdownError("api value missing from context")
+	}
+
+	var nbs buildstatus.NewBuildStatus
+	if err := api.Decode(r, &nbs); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	bs, err := h.BuildStatus.Create(ctx, nbs, v.Now)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", bs.ETag())
+	return api.Respond(ctx, w, []buildstatus.BuildStatus{bs}, http.StatusCreated)
+}
+
+// Update modifies an existing build status. An alias other statuses allow
+// transitions to can't be renamed.
+//
+// swagger:operation PATCH /buildstatus/{id} BuildStatus BuildStatusUpdate
+//
+// # Updates a Build Status
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/BuildStatusRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+//	  "404":
+//		   "$ref": "#/responses/errorResponse404"
+//	  "412":
+//		   "$ref": "#/responses/errorResponse412"
+//	  "409":
+//		   "$ref": "#/responses/errorResponse409"
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := api.GetContextValues(ctx)
+	if err != nil {
+		return api.NewShutdownError("api value missing from context")
+	}
+
+	id := api.Param(r, "id")
+
+	var ubs buildstatus.UpdateBuildStatus
+	if err := api.Decode(r, &ubs); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	if err := h.BuildStatus.Update(ctx, id, ubs, api.IfMatch(r), v.Now); err != nil {
+		return statusError(err, id)
+	}
+
+	bs, err := h.BuildStatus.QueryByID(ctx, id, database.Fieldset{})
+	if err != nil {
+		return statusError(err, id)
+	}
+
+	w.Header().Set("ETag", bs.ETag())
+	return api.Respond(ctx, w, []buildstatus.BuildStatus{bs}, http.StatusOK)
+}
+
+// Delete soft deletes a build status. Nothing but the envelope is returned.
+// Statuses that other statuses allow a transition to are refused with a 409.
+//
+// swagger:operation DELETE /buildstatus/{id} BuildStatus BuildStatusDelete
+//
+// # Deletes a Build Status
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/BuildStatusDeletedRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+//	  "404":
+//		   "$ref": "#/responses/errorResponse404"
+//	  "409":
+//		   "$ref": "#/responses/errorResponse409"
+//	  "412":
+//		   "$ref": "#/responses/errorResponse412"
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := api.GetContextValues(ctx)
+	if err != nil {
+		return api.NewShutdownError("api value missing from context")
+	}
+
+	id := api.Param(r, "id")
+
+	if err := h.BuildStatus.Delete(ctx, id, api.IfMatch(r), v.Now); err != nil {
+		return statusError(err, id)
+	}
+
+	return api.Respond(ctx, w, nil, http.StatusOK)
+}
+
+// UnDelete restores a soft deleted build status.
+//
+// swagger:operation POST /buildstatus/{id}/undelete BuildStatus BuildStatusUnDelete
+//
+// # Restores a deleted Build Status
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/BuildStatusRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+//	  "404":
+//		   "$ref": "#/responses/errorResponse404"
+func (h Handlers) UnDelete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := api.GetContextValues(ctx)
+	if err != nil {
+		return api.NewShutdownError("api value missing from context")
+	}
+
+	id := api.Param(r, "id")
+
+	bs, err := h.BuildStatus.UnDelete(ctx, id, v.Now)
+	if err != nil {
+		return statusError(err, id)
+	}
+
+	w.Header().Set("ETag", bs.ETag())
+	return api.Respond(ctx, w, []buildstatus.BuildStatus{bs}, http.StatusOK)
+}
+
+// Query all the build status records
+//
+// swagger:operation GET /buildstatus BuildStatus BuildStatusQuery
+//
+// # Lists the build statuses
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/BuildStatusListRes"
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	pagi, err := database.PaginationParams(r)
+	if err != nil {
+		return err
+	}
+
+	fs, err := database.FieldsParams(r, buildstatus.Fields)
+	if err != nil {
+		return err
+	}
+
+	bss, page, err := h.BuildStatus.Query(ctx, fs, pagi)
+	if err != nil {
+		switch {
+		case errors.Is(err, buildstatus.ErrNotFound):
+			return api.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("unable to query for build statuses: %w", err)
+		}
+	}
+
+	data, err := fs.Project(bss)
+	if err != nil {
+		return err
+	}
+
+	return database.RespondPage(ctx, w, r, pagi, data, page)
+}
+
+// QueryByID from an individual id
+//
+// swagger:operation GET /buildstatus/{id} BuildStatus BuildStatusQueryById
+//
+// # Getting a single build status by ID
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/BuildStatusRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+//	  "404":
+//		   "$ref": "#/responses/errorResponse404"
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := api.Param(r, "id")
+
+	fs, err := database.FieldsParams(r, buildstatus.Fields)
+	if err != nil {
+		return err
+	}
+
+	bs, err := h.BuildStatus.QueryByID(ctx, id, fs)
+	if err != nil {
+		return statusError(err, id)
+	}
+
+	data, err := fs.Project([]buildstatus.BuildStatus{bs})
+	if err != nil {
+		return err
+	}
+
+	// The strong etag only describes the full representation, projections
+	// get a weak one derived from their data.
+	if !fs.Partial() {
+		w.Header().Set("ETag", bs.ETag())
+	}
+	return api.Respond(ctx, w, data, http.StatusOK)
+}
+
+// QueryByAlias from an individual alias
+//
+// swagger:operation GET /buildstatus/alias/{alias} BuildStatus BuildStatusQueryByAlias
+//
+// # Getting a single build status by alias
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/BuildStatusRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+//	  "404":
+//		   "$ref": "#/responses/errorResponse404"
+func (h Handlers) QueryByAlias(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	alias := api.Param(r, "alias")
+
+	fs, err := database.FieldsParams(r, buildstatus.Fields)
+	if err != nil {
+		return err
+	}
+
+	bs, err := h.BuildStatus.QueryByAlias(ctx, alias, fs)
+	if err != nil {
+		switch {
+		case errors.Is(err, buildstatus.ErrInvalidAlias):
+			return api.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, buildstatus.ErrNotFound):
+			return api.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("build status alias[%s]: %w", alias, err)
+		}
+	}
+
+	data, err := fs.Project([]buildstatus.BuildStatus{bs})
+	if err != nil {
+		return err
+	}
+
+	// The strong etag only describes the full representation, projections
+	// get a weak one derived from their data.
+	if !fs.Partial() {
+		w.Header().Set("ETag", bs.ETag())
+	}
+	return api.Respond(ctx, w, data, http.StatusOK)
+}
+
+// statusError maps the core errors for a single build status to the
+// matching request errors. Validation and duplicate entry errors are
+// passed through untouched so the errors middleware can handle them.
+func statusError(err error, id string) error {
+	switch {
+	case errors.Is(err, buildstatus.ErrInvalidID):
+		return api.NewRequestError(err, http.StatusBadRequest)
+	case errors.Is(err, buildstatus.ErrNotFound):
+		return api.NewRequestError(err, http.StatusNotFound)
+	case errors.Is(err, buildstatus.ErrPreconditionFailed):
+		return api.NewRequestError(err, http.StatusPreconditionFailed)
+	case errors.Is(err, buildstatus.ErrConflict), errors.Is(err, buildstatus.ErrAliasInUse):
+		return api.NewRequestError(err, http.StatusConflict)
+	case database.IsError(err):
+		return err
+	default:
+		return fmt.Errorf("build status id[%s]: %w", id, err)
+	}
+}
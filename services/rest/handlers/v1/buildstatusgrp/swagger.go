@@ -0,0 +1,157 @@
+package buildstatusgrp
+
+import (
+	"github.com/chaitanyamaili/go_rest/models/buildstatus"
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+)
+
+// swagger:response BuildStatusRes
+type _ struct {
+	// in:body
+	Body struct {
+		// Success
+		//
+		Success bool `json:"success"`
+		// Timestamp
+		//
+		// example: 1639237536
+		Timestamp int64 `json:"timestamp"`
+		// Data
+		// in: body
+		Data []buildstatus.BuildStatus `json:"data"`
+	}
+}
+
+// swagger:response BuildStatusDeletedRes
+type _ struct {
+	// in:body
+	Body struct {
+		// Success
+		//
+		Success bool `json:"success"`
+		// Timestamp
+		//
+		// example: 1639237536
+		Timestamp int64 `json:"timestamp"`
+	}
+}
+
+// swagger:response BuildStatusListRes
+type _ struct {
+	// in:body
+	Body struct {
+		// Success
+		//
+		Success bool `json:"success"`
+		// Timestamp
+		//
+		// example: 1639237536
+		Timestamp int64 `json:"timestamp"`
+		// Data
+		// in: body
+		Data []buildstatus.BuildStatus `json:"data"`
+		// Meta
+		// in: body
+		Meta database.Page `json:"meta"`
+	}
+}
+
+// swagger:parameters BuildStatusQueryById BuildStatusUpdate BuildStatusDelete BuildStatusUnDelete
+type _ struct {
+	// Build Status ID
+	//
+	// in: path
+	// required: true
+	// enum: 1
+	// type: integer
+	ID string `json:"id"`
+}
+
+// swagger:parameters BuildStatusQueryByAlias
+type _ struct {
+	// Build Status alias
+	//
+	// in: path
+	// required: true
+	// enum: processing
+	Alias string `json:"alias"`
+}
+
+// swagger:parameters BuildStatusCreate
+type _ struct {
+	// Build Status input Json Object
+	//
+	// in: body
+	// required: true
+	Body buildstatus.NewBuildStatus
+}
+
+// swagger:parameters BuildStatusUpdate
+type _ struct {
+	// Build Status update Json Object
+	//
+	// in: body
+	// required: true
+	Body buildstatus.UpdateBuildStatus
+}
+
+// swagger:parameters BuildStatusQuery BuildStatusQueryById BuildStatusQueryByAlias
+type _ struct {
+	// Comma separated list of the fields to return, any of id, alias, name,
+	// is_terminal, allowed_next, created_on or updated_on. Every field is
+	// returned by default.
+	//
+	// in: query
+	// required: false
+	// example: id,alias,name
+	Fields string `json:"fields"`
+}
+
+// swagger:parameters BuildStatusQuery BuildStatusQueryById BuildStatusQueryByAlias
+type _ struct {
+	// Read across every tenant instead of the org and site of the request,
+	// requires the tenants:admin scope
+	//
+	// in: query
+	// required: false
+	// example: true
+	AllTenants bool `json:"all_tenants"`
+}
+
+// swagger:parameters BuildStatusCreate BuildStatusUpdate BuildStatusDelete BuildStatusUnDelete
+type _ struct {
+	// Key making the request safe to retry, the response of the first
+	// request is replayed for the configured time
+	//
+	// in: header
+	// required: false
+	// example: 5f0c7c2e-9d8b-4b8e-8a57-2f1e2d2b6c11
+	IdempotencyKey string `json:"Idempotency-Key"`
+}
+
+// swagger:parameters BuildStatusUpdate BuildStatusDelete
+type _ struct {
+	// ETag the change only applies to, the request fails with a 412 when
+	// it no longer matches
+	//
+	// in: header
+	// required: false
+	// example: "1-3"
+	IfMatch string `json:"If-Match"`
+}
+
+// swagger:parameters BuildStatusQuery BuildStatusQueryById BuildStatusQueryByAlias
+type _ struct {
+	// ETag of the copy the client holds, a 304 without a body is sent back
+	// when it is still current
+	//
+	// in: header
+	// required: false
+	IfNoneMatch string `json:"If-None-Match"`
+	// Last-Modified time of the copy the client holds, only used without
+	// If-None-Match
+	//
+	// in: header
+	// required: false
+	IfModifiedSince string `json:"If-Modified-Since"`
+}
@@ -4,18 +4,57 @@ import (
 	"net/http"
 	"sync"
 
+	"github.com/chaitanyamaili/go_rest/models/apikey"
+	"github.com/chaitanyamaili/go_rest/models/audit"
 	"github.com/chaitanyamaili/go_rest/models/build"
+	"github.com/chaitanyamaili/go_rest/models/buildstatus"
+	"github.com/chaitanyamaili/go_rest/models/webhook"
 	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/api/middleware"
+	"github.com/chaitanyamaili/go_rest/pkg/auth"
+	"github.com/chaitanyamaili/go_rest/services/rest/handlers/v1/apikeygrp"
+	"github.com/chaitanyamaili/go_rest/services/rest/handlers/v1/auditgrp"
 	"github.com/chaitanyamaili/go_rest/services/rest/handlers/v1/buildgrp"
+	"github.com/chaitanyamaili/go_rest/services/rest/handlers/v1/buildstatusgrp"
+	"github.com/chaitanyamaili/go_rest/services/rest/handlers/v1/webhookgrp"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
 
+// Set of scopes the version 1 routes require.
+const (
+	ScopeBuildsRead    = "builds:read"
+	ScopeBuildsWrite   = "builds:write"
+	ScopeStatusesRead  = "statuses:read"
+	ScopeStatusesAdmin = "statuses:admin"
+	ScopeAPIKeysAdmin  = "apikeys:admin"
+	ScopeAuditRead     = "audit:read"
+	ScopeWebhooksAdmin = "webhooks:admin"
+
+	// ScopeTenantsAdmin lets platform admins act on another org or read
+	// across tenants.
+	ScopeTenantsAdmin = "tenants:admin"
+)
+
+// Set of Cache-Control policies of the read routes. Builds change all the
+// time so clients revalidate them on every read, build statuses rarely do.
+// API keys, the audit trail and webhooks are never cached.
+const (
+	cacheRevalidate = "private, no-cache"
+	cacheStatuses   = "private, max-age=300"
+	cacheNever      = "no-store"
+)
+
 // Config contains all the mandatory systems required by handlers.
 type Config struct {
 	Log   *zap.SugaredLogger
 	DB    *sqlx.DB
 	RWMux *sync.RWMutex
+	// Policy authorizes the routes, nil when callers are not authenticated.
+	Policy *auth.Policy
+	// Idempotency handles the Idempotency-Key header of the mutating
+	// routes, nil when the header is ignored.
+	Idempotency api.Middleware
 }
 
 // Routes binds all the version 1 routes.
@@ -26,7 +65,80 @@ func Routes(api *api.API, cfg Config) {
 	bd := buildgrp.Handlers{
 		Build: build.NewCore(cfg.Log, cfg.DB, cfg.RWMux),
 	}
-	api.Handle(http.MethodPost, "/v1/build", bd.Create)
-	api.Handle(http.MethodGet, "/v1/build", bd.Query)
-	api.Handle(http.MethodGet, "/v1/build/:id", bd.QueryByID)
+	api.Handle(http.MethodPost, "/v1/build", bd.Create, mutating(cfg, ScopeBuildsWrite)...)
+	api.Handle(http.MethodGet, "/v1/build", bd.Query, cached(cacheRevalidate, authorize(cfg, ScopeBuildsRead))...)
+	api.Handle(http.MethodGet, "/v1/build/:id", bd.QueryByID, cached(cacheRevalidate, authorize(cfg, ScopeBuildsRead))...)
+	api.Handle(http.MethodPatch, "/v1/build/:id", bd.Update, mutating(cfg, ScopeBuildsWrite)...)
+	api.Handle(http.MethodDelete, "/v1/build/:id", bd.Delete, mutating(cfg, ScopeBuildsWrite)...)
+	api.Handle(http.MethodPost, "/v1/build/:id/undelete", bd.UnDelete, mutating(cfg, ScopeBuildsWrite)...)
+
+	// -------------------------------------------------------------------
+	// Build Status
+	// -------------------------------------------------------------------
+	bs := buildstatusgrp.Handlers{
+		BuildStatus: buildstatus.NewCore(cfg.Log, cfg.DB, cfg.RWMux),
+	}
+	api.Handle(http.MethodPost, "/v1/buildstatus", bs.Create, mutating(cfg, ScopeStatusesAdmin)...)
+	api.Handle(http.MethodGet, "/v1/buildstatus", bs.Query, cached(cacheStatuses, authorize(cfg, ScopeStatusesRead))...)
+	api.Handle(http.MethodGet, "/v1/buildstatus/:id", bs.QueryByID, cached(cacheStatuses, authorize(cfg, ScopeStatusesRead))...)
+	api.Handle(http.MethodGet, "/v1/buildstatus/alias/:alias", bs.QueryByAlias, cached(cacheStatuses, authorize(cfg, ScopeStatusesRead))...)
+	api.Handle(http.MethodPatch, "/v1/buildstatus/:id", bs.Update, mutating(cfg, ScopeStatusesAdmin)...)
+	api.Handle(http.MethodDelete, "/v1/buildstatus/:id", bs.Delete, mutating(cfg, ScopeStatusesAdmin)...)
+	api.Handle(http.MethodPost, "/v1/buildstatus/:id/undelete", bs.UnDelete, mutating(cfg, ScopeStatusesAdmin)...)
+
+	// -------------------------------------------------------------------
+	// API Key
+	// -------------------------------------------------------------------
+	ak := apikeygrp.Handlers{
+		APIKey: apikey.NewCore(cfg.Log, cfg.DB, cfg.RWMux),
+		Policy: cfg.Policy,
+	}
+	api.Handle(http.MethodPost, "/v1/apikey", ak.Create, mutating(cfg, ScopeAPIKeysAdmin)...)
+	api.Handle(http.MethodGet, "/v1/apikey", ak.Query, cached(cacheNever, authorize(cfg, ScopeAPIKeysAdmin))...)
+	api.Handle(http.MethodGet, "/v1/apikey/:id", ak.QueryByID, cached(cacheNever, authorize(cfg, ScopeAPIKeysAdmin))...)
+	api.Handle(http.MethodPost, "/v1/apikey/:id/rotate", ak.Rotate, mutating(cfg, ScopeAPIKeysAdmin)...)
+	api.Handle(http.MethodDelete, "/v1/apikey/:id", ak.Revoke, mutating(cfg, ScopeAPIKeysAdmin)...)
+
+	// -------------------------------------------------------------------
+	// Audit
+	// -------------------------------------------------------------------
+	au := auditgrp.Handlers{
+		Audit: audit.NewCore(cfg.Log, cfg.DB, cfg.RWMux),
+	}
+	api.Handle(http.MethodGet, "/v1/audit", au.Query, cached(cacheNever, authorize(cfg, ScopeAuditRead))...)
+
+	// -------------------------------------------------------------------
+	// Webhook
+	// -------------------------------------------------------------------
+	wh := webhookgrp.Handlers{
+		Webhook: webhook.NewCore(cfg.Log, cfg.DB, cfg.RWMux),
+	}
+	api.Handle(http.MethodPost, "/v1/webhook", wh.Create, mutating(cfg, ScopeWebhooksAdmin)...)
+	api.Handle(http.MethodGet, "/v1/webhook", wh.Query, cached(cacheNever, authorize(cfg, ScopeWebhooksAdmin))...)
+	api.Handle(http.MethodGet, "/v1/webhook/:id", wh.QueryByID, cached(cacheNever, authorize(cfg, ScopeWebhooksAdmin))...)
+	api.Handle(http.MethodPatch, "/v1/webhook/:id", wh.Update, mutating(cfg, ScopeWebhooksAdmin)...)
+	api.Handle(http.MethodDelete, "/v1/webhook/:id", wh.Delete, mutating(cfg, ScopeWebhooksAdmin)...)
+	api.Handle(http.MethodGet, "/v1/webhook/:id/delivery", wh.QueryDeliveries, cached(cacheNever, authorize(cfg, ScopeWebhooksAdmin))...)
+	api.Handle(http.MethodPost, "/v1/webhook/:id/delivery/:delivery_id/redeliver", wh.Redeliver, mutating(cfg, ScopeWebhooksAdmin)...)
+}
+
+// authorize returns the middleware requiring the scopes of a route, none
+// when callers are not authenticated.
+func authorize(cfg Config, scopes ...string) []api.Middleware {
+	if cfg.Policy == nil {
+		return nil
+	}
+	return []api.Middleware{middleware.Authorize(cfg.Log, *cfg.Policy, scopes...)}
+}
+
+// mutating returns the middleware of a route changing resources. The
+// Idempotency-Key is only handled once the caller is authorized, so a
+// stored response is never replayed to a caller that lost the scopes.
+func mutating(cfg Config, scopes ...string) []api.Middleware {
+	return append(authorize(cfg, scopes...), cfg.Idempotency)
+}
+
+// cached adds the Cache-Control policy of a read route to its middleware.
+func cached(policy string, mw []api.Middleware) []api.Middleware {
+	return append(mw, middleware.CacheControl(policy))
 }
@@ -0,0 +1,230 @@
+package webhookgrp
+
+import (
+	"github.com/chaitanyamaili/go_rest/models/webhook"
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+)
+
+// swagger:response WebhookRes
+type _ struct {
+	// in:body
+	Body struct {
+		// Success
+		//
+		Success bool `json:"success"`
+		// Timestamp
+		//
+		// example: 1639237536
+		Timestamp int64 `json:"timestamp"`
+		// Data
+		// in: body
+		Data []webhook.Webhook `json:"data"`
+	}
+}
+
+// swagger:response WebhookDeletedRes
+type _ struct {
+	// in:body
+	Body struct {
+		// Success
+		//
+		Success bool `json:"success"`
+		// Timestamp
+		//
+		// example: 1639237536
+		Timestamp int64 `json:"timestamp"`
+	}
+}
+
+// swagger:response CreatedWebhookRes
+type _ struct {
+	// in:body
+	Body struct {
+		// Success
+		//
+		Success bool `json:"success"`
+		// Timestamp
+		//
+		// example: 1639237536
+		Timestamp int64 `json:"timestamp"`
+		// Data
+		// in: body
+		Data []webhook.CreatedWebhook `json:"data"`
+	}
+}
+
+// swagger:response WebhookListRes
+type _ struct {
+	// in:body
+	Body struct {
+		// Success
+		//
+		Success bool `json:"success"`
+		// Timestamp
+		//
+		// example: 1639237536
+		Timestamp int64 `json:"timestamp"`
+		// Data
+		// in: body
+		Data []webhook.Webhook `json:"data"`
+		// Meta
+		// in: body
+		Meta database.Page `json:"meta"`
+	}
+}
+
+// swagger:response WebhookDeliveryRes
+type _ struct {
+	// in:body
+	Body struct {
+		// Success
+		//
+		Success bool `json:"success"`
+		// Timestamp
+		//
+		// example: 1639237536
+		Timestamp int64 `json:"timestamp"`
+		// Data
+		// in: body
+		Data []webhook.Delivery `json:"data"`
+	}
+}
+
+// swagger:response WebhookDeliveryListRes
+type _ struct {
+	// in:body
+	Body struct {
+		// Success
+		//
+		Success bool `json:"success"`
+		// Timestamp
+		//
+		// example: 1639237536
+		Timestamp int64 `json:"timestamp"`
+		// Data
+		// in: body
+		Data []webhook.Delivery `json:"data"`
+		// Meta
+		// in: body
+		Meta database.Page `json:"meta"`
+	}
+}
+
+// swagger:parameters WebhookQueryById WebhookUpdate WebhookDelete WebhookDeliveryQuery WebhookRedeliver
+type _ struct {
+	// Webhook ID
+	//
+	// in: path
+	// required: true
+	// enum: 1
+	// type: integer
+	ID string `json:"id"`
+}
+
+// swagger:parameters WebhookRedeliver
+type _ struct {
+	// Delivery ID
+	//
+	// in: path
+	// required: true
+	// enum: 1
+	// type: integer
+	DeliveryID string `json:"delivery_id"`
+}
+
+// swagger:parameters WebhookCreate
+type _ struct {
+	// Webhook input Json Object
+	//
+	// in: body
+	// required: true
+	Body webhook.NewWebhook
+}
+
+// swagger:parameters WebhookUpdate
+type _ struct {
+	// Webhook input Json Object
+	//
+	// in: body
+	// required: true
+	Body webhook.UpdateWebhook
+}
+
+// swagger:parameters WebhookQuery WebhookQueryById
+type _ struct {
+	// Comma separated list of the fields to return, any of id, url,
+	// event_types, active, org_uid, site_uid, created_on or updated_on.
+	// Every field is returned by default.
+	//
+	// in: query
+	// required: false
+	// example: id,url,active
+	Fields string `json:"fields"`
+}
+
+// swagger:parameters WebhookDeliveryQuery
+type _ struct {
+	// Only deliveries in this state or comma separated states, any of
+	// pending, succeeded or failed
+	//
+	// in: query
+	// required: false
+	// example: failed
+	Status string `json:"status"`
+	// Only deliveries of this event or comma separated events
+	//
+	// in: query
+	// required: false
+	// example: build.status_changed
+	Event string `json:"event"`
+	// Only the delivery of the event with this id
+	//
+	// in: query
+	// required: false
+	// example: 0f8fad5b-d9cb-469f-a165-70867728950e
+	EventUID string `json:"event_uid"`
+	// Only deliveries queued at or after this RFC3339 timestamp or date
+	//
+	// in: query
+	// required: false
+	// format: date-time
+	CreatedAfter string `json:"created_after"`
+	// Only deliveries queued before this RFC3339 timestamp or date
+	//
+	// in: query
+	// required: false
+	// format: date-time
+	CreatedBefore string `json:"created_before"`
+	// Comma separated list of the fields to return, any of id, webhook_id,
+	// event_uid, event, payload, status, attempts, next_attempt_on,
+	// last_attempt_on, response_code, last_error, delivered_on, org_uid,
+	// site_uid, created_on or updated_on. Every field is returned by
+	// default.
+	//
+	// in: query
+	// required: false
+	// example: id,event,status,attempts,last_error
+	Fields string `json:"fields"`
+}
+
+// swagger:parameters WebhookQuery WebhookQueryById WebhookDeliveryQuery
+type _ struct {
+	// Read across every tenant instead of the org and site of the request,
+	// requires the tenants:admin scope
+	//
+	// in: query
+	// required: false
+	// example: true
+	AllTenants bool `json:"all_tenants"`
+}
+
+// swagger:parameters WebhookCreate WebhookUpdate WebhookDelete WebhookRedeliver
+type _ struct {
+	// Key making the request safe to retry, the response of the first
+	// request is replayed for the configured time
+	//
+	// in: header
+	// required: false
+	// example: 5f0c7c2e-9d8b-4b8e-8a57-2f1e2d2b6c11
+	IdempotencyKey string `json:"Idempotency-Key"`
+}
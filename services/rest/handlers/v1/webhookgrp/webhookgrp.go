@@ -0,0 +1,300 @@
+package webhookgrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/chaitanyamaili/go_rest/models/webhook"
+	"github.com/chaitanyamaili/go_rest/pkg/api"
+	"github.com/chaitanyamaili/go_rest/pkg/database"
+)
+
+// Handlers manages the set of webhook endpoints.
+type Handlers struct {
+	Webhook webhook.Core
+}
+
+// Create subscribes a url to build events, the secret is only returned
+// here.
+//
+// swagger:operation POST /webhook Webhook WebhookCreate
+//
+// # Creates a new webhook
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "201":
+//		   "$ref": "#/responses/CreatedWebhookRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := api.GetContextValues(ctx)
+	if err != nil {
+		return api.NewShutdownError("api value missing from context")
+	}
+
+	var nw webhook.NewWebhook
+	if err := api.Decode(r, &nw); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	wh, err := h.Webhook.Create(ctx, nw, v.Now)
+	if err != nil {
+		return err
+	}
+
+	return api.Respond(ctx, w, []webhook.CreatedWebhook{wh}, http.StatusCreated)
+}
+
+// Update modifies a webhook, deliveries already queued go to its new url.
+//
+// swagger:operation PATCH /webhook/{id} Webhook WebhookUpdate
+//
+// # Updates a webhook
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/WebhookRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+//	  "404":
+//		   "$ref": "#/responses/errorResponse404"
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := api.GetContextValues(ctx)
+	if err != nil {
+		return api.NewShutdownError("api value missing from context")
+	}
+
+	id := api.Param(r, "id")
+
+	var uw webhook.UpdateWebhook
+	if err := api.Decode(r, &uw); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	wh, err := h.Webhook.Update(ctx, id, uw, v.Now)
+	if err != nil {
+		return webhookError(err, id)
+	}
+
+	return api.Respond(ctx, w, []webhook.Webhook{wh}, http.StatusOK)
+}
+
+// Delete removes a webhook, its pending deliveries fail. Nothing but the
+// envelope is returned.
+//
+// swagger:operation DELETE /webhook/{id} Webhook WebhookDelete
+//
+// # Deletes a webhook
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/WebhookDeletedRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+//	  "404":
+//		   "$ref": "#/responses/errorResponse404"
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := api.GetContextValues(ctx)
+	if err != nil {
+		return api.NewShutdownError("api value missing from context")
+	}
+
+	id := api.Param(r, "id")
+
+	if err := h.Webhook.Delete(ctx, id, v.Now); err != nil {
+		return webhookError(err, id)
+	}
+
+	return api.Respond(ctx, w, nil, http.StatusOK)
+}
+
+// Query all the webhooks
+//
+// swagger:operation GET /webhook Webhook WebhookQuery
+//
+// # Lists the webhooks
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/WebhookListRes"
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	pagi, err := database.PaginationParams(r)
+	if err != nil {
+		return err
+	}
+
+	fs, err := database.FieldsParams(r, webhook.Fields)
+	if err != nil {
+		return err
+	}
+
+	whs, page, err := h.Webhook.Query(ctx, fs, pagi)
+	if err != nil {
+		return fmt.Errorf("unable to query for webhooks: %w", err)
+	}
+
+	data, err := fs.Project(whs)
+	if err != nil {
+		return err
+	}
+
+	return database.RespondPage(ctx, w, r, pagi, data, page)
+}
+
+// QueryByID from an individual id
+//
+// swagger:operation GET /webhook/{id} Webhook WebhookQueryById
+//
+// # Getting a single webhook by ID
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/WebhookRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+//	  "404":
+//		   "$ref": "#/responses/errorResponse404"
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := api.Param(r, "id")
+
+	fs, err := database.FieldsParams(r, webhook.Fields)
+	if err != nil {
+		return err
+	}
+
+	wh, err := h.Webhook.QueryByID(ctx, id, fs)
+	if err != nil {
+		return webhookError(err, id)
+	}
+
+	data, err := fs.Project([]webhook.Webhook{wh})
+	if err != nil {
+		return err
+	}
+
+	return api.Respond(ctx, w, data, http.StatusOK)
+}
+
+// QueryDeliveries lists the delivery log of a webhook
+//
+// swagger:operation GET /webhook/{id}/delivery Webhook WebhookDeliveryQuery
+//
+// # Lists the deliveries of a webhook and how their last attempt went
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "200":
+//		   "$ref": "#/responses/WebhookDeliveryListRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+//	  "404":
+//		   "$ref": "#/responses/errorResponse404"
+func (h Handlers) QueryDeliveries(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := api.Param(r, "id")
+
+	pagi, err := database.PaginationParams(r)
+	if err != nil {
+		return err
+	}
+
+	filter, err := database.FilterParams(r, webhook.DeliveryFilters)
+	if err != nil {
+		return err
+	}
+
+	fs, err := database.FieldsParams(r, webhook.DeliveryFields)
+	if err != nil {
+		return err
+	}
+
+	ds, page, err := h.Webhook.QueryDeliveries(ctx, id, filter, fs, pagi)
+	if err != nil {
+		return webhookError(err, id)
+	}
+
+	data, err := fs.Project(ds)
+	if err != nil {
+		return err
+	}
+
+	return database.RespondPage(ctx, w, r, pagi, data, page)
+}
+
+// Redeliver queues a delivery to be sent again right away.
+//
+// swagger:operation POST /webhook/{id}/delivery/{delivery_id}/redeliver Webhook WebhookRedeliver
+//
+// # Sends a delivery of a webhook again
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//
+//	  "202":
+//		   "$ref": "#/responses/WebhookDeliveryRes"
+//	  "400":
+//		   "$ref": "#/responses/errorResponse400"
+//	  "404":
+//		   "$ref": "#/responses/errorResponse404"
+//	  "409":
+//		   "$ref": "#/responses/errorResponse409"
+func (h Handlers) Redeliver(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := api.GetContextValues(ctx)
+	if err != nil {
+		return api.NewShutdownError("api value missing from context")
+	}
+
+	id := api.Param(r, "id")
+	deliveryID := api.Param(r, "delivery_id")
+
+	d, err := h.Webhook.Redeliver(ctx, id, deliveryID, v.Now)
+	if err != nil {
+		return webhookError(err, id)
+	}
+
+	return api.Respond(ctx, w, []webhook.Delivery{d}, http.StatusAccepted)
+}
+
+// webhookError maps the core errors for a single webhook to the matching
+// request errors. Validation and database errors are passed through
+// untouched so the errors middleware can handle them.
+func webhookError(err error, id string) error {
+	switch {
+	case errors.Is(err, webhook.ErrInvalidID):
+		return api.NewRequestError(err, http.StatusBadRequest)
+	case errors.Is(err, webhook.ErrNotFound), errors.Is(err, webhook.ErrDeliveryNotFound):
+		return api.NewRequestError(err, http.StatusNotFound)
+	case errors.Is(err, webhook.ErrInactive):
+		return api.NewRequestError(err, http.StatusConflict)
+	case database.IsError(err):
+		return err
+	default:
+		return fmt.Errorf("webhook id[%s]: %w", id, err)
+	}
+}
@@ -2,21 +2,35 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
+	idempotencydb "github.com/chaitanyamaili/go_rest/models/idempotency"
+	"github.com/chaitanyamaili/go_rest/models/webhook"
+	"github.com/chaitanyamaili/go_rest/pkg/api/middleware"
+	"github.com/chaitanyamaili/go_rest/pkg/auth"
 	"github.com/chaitanyamaili/go_rest/pkg/database"
+	"github.com/chaitanyamaili/go_rest/pkg/idempotency"
 	"github.com/chaitanyamaili/go_rest/pkg/logger"
+	"github.com/chaitanyamaili/go_rest/pkg/metrics"
+	"github.com/chaitanyamaili/go_rest/pkg/ratelimit"
+	"github.com/chaitanyamaili/go_rest/pkg/tracing"
+	hook "github.com/chaitanyamaili/go_rest/pkg/webhook"
 	"github.com/chaitanyamaili/go_rest/services/rest/handlers"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
 )
 
 const (
@@ -68,6 +82,31 @@ func run(log *zap.SugaredLogger) error {
 	// -------------------------------------------------------------------
 	log.Infow("startup", "binary build time", appBuildTimestampLDFlag)
 
+	// -------------------------------------------------------------------
+	// Tracing
+	// -------------------------------------------------------------------
+	if viper.GetBool("tracing.enabled") {
+		log.Infow("startup.tracing", "status", "initializing tracer provider")
+
+		var cfg tracing.Config
+		if err := viper.UnmarshalKey("tracing", &cfg); err != nil {
+			return fmt.Errorf("reading tracing config: %w", err)
+		}
+		tp, err := tracing.Start(context.Background(), cfg, appName, appVersionLDFlag)
+		if err != nil {
+			return fmt.Errorf("constructing tracing: %w", err)
+		}
+		defer func() {
+			log.Infow("shutdown", "status", "stopping tracer provider", "exporter", cfg.Exporter)
+
+			ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("web.shutdownTimeout"))
+			defer cancel()
+			if err := tp.Shutdown(ctx); err != nil {
+				log.Errorw("shutdown", "status", "flushing spans", "ERROR", err)
+			}
+		}()
+	}
+
 	// -------------------------------------------------------------------
 	// Databases
 	// -------------------------------------------------------------------
@@ -92,6 +131,136 @@ func run(log *zap.SugaredLogger) error {
 		_ = db.Close()
 	}()
 
+	// -------------------------------------------------------------------
+	// Migrations
+	// -------------------------------------------------------------------
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		return runMigrate(context.Background(), log, db, os.Args[2:])
+	}
+
+	if viper.GetBool("db.autoMigrate") {
+		log.Infow("startup.migrate", "status", "applying migrations")
+
+		m, err := newMigrator(log, db)
+		if err != nil {
+			return fmt.Errorf("constructing migrator: %w", err)
+		}
+		n, err := m.Up(context.Background())
+		if err != nil {
+			return fmt.Errorf("applying migrations: %w", err)
+		}
+		log.Infow("startup.migrate", "status", "migrations applied", "applied", n)
+	}
+
+	// -------------------------------------------------------------------
+	// API Keys
+	// -------------------------------------------------------------------
+	if len(os.Args) > 1 && os.Args[1] == "apikey" {
+		return runAPIKey(context.Background(), log, db, os.Args[2:])
+	}
+
+	// -------------------------------------------------------------------
+	// Authentication
+	// -------------------------------------------------------------------
+	var authn *auth.Auth
+	if viper.GetBool("auth.enabled") {
+		log.Infow("startup.auth", "status", "loading signing keys")
+
+		authn, err = newAuth(log)
+		if err != nil {
+			return fmt.Errorf("constructing auth: %w", err)
+		}
+		defer authn.Close()
+	}
+
+	policy, err := auth.NewPolicy(viper.GetStringMapStringSlice("auth.roles"))
+	if err != nil {
+		return fmt.Errorf("constructing authorization policy: %w", err)
+	}
+
+	// -------------------------------------------------------------------
+	// Rate Limiting
+	// -------------------------------------------------------------------
+	var (
+		limiter    ratelimit.Limiter
+		rateLimits ratelimit.Policy
+	)
+	if viper.GetBool("rateLimit.enabled") {
+		log.Infow("startup.ratelimit", "status", "initializing in memory rate limiter")
+
+		var cfg ratelimit.Config
+		if err := viper.UnmarshalKey("rateLimit", &cfg); err != nil {
+			return fmt.Errorf("reading rate limits: %w", err)
+		}
+		if rateLimits, err = ratelimit.NewPolicy(cfg); err != nil {
+			return fmt.Errorf("constructing rate limits: %w", err)
+		}
+
+		mem := ratelimit.NewMemory()
+		defer mem.Close()
+		limiter = mem
+	}
+
+	// -------------------------------------------------------------------
+	// Idempotency
+	// -------------------------------------------------------------------
+	var idem *idempotency.Config
+	if viper.GetBool("idempotency.enabled") {
+		var cfg idempotency.Config
+		if err := viper.UnmarshalKey("idempotency", &cfg); err != nil {
+			return fmt.Errorf("reading idempotency config: %w", err)
+		}
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("constructing idempotency: %w", err)
+		}
+		idem = &cfg
+	}
+
+	// -------------------------------------------------------------------
+	// Compression
+	// -------------------------------------------------------------------
+	var compress *middleware.CompressConfig
+	if viper.GetBool("compress.enabled") {
+		var cfg middleware.CompressConfig
+		if err := viper.UnmarshalKey("compress", &cfg); err != nil {
+			return fmt.Errorf("reading compression config: %w", err)
+		}
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("constructing compression: %w", err)
+		}
+		compress = &cfg
+	}
+
+	// -------------------------------------------------------------------
+	// Metrics
+	// -------------------------------------------------------------------
+	var mtr *metrics.Metrics
+	if viper.GetString("metrics.host") != "" {
+		log.Infow("startup.metrics", "status", "initializing metrics")
+
+		if mtr, err = metrics.New(); err != nil {
+			return fmt.Errorf("constructing metrics: %w", err)
+		}
+		if err := mtr.RegisterDB(viper.GetString("db.dbName"), db.DB); err != nil {
+			return fmt.Errorf("constructing metrics: %w", err)
+		}
+	}
+
+	// -------------------------------------------------------------------
+	// Webhooks
+	// -------------------------------------------------------------------
+	var hooks *hook.Config
+	if viper.GetBool("webhooks.enabled") {
+		var cfg hook.Config
+		if err := viper.UnmarshalKey("webhooks", &cfg); err != nil {
+			return fmt.Errorf("reading webhooks config: %w", err)
+		}
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("constructing webhooks: %w", err)
+		}
+		hooks = &cfg
+	}
+
 	// -------------------------------------------------------------------
 	// Initialize API
 	// -------------------------------------------------------------------
@@ -104,10 +273,18 @@ func run(log *zap.SugaredLogger) error {
 	rwmux := &sync.RWMutex{}
 
 	apiMux := handlers.APIMux(handlers.APIMuxConfig{
-		Log:     log,
-		DB:      db,
-		RWMux:   rwmux,
-		Headers: viper.GetBool("app.enforceHeaders"),
+		Log:         log,
+		DB:          db,
+		RWMux:       rwmux,
+		Headers:     viper.GetBool("app.enforceHeaders"),
+		Auth:        authn,
+		APIKeys:     viper.GetBool("auth.apiKeys"),
+		Policy:      policy,
+		RateLimiter: limiter,
+		RateLimits:  rateLimits,
+		Idempotency: idem,
+		Compress:    compress,
+		Metrics:     mtr,
 	})
 
 	// -------------------------------------------------------------------
@@ -155,6 +332,91 @@ func run(log *zap.SugaredLogger) error {
 		serverErrors <- api.ListenAndServe()
 	}()
 
+	// Start the service listening for metrics requests, on a listener of
+	// its own so they are not exposed along the api.
+	if mtr != nil {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", mtr.Handler())
+
+		ms := http.Server{
+			Addr:              viper.GetString("metrics.host"),
+			Handler:           mux,
+			ReadHeaderTimeout: viper.GetDuration("web.readHeaderTimeout"),
+		}
+		defer ms.Close()
+
+		go func() {
+			log.Infow("startup.metrics", "status", "metrics router started", "host", ms.Addr)
+
+			if err := ms.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				serverErrors <- fmt.Errorf("metrics: %w", err)
+			}
+		}()
+	}
+
+	// Start the service listening for debug requests. The readiness probe
+	// fails as soon as draining is set, while the listener itself keeps
+	// serving until the api has shut down.
+	var draining atomic.Bool
+	if debugHost := viper.GetString("web.debugHost"); debugHost != "" {
+		debug := http.Server{
+			Addr: debugHost,
+			Handler: handlers.DebugMux(handlers.DebugMuxConfig{
+				Build:    appVersionLDFlag,
+				Log:      log,
+				DB:       db,
+				Draining: &draining,
+			}),
+			ReadHeaderTimeout: viper.GetDuration("web.readHeaderTimeout"),
+		}
+		defer debug.Close()
+
+		go func() {
+			log.Infow("startup.debug", "status", "debug router started", "host", debug.Addr)
+
+			if err := debug.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				serverErrors <- fmt.Errorf("debug: %w", err)
+			}
+		}()
+	}
+
+	// Start sending the webhook deliveries queued by the requests. The
+	// deliveries are stored so the ones still pending at shutdown are sent
+	// once the service is back.
+	dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+	defer stopDispatch()
+
+	dispatchDone := make(chan struct{})
+	if hooks != nil {
+		dispatcher, err := webhook.NewDispatcher(log, db, rwmux, *hooks, fmt.Sprintf("%s/%s", appName, appVersionLDFlag))
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			defer close(dispatchDone)
+			log.Infow("startup.webhooks", "status", "webhook dispatcher started", "pollInterval", hooks.PollInterval)
+
+			dispatcher.Run(dispatchCtx)
+		}()
+	} else {
+		close(dispatchDone)
+	}
+
+	// Remove the expired idempotency records in the background.
+	sweepCtx, stopSweep := context.WithCancel(context.Background())
+	defer stopSweep()
+
+	if idem != nil {
+		records := idempotencydb.NewCore(log, db, rwmux)
+
+		go func() {
+			log.Infow("startup.idempotency", "status", "idempotency sweeper started", "sweepInterval", idem.SweepInterval)
+
+			records.Sweep(sweepCtx, log, idem.SweepInterval)
+		}()
+	}
+
 	// -------------------------------------------------------------------
 	// Shutdown
 	// -------------------------------------------------------------------
@@ -172,6 +434,14 @@ func run(log *zap.SugaredLogger) error {
 		log.Infow("shutdown", "status", "shutdown started", "signal", sig)
 		defer log.Infow("shutdown", "status", "shutdown completed", "signal", sig)
 
+		// Fail readiness first and give load balancers time to notice
+		// before the api stops accepting connections.
+		draining.Store(true)
+		if delay := viper.GetDuration("web.drainDelay"); delay > 0 {
+			log.Infow("shutdown", "status", "draining", "delay", delay)
+			time.Sleep(delay)
+		}
+
 		// Give outstanding requests a deadline for completion.
 		ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("web.shutdownTimeout"))
 		defer cancel()
@@ -181,6 +451,15 @@ func run(log *zap.SugaredLogger) error {
 			_ = api.Close()
 			return fmt.Errorf("could not stop server gracefully: %w", err)
 		}
+
+		// Let the attempts under way complete, the deliveries they don't
+		// record are sent again once their lease ends.
+		stopDispatch()
+		select {
+		case <-dispatchDone:
+		case <-ctx.Done():
+			log.Infow("shutdown", "status", "webhook attempts still running")
+		}
 	}
 
 	return nil
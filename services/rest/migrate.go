@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/chaitanyamaili/go_rest/models/migrations"
+	"github.com/chaitanyamaili/go_rest/pkg/database/migrate"
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// newMigrator constructs the migrator for the embedded scripts of the
+// configured database type.
+func newMigrator(log *zap.SugaredLogger, db *sqlx.DB) (*migrate.Migrator, error) {
+	fsys, err := migrations.FS(viper.GetString("db.type"))
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.New(migrate.Config{
+		Log:         log,
+		DB:          db,
+		FS:          fsys,
+		InstalledBy: viper.GetString("db.user"),
+		LockTimeout: viper.GetDuration("db.migrationLockTimeout"),
+	})
+}
+
+// runMigrate executes the migrate sub command:
+//
+//	rest migrate up
+//	rest migrate down [steps]
+//	rest migrate status
+func runMigrate(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s migrate up|down [steps]|status", appName)
+	}
+
+	m, err := newMigrator(log, db)
+	if err != nil {
+		return fmt.Errorf("constructing migrator: %w", err)
+	}
+
+	switch args[0] {
+	case "up":
+		n, err := m.Up(ctx)
+		if err != nil {
+			return fmt.Errorf("migrate up: %w", err)
+		}
+		log.Infow("migrate", "status", "up completed", "applied", n)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+		}
+		n, err := m.Down(ctx, steps)
+		if err != nil {
+			return fmt.Errorf("migrate down: %w", err)
+		}
+		log.Infow("migrate", "status", "down completed", "undone", n)
+
+	case "status":
+		sts, err := m.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("migrate status: %w", err)
+		}
+		for _, st := range sts {
+			installedOn := ""
+			if st.InstalledOn != nil {
+				installedOn = st.InstalledOn.UTC().Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%-10s %-10s %-20s %s\n", st.Version, st.State, installedOn, st.Description)
+		}
+
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+
+	return nil
+}